@@ -2,33 +2,121 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"reflect"
+	"sync"
 
-	ollama "github.com/cloudwego/eino-ext/components/model/ollama"
-	mcpTool "github.com/cloudwego/eino-ext/components/tool/mcp"
+	"github.com/cloudwego/eino/components"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/components/tool"
 	"github.com/cloudwego/eino/compose"
 	"github.com/cloudwego/eino/schema"
 )
 
-func buildAgent(ctx context.Context, isToolAgent bool) (compose.Runnable[[]*schema.Message, []*schema.Message], error) {
-	cli, err := newMCPClient(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("newMCPClient: %w", err)
+// 图中各节点的 key
+const (
+	nodeKeyChatModel  = "chat_model"
+	nodeKeyTools      = "tools"
+	nodeKeyAnswer     = "answer"
+	nodeKeyToolResult = "tool_result"
+	nodeKeyApproval   = "approval"
+	nodeKeyGiveUp     = "give_up"
+)
+
+// defaultMaxIterations 默认最多调用模型的次数（每次调用后可能触发一轮工具执行）
+const defaultMaxIterations = 8
+
+// defaultMaxPlanSteps plan_agent 默认一次规划最多的步骤数
+const defaultMaxPlanSteps = 6
+
+// errMaxIterations 模型调用次数达到上限仍未给出最终回答
+var errMaxIterations = errors.New("agent 达到最大迭代次数仍未得到最终回答")
+
+// agentConfig 构建 agent 图的配置
+type agentConfig struct {
+	// MaxIterations 模型调用次数上限，<=0 时使用 defaultMaxIterations
+	MaxIterations int
+	// ReturnToolResult 为 true 时工具执行完直接返回工具结果，不再交回模型（ToolAgent 模式）
+	ReturnToolResult bool
+	// RequiresApproval 返回 true 的工具在执行前需要人工审批，为 nil 时所有工具直接执行
+	RequiresApproval func(name string) bool
+	// CheckPointStore 保存等待审批的运行；为 nil 时不会暂停，需要审批的调用一律按拒绝处理
+	CheckPointStore compose.CheckPointStore
+	// ToolErrorsAsResults 为 true 时工具返回的错误作为工具结果交回模型，由模型决定如何继续；
+	// 为 false 时工具出错即整次运行失败
+	ToolErrorsAsResults bool
+	// FailureAnswer 非空时模型调用出错或达到迭代上限不再让整次运行失败，而是以 FailureAnswer 开头、
+	// 附上原因的回复结束运行（plan_agent 的执行者据此把步骤标记为失败）
+	FailureAnswer string
+	// MaxPlanSteps plan_agent 一次规划最多的步骤数，<=0 时使用 defaultMaxPlanSteps
+	MaxPlanSteps int
+	// MaxReplans plan_agent 步骤失败后最多重新规划的次数
+	MaxReplans int
+}
+
+// agentState 单次运行的图状态
+type agentState struct {
+	// Messages 发送给模型的完整上下文（输入 + 运行中产生的消息）
+	Messages []*schema.Message
+	// Output 本次运行新产生的消息（assistant 与 tool 消息），作为图的输出
+	Output []*schema.Message
+	// Iterations 已调用模型的次数
+	Iterations int
+	// Decisions 恢复运行时写入的审批结果，按 tool call ID 索引，approval 节点处理后清空
+	Decisions map[string]toolDecision
+	// Rejected 被拒绝的 tool call ID 与拒绝原因，工具节点以拒绝说明代替执行结果
+	Rejected map[string]string
+}
+
+// 图状态随 checkpoint 序列化保存，需要注册类型名
+func init() {
+	schema.RegisterName[*agentState]("eino_demo_agent_state")
+	schema.RegisterName[toolDecision]("eino_demo_tool_decision")
+}
+
+// agentBuilder 以模型与工具编译一个 agent 图，agentProfile.Build 的类型
+type agentBuilder func(ctx context.Context, chatModel model.ToolCallingChatModel, tools []tool.BaseTool, cfg agentConfig) (compose.Runnable[[]*schema.Message, []*schema.Message], error)
+
+// maxIterations 模型调用次数上限，未配置时使用 defaultMaxIterations
+func (cfg agentConfig) maxIterations() int {
+	if cfg.MaxIterations <= 0 {
+		return defaultMaxIterations
 	}
+	return cfg.MaxIterations
+}
 
-	tools, err := mcpTool.GetTools(ctx, &mcpTool.Config{Cli: cli})
+// buildAgent 以给定的模型与工具编译 ReAct 循环图
+func buildAgent(ctx context.Context, chatModel model.ToolCallingChatModel, tools []tool.BaseTool, cfg agentConfig) (compose.Runnable[[]*schema.Message, []*schema.Message], error) {
+	g, err := newAgentGraph(ctx, chatModel, tools, cfg)
 	if err != nil {
-		return nil, fmt.Errorf("GetTools: %w", err)
+		return nil, err
+	}
+	opts := agentCompileOptions(cfg)
+	if cfg.CheckPointStore != nil {
+		opts = append(opts, compose.WithCheckPointStore(cfg.CheckPointStore))
 	}
+	return g.Compile(ctx, opts...)
+}
 
-	// 使用本地 Ollama 作为 ChatModel
-	chatModel, err := ollama.NewChatModel(ctx, &ollama.ChatModelConfig{
-		BaseURL: "http://localhost:11434",
-		Model:   "qwen2.5:7b",
-	})
-	if err != nil {
-		return nil, fmt.Errorf("NewChatModel(ollama): %w", err)
+// agentCompileOptions ReAct 图的编译选项；作为子图嵌入其他图时也使用这些选项，checkpoint store 则由最外层的图设置
+func agentCompileOptions(cfg agentConfig) []compose.GraphCompileOption {
+	// 每轮迭代最多经过 chat_model、approval、tools 三个节点，另留出出口节点的余量；
+	// 图结构与是否配置审批无关，工具变化重新编译后仍能从旧的 checkpoint 恢复
+	opts := []compose.GraphCompileOption{
+		compose.WithNodeTriggerMode(compose.AnyPredecessor),
+		compose.WithMaxRunSteps(3*cfg.maxIterations() + 2),
 	}
+	if cfg.CheckPointStore != nil {
+		opts = append(opts, compose.WithInterruptBeforeNodes([]string{nodeKeyApproval}))
+	}
+	return opts
+}
+
+// newAgentGraph 构建（未编译的）ReAct 循环图
+func newAgentGraph(ctx context.Context, chatModel model.ToolCallingChatModel, tools []tool.BaseTool, cfg agentConfig) (*compose.Graph[[]*schema.Message, []*schema.Message], error) {
+	maxIterations := cfg.maxIterations()
 
 	// 绑定工具信息
 	var toolInfos []*schema.ToolInfo
@@ -39,25 +127,313 @@ func buildAgent(ctx context.Context, isToolAgent bool) (compose.Runnable[[]*sche
 		}
 		toolInfos = append(toolInfos, info)
 	}
-	if err := chatModel.BindTools(toolInfos); err != nil {
-		return nil, fmt.Errorf("BindTools: %w", err)
+	// MCP Server 尚未连上时没有工具可绑定（部分 provider 不接受空工具列表），直接使用原模型
+	var boundModel model.BaseChatModel = chatModel
+	if len(toolInfos) > 0 {
+		m, err := chatModel.WithTools(toolInfos)
+		if err != nil {
+			return nil, fmt.Errorf("WithTools: %w", err)
+		}
+		boundModel = m
+	}
+	if cfg.FailureAnswer != "" {
+		boundModel = &failureAnswerModel{inner: boundModel, prefix: cfg.FailureAnswer}
 	}
 
+	middlewares := []compose.ToolMiddleware{rejectedToolMiddleware()}
+	if cfg.ToolErrorsAsResults {
+		middlewares = append(middlewares, toolErrorMiddleware())
+	}
 	toolsNode, err := compose.NewToolNode(ctx, &compose.ToolsNodeConfig{
 		Tools: tools,
+		// 模型臆造了不存在的工具时，把提示作为工具结果交回模型，而不是让整次运行失败
+		UnknownToolsHandler: func(ctx context.Context, name, input string) (string, error) {
+			return fmt.Sprintf("工具 %s 不存在，请改用可用工具或直接回答用户", name), nil
+		},
+		ToolCallMiddlewares: middlewares,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("NewToolNode: %w", err)
 	}
 
-	chain := compose.NewChain[[]*schema.Message, []*schema.Message]()
-	chain.
-		AppendChatModel(chatModel, compose.WithNodeName("chat_model")).
-		AppendToolsNode(toolsNode, compose.WithNodeName("tools"))
-	if !isToolAgent {
-		chain.AppendChatModel(chatModel, compose.WithNodeName("chat_model_final"))
-		chain.AppendLambda(compose.ToList[*schema.Message]())
+	// ReAct 循环：chat_model → (有 tool call) tools → chat_model → ... → (无 tool call) answer
+	g := compose.NewGraph[[]*schema.Message, []*schema.Message](
+		compose.WithGenLocalState(func(ctx context.Context) *agentState {
+			return &agentState{}
+		}),
+	)
+
+	// 模型调用前：累积上下文并检查迭代上限
+	modelPreHandle := func(ctx context.Context, in []*schema.Message, state *agentState) ([]*schema.Message, error) {
+		if state.Iterations >= maxIterations {
+			return nil, fmt.Errorf("%w（上限 %d 次）", errMaxIterations, maxIterations)
+		}
+		state.Iterations++
+		state.Messages = append(state.Messages, in...)
+		return state.Messages, nil
+	}
+	modelPostHandle := func(ctx context.Context, out *schema.Message, state *agentState) (*schema.Message, error) {
+		state.Messages = append(state.Messages, out)
+		state.Output = append(state.Output, out)
+		return out, nil
+	}
+	if err := g.AddChatModelNode(nodeKeyChatModel, boundModel,
+		compose.WithStatePreHandler(modelPreHandle),
+		compose.WithStatePostHandler(modelPostHandle),
+		compose.WithNodeName(nodeKeyChatModel),
+	); err != nil {
+		return nil, err
+	}
+
+	// 工具执行后：记录 tool 消息，下一轮模型调用时由 modelPreHandle 追加到上下文
+	toolsPostHandle := func(ctx context.Context, out []*schema.Message, state *agentState) ([]*schema.Message, error) {
+		state.Output = append(state.Output, out...)
+		return out, nil
+	}
+	if err := g.AddToolsNode(nodeKeyTools, toolsNode,
+		compose.WithStatePostHandler(toolsPostHandle),
+		compose.WithNodeName(nodeKeyTools),
+	); err != nil {
+		return nil, err
+	}
+
+	// 两个出口节点都返回本次运行产生的全部消息
+	if err := g.AddLambdaNode(nodeKeyAnswer, compose.InvokableLambda(func(ctx context.Context, _ *schema.Message) ([]*schema.Message, error) {
+		return collectOutput(ctx)
+	}), compose.WithNodeName(nodeKeyAnswer)); err != nil {
+		return nil, err
+	}
+	if err := g.AddLambdaNode(nodeKeyToolResult, compose.InvokableLambda(func(ctx context.Context, _ []*schema.Message) ([]*schema.Message, error) {
+		return collectOutput(ctx)
+	}), compose.WithNodeName(nodeKeyToolResult)); err != nil {
+		return nil, err
+	}
+
+	// 配置了 FailureAnswer 时，迭代次数用完而模型仍要调用工具则以失败回复结束，而不是报错
+	if err := g.AddLambdaNode(nodeKeyGiveUp, compose.InvokableLambda(func(ctx context.Context, _ *schema.Message) ([]*schema.Message, error) {
+		var out []*schema.Message
+		err := compose.ProcessState(ctx, func(_ context.Context, state *agentState) error {
+			reason := fmt.Errorf("%w（上限 %d 次）", errMaxIterations, maxIterations)
+			state.Output = append(state.Output, schema.AssistantMessage(fmt.Sprintf("%s %v", cfg.FailureAnswer, reason), nil))
+			out = state.Output
+			return nil
+		})
+		return out, err
+	}), compose.WithNodeName(nodeKeyGiveUp)); err != nil {
+		return nil, err
+	}
+
+	// 审批节点：图在进入该节点前中断，恢复后按审批结果改写或拒绝本轮的 tool call
+	if err := g.AddLambdaNode(nodeKeyApproval, compose.InvokableLambda(func(ctx context.Context, msg *schema.Message) (*schema.Message, error) {
+		err := compose.ProcessState(ctx, func(_ context.Context, state *agentState) error {
+			applyDecisions(state, msg, cfg.RequiresApproval)
+			return nil
+		})
+		return msg, err
+	}), compose.WithNodeName(nodeKeyApproval)); err != nil {
+		return nil, err
+	}
+
+	if err := g.AddEdge(compose.START, nodeKeyChatModel); err != nil {
+		return nil, err
+	}
+	// 模型输出含 tool call 则执行工具（其中有需要审批的工具时先经过审批节点），否则即为最终回答
+	if err := g.AddBranch(nodeKeyChatModel, compose.NewGraphBranch(func(ctx context.Context, msg *schema.Message) (string, error) {
+		if len(msg.ToolCalls) == 0 {
+			return nodeKeyAnswer, nil
+		}
+		if cfg.FailureAnswer != "" {
+			exhausted := false
+			if err := compose.ProcessState(ctx, func(_ context.Context, state *agentState) error {
+				exhausted = state.Iterations >= maxIterations
+				return nil
+			}); err != nil {
+				return "", err
+			}
+			if exhausted {
+				return nodeKeyGiveUp, nil
+			}
+		}
+		if cfg.RequiresApproval != nil {
+			for _, tc := range msg.ToolCalls {
+				if cfg.RequiresApproval(tc.Function.Name) {
+					return nodeKeyApproval, nil
+				}
+			}
+		}
+		return nodeKeyTools, nil
+	}, map[string]bool{nodeKeyTools: true, nodeKeyApproval: true, nodeKeyAnswer: true, nodeKeyGiveUp: true})); err != nil {
+		return nil, err
+	}
+	if err := g.AddEdge(nodeKeyApproval, nodeKeyTools); err != nil {
+		return nil, err
+	}
+	if cfg.ReturnToolResult {
+		err = g.AddEdge(nodeKeyTools, nodeKeyToolResult)
+	} else {
+		err = g.AddEdge(nodeKeyTools, nodeKeyChatModel)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := g.AddEdge(nodeKeyAnswer, compose.END); err != nil {
+		return nil, err
+	}
+	if err := g.AddEdge(nodeKeyToolResult, compose.END); err != nil {
+		return nil, err
+	}
+	if err := g.AddEdge(nodeKeyGiveUp, compose.END); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// failureAnswerModel 把模型调用的错误转成以 prefix 开头的回复（agentConfig.FailureAnswer）；
+// ctx 取消时仍按错误返回，流式输出已经送出内容后出错也按错误返回
+type failureAnswerModel struct {
+	inner  model.BaseChatModel
+	prefix string
+}
+
+func (m *failureAnswerModel) failure(ctx context.Context, err error) (*schema.Message, bool) {
+	if ctx.Err() != nil {
+		return nil, false
+	}
+	return schema.AssistantMessage(fmt.Sprintf("%s 模型调用失败：%v", m.prefix, err), nil), true
+}
+
+func (m *failureAnswerModel) Generate(ctx context.Context, in []*schema.Message, opts ...model.Option) (*schema.Message, error) {
+	out, err := m.inner.Generate(ctx, in, opts...)
+	if err != nil {
+		if msg, ok := m.failure(ctx, err); ok {
+			return msg, nil
+		}
+	}
+	return out, err
+}
+
+func (m *failureAnswerModel) Stream(ctx context.Context, in []*schema.Message, opts ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	sr, err := m.inner.Stream(ctx, in, opts...)
+	if err != nil {
+		if msg, ok := m.failure(ctx, err); ok {
+			return schema.StreamReaderFromArray([]*schema.Message{msg}), nil
+		}
+		return nil, err
+	}
+	// 部分 provider 在首次 Recv 时才返回请求错误
+	r, w := schema.Pipe[*schema.Message](1)
+	go func() {
+		defer sr.Close()
+		defer w.Close()
+		sent := false
+		for {
+			chunk, err := sr.Recv()
+			if errors.Is(err, io.EOF) {
+				return
+			}
+			if err != nil {
+				if msg, ok := m.failure(ctx, err); ok && !sent {
+					w.Send(msg, nil)
+				} else {
+					w.Send(nil, err)
+				}
+				return
+			}
+			sent = true
+			if closed := w.Send(chunk, nil); closed {
+				return
+			}
+		}
+	}()
+	return r, nil
+}
+
+// GetType 与 IsCallbacksEnabled 沿用原模型，回调与指标中的模型类型不变
+func (m *failureAnswerModel) GetType() string {
+	if typ, ok := components.GetType(m.inner); ok {
+		return typ
 	}
+	return reflect.Indirect(reflect.ValueOf(m.inner)).Type().Name()
+}
+
+func (m *failureAnswerModel) IsCallbacksEnabled() bool {
+	return components.IsCallbacksEnabled(m.inner)
+}
+
+// toolErrorMiddleware 把工具调用的错误转成工具结果；中断与 ctx 取消仍按错误返回
+func toolErrorMiddleware() compose.ToolMiddleware {
+	asResult := func(ctx context.Context, in *compose.ToolInput, err error) (string, bool) {
+		if _, ok := compose.IsInterruptRerunError(err); ok || ctx.Err() != nil {
+			return "", false
+		}
+		return fmt.Sprintf("工具 %s 调用失败：%v", in.Name, err), true
+	}
+	return compose.ToolMiddleware{
+		Invokable: func(next compose.InvokableToolEndpoint) compose.InvokableToolEndpoint {
+			return func(ctx context.Context, in *compose.ToolInput) (*compose.ToolOutput, error) {
+				out, err := next(ctx, in)
+				if err != nil {
+					if result, ok := asResult(ctx, in, err); ok {
+						return &compose.ToolOutput{Result: result}, nil
+					}
+				}
+				return out, err
+			}
+		},
+		Streamable: func(next compose.StreamableToolEndpoint) compose.StreamableToolEndpoint {
+			return func(ctx context.Context, in *compose.ToolInput) (*compose.StreamToolOutput, error) {
+				out, err := next(ctx, in)
+				if err != nil {
+					if result, ok := asResult(ctx, in, err); ok {
+						return &compose.StreamToolOutput{Result: schema.StreamReaderFromArray([]string{result})}, nil
+					}
+				}
+				return out, err
+			}
+		},
+	}
+}
+
+// collectOutput 从图状态中取出本次运行产生的消息
+func collectOutput(ctx context.Context) ([]*schema.Message, error) {
+	var out []*schema.Message
+	err := compose.ProcessState(ctx, func(_ context.Context, state *agentState) error {
+		out = state.Output
+		return nil
+	})
+	return out, err
+}
+
+// agentHolder 持有当前编译好的 agent 图并转发调用；MCP 工具变化时整体替换，已开始的运行继续使用旧图
+type agentHolder struct {
+	mu      sync.RWMutex
+	current compose.Runnable[[]*schema.Message, []*schema.Message]
+}
+
+func (h *agentHolder) get() compose.Runnable[[]*schema.Message, []*schema.Message] {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.current
+}
+
+func (h *agentHolder) set(r compose.Runnable[[]*schema.Message, []*schema.Message]) {
+	h.mu.Lock()
+	h.current = r
+	h.mu.Unlock()
+}
+
+func (h *agentHolder) Invoke(ctx context.Context, input []*schema.Message, opts ...compose.Option) ([]*schema.Message, error) {
+	return h.get().Invoke(ctx, input, opts...)
+}
+
+func (h *agentHolder) Stream(ctx context.Context, input []*schema.Message, opts ...compose.Option) (*schema.StreamReader[[]*schema.Message], error) {
+	return h.get().Stream(ctx, input, opts...)
+}
+
+func (h *agentHolder) Collect(ctx context.Context, input *schema.StreamReader[[]*schema.Message], opts ...compose.Option) ([]*schema.Message, error) {
+	return h.get().Collect(ctx, input, opts...)
+}
 
-	return chain.Compile(ctx)
+func (h *agentHolder) Transform(ctx context.Context, input *schema.StreamReader[[]*schema.Message], opts ...compose.Option) (*schema.StreamReader[[]*schema.Message], error) {
+	return h.get().Transform(ctx, input, opts...)
 }
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/aistudiolabx/eino-demo/backend/agenttest"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+func TestBuildAgentToolLoop(t *testing.T) {
+	ctx := context.Background()
+	srv, tools := mcpTools(t, nil)
+	cm := agenttest.NewFakeChatModel(
+		agenttest.CallTools(agenttest.ToolCall("call_1", "weather", `{"city":"Beijing"}`)),
+		agenttest.Reply("北京今天晴，21.5°C。"),
+	)
+	r, err := buildAgent(ctx, cm, tools, agentConfig{MaxIterations: 4})
+	if err != nil {
+		t.Fatalf("buildAgent: %v", err)
+	}
+
+	out, err := r.Invoke(ctx, []*schema.Message{schema.UserMessage("北京天气怎么样")})
+	if err != nil {
+		t.Fatalf("Invoke: %v", err)
+	}
+	if len(out) != 3 {
+		t.Fatalf("got %d messages, want tool call, tool result and answer: %v", len(out), out)
+	}
+	if out[1].Role != schema.Tool || out[1].ToolCallID != "call_1" || !strings.Contains(out[1].Content, "21.5") {
+		t.Errorf("tool message = %+v, want weather result for call_1", out[1])
+	}
+	if got := finalAnswer(out); got != "北京今天晴，21.5°C。" {
+		t.Errorf("finalAnswer = %q", got)
+	}
+
+	calls := cm.Calls()
+	if len(calls) != 2 {
+		t.Fatalf("model called %d times, want 2", len(calls))
+	}
+	if last := calls[1][len(calls[1])-1]; last.Role != schema.Tool {
+		t.Errorf("second model call ends with %s message, want tool result", last.Role)
+	}
+	if !slices.ContainsFunc(cm.BoundTools(), func(ti *schema.ToolInfo) bool { return ti.Name == "weather" }) {
+		t.Error("weather tool not bound to the model")
+	}
+	if !slices.ContainsFunc(srv.Upstream.Requests(), func(r string) bool { return strings.Contains(r, "/v1/forecast") }) {
+		t.Errorf("forecast API not called, upstream requests: %v", srv.Upstream.Requests())
+	}
+}
+
+func TestBuildAgentReturnToolResult(t *testing.T) {
+	ctx := context.Background()
+	_, tools := mcpTools(t, nil)
+	cm := agenttest.NewFakeChatModel(agenttest.CallTools(agenttest.ToolCall("call_1", "weather", `{"city":"Shanghai"}`)))
+	r, err := buildAgent(ctx, cm, tools, agentConfig{ReturnToolResult: true})
+	if err != nil {
+		t.Fatalf("buildAgent: %v", err)
+	}
+
+	out, err := r.Invoke(ctx, []*schema.Message{schema.UserMessage("上海天气")})
+	if err != nil {
+		t.Fatalf("Invoke: %v", err)
+	}
+	if got := toolAgentOutput(out); !strings.Contains(got, "24.1") {
+		t.Errorf("toolAgentOutput = %q, want the weather tool result", got)
+	}
+	if cm.Remaining() != 0 || len(cm.Calls()) != 1 {
+		t.Errorf("model called %d times, want 1", len(cm.Calls()))
+	}
+}
+
+func TestBuildAgentMaxIterations(t *testing.T) {
+	ctx := context.Background()
+	_, tools := mcpTools(t, nil)
+	call := agenttest.CallTools(agenttest.ToolCall("call_1", "weather", `{"city":"Beijing"}`))
+	cm := agenttest.NewFakeChatModel(call, call, call)
+	r, err := buildAgent(ctx, cm, tools, agentConfig{MaxIterations: 2})
+	if err != nil {
+		t.Fatalf("buildAgent: %v", err)
+	}
+
+	if _, err := r.Invoke(ctx, []*schema.Message{schema.UserMessage("北京天气")}); !errors.Is(err, errMaxIterations) {
+		t.Fatalf("Invoke error = %v, want errMaxIterations", err)
+	}
+	if n := len(cm.Calls()); n != 2 {
+		t.Errorf("model called %d times, want 2", n)
+	}
+}
+
+// streamModel 只用于 Stream：依次送出 chunks，之后以 err 结束
+type streamModel struct {
+	chunks []string
+	err    error
+}
+
+func (m *streamModel) Generate(context.Context, []*schema.Message, ...model.Option) (*schema.Message, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (m *streamModel) Stream(context.Context, []*schema.Message, ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	r, w := schema.Pipe[*schema.Message](len(m.chunks) + 1)
+	for _, c := range m.chunks {
+		w.Send(schema.AssistantMessage(c, nil), nil)
+	}
+	w.Send(nil, m.err)
+	w.Close()
+	return r, nil
+}
+
+// 首次 Recv 即出错时转成失败回复；已经送出内容后出错、或 ctx 已取消时仍按错误返回
+func TestFailureAnswerModelStream(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, tc := range []struct {
+		name    string
+		ctx     context.Context
+		chunks  []string
+		want    string
+		wantErr bool
+	}{
+		{name: "error before content", ctx: context.Background(), want: "[FAILED] 模型调用失败：502"},
+		{name: "error after content", ctx: context.Background(), chunks: []string{"北京", "晴"}, wantErr: true},
+		{name: "cancelled", ctx: cancelled, wantErr: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			m := &failureAnswerModel{inner: &streamModel{chunks: tc.chunks, err: errors.New("502")}, prefix: stepFailedMarker}
+			sr, err := m.Stream(tc.ctx, nil)
+			if err != nil {
+				t.Fatalf("Stream: %v", err)
+			}
+			defer sr.Close()
+			var content string
+			for {
+				chunk, err := sr.Recv()
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				if err != nil {
+					if !tc.wantErr {
+						t.Fatalf("Recv: %v", err)
+					}
+					return
+				}
+				content += chunk.Content
+			}
+			if tc.wantErr || content != tc.want {
+				t.Errorf("content = %q, want %q (error %v)", content, tc.want, tc.wantErr)
+			}
+		})
+	}
+}
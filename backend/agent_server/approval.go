@@ -0,0 +1,677 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/eino/compose"
+	"github.com/cloudwego/eino/schema"
+)
+
+// 需要人工审批的工具（配置 agent.approval.tools）：模型给出的 tool call 中含有这类工具时，
+// 图在 approval 节点前中断，运行状态以 checkpoint 保存，等待审批后从中断处继续：
+//
+//	/agent、/tool_agent    返回 202，响应体 approval 字段为待审批记录
+//	/agent/stream          发送 approval_required 事件后关闭连接
+//	/v1/chat/completions   回复中说明需要审批，扩展字段 approval 为待审批记录
+//	/jobs                  任务进入 awaiting_approval 状态，approval 字段为待审批记录
+//
+//	GET  /approvals       列出待审批的运行
+//	GET  /approvals/{id}  查看待审批记录
+//	POST /approvals/{id}  提交审批结果并继续运行：
+//	  {"decisions": [{"tool_call_id": "...", "action": "approve|edit|reject", "arguments": "{...}", "reason": "..."}],
+//	   "action": "approve"}   未逐个给出决定的 tool call 使用顶层 action
+//
+// 同步接口发起的运行在 POST 请求中继续，响应同 /agent（再次需要审批时仍返回 202）；
+// 任务发起的运行重新进入任务队列，响应为任务状态。被拒绝的调用不会执行，
+// 模型收到拒绝说明作为工具结果后继续回答。同一次运行再次暂停时沿用同一个审批 ID。
+// 待审批期间会话保持进行中，该会话上的新请求返回 409，直到审批后的运行结束或待审批记录被放弃。
+
+// 审批动作
+const (
+	approvalApprove = "approve"
+	approvalEdit    = "edit"
+	approvalReject  = "reject"
+)
+
+var (
+	errApprovalNotFound = errors.New("approval not found")
+	errApprovalBusy     = errors.New("approval is being processed")
+)
+
+var approvalIDPattern = regexp.MustCompile(`^apr-[0-9a-f]{24}$`)
+
+// toolDecision 对一个 tool call 的审批结果
+type toolDecision struct {
+	Action string
+	// Arguments edit 时替换的参数（JSON 字符串）
+	Arguments string
+	// Reason reject 时告诉模型的原因
+	Reason string
+}
+
+// applyDecisions 在 approval 节点中按 state.Decisions 处理本轮的 tool call：
+// edit 的调用改写参数（同时改写上下文中的 assistant 消息，使模型看到实际执行的参数），
+// reject 与未给出决定的调用记入 state.Rejected，不需要审批的调用原样执行
+func applyDecisions(state *agentState, msg *schema.Message, requiresApproval func(string) bool) {
+	for i, tc := range msg.ToolCalls {
+		if requiresApproval == nil || !requiresApproval(tc.Function.Name) {
+			continue
+		}
+		d, ok := state.Decisions[tc.ID]
+		switch {
+		case !ok:
+			rejectToolCall(state, tc.ID, "未获得审批")
+		case d.Action == approvalEdit:
+			msg.ToolCalls[i].Function.Arguments = d.Arguments
+			editToolCall(state.Messages, tc.ID, d.Arguments)
+			editToolCall(state.Output, tc.ID, d.Arguments)
+		case d.Action == approvalReject:
+			rejectToolCall(state, tc.ID, d.Reason)
+		}
+	}
+	state.Decisions = nil
+}
+
+func rejectToolCall(state *agentState, id, reason string) {
+	if state.Rejected == nil {
+		state.Rejected = make(map[string]string)
+	}
+	state.Rejected[id] = reason
+}
+
+// editToolCall 改写 msgs 中最后一条 assistant 消息里对应 tool call 的参数
+func editToolCall(msgs []*schema.Message, id, arguments string) {
+	for i := len(msgs) - 1; i >= 0; i-- {
+		if msgs[i].Role != schema.Assistant {
+			continue
+		}
+		for j := range msgs[i].ToolCalls {
+			if msgs[i].ToolCalls[j].ID == id {
+				msgs[i].ToolCalls[j].Function.Arguments = arguments
+			}
+		}
+		return
+	}
+}
+
+// rejectedToolMiddleware 被拒绝的 tool call 不执行工具，以拒绝说明作为工具结果交回模型
+func rejectedToolMiddleware() compose.ToolMiddleware {
+	rejected := func(ctx context.Context, in *compose.ToolInput) (string, bool) {
+		var (
+			reason string
+			ok     bool
+		)
+		_ = compose.ProcessState(ctx, func(_ context.Context, state *agentState) error {
+			reason, ok = state.Rejected[in.CallID]
+			return nil
+		})
+		if !ok {
+			return "", false
+		}
+		result := fmt.Sprintf("用户拒绝执行工具 %s，请不要再次调用，直接告诉用户该操作未执行", in.Name)
+		if reason != "" {
+			result += "。拒绝原因：" + reason
+		}
+		return result, true
+	}
+	return compose.ToolMiddleware{
+		Invokable: func(next compose.InvokableToolEndpoint) compose.InvokableToolEndpoint {
+			return func(ctx context.Context, in *compose.ToolInput) (*compose.ToolOutput, error) {
+				if result, ok := rejected(ctx, in); ok {
+					return &compose.ToolOutput{Result: result}, nil
+				}
+				return next(ctx, in)
+			}
+		},
+		Streamable: func(next compose.StreamableToolEndpoint) compose.StreamableToolEndpoint {
+			return func(ctx context.Context, in *compose.ToolInput) (*compose.StreamToolOutput, error) {
+				if result, ok := rejected(ctx, in); ok {
+					return &compose.StreamToolOutput{Result: schema.StreamReaderFromArray([]string{result})}, nil
+				}
+				return next(ctx, in)
+			}
+		},
+	}
+}
+
+// pendingApproval 一次等待审批的运行
+type pendingApproval struct {
+	ID string `json:"id"`
+	// Agent 运行所用的 profile
+	Agent     string `json:"agent"`
+	SessionID string `json:"session_id,omitempty"`
+	// JobID 由任务发起的运行，审批后回到任务队列继续
+	JobID string `json:"job_id,omitempty"`
+	// Input 本轮用户输入，继续运行结束后与结果一起写入会话
+	Input string `json:"input"`
+	// ToolCalls 模型本轮给出的全部 tool call，requires_approval 为 true 的需要逐个决定
+	ToolCalls []pendingToolCall `json:"tool_calls"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+type pendingToolCall struct {
+	ID               string `json:"id"`
+	Name             string `json:"name"`
+	Arguments        string `json:"arguments"`
+	RequiresApproval bool   `json:"requires_approval"`
+}
+
+// approvalRequest POST /approvals/{id} 请求体
+type approvalRequest struct {
+	Decisions []approvalDecision `json:"decisions"`
+	// Action 未在 decisions 中出现的 tool call 使用的动作，为空时每个需要审批的调用都必须给出决定
+	Action string `json:"action,omitempty"`
+}
+
+type approvalDecision struct {
+	ToolCallID string `json:"tool_call_id"`
+	Action     string `json:"action"`
+	Arguments  string `json:"arguments,omitempty"`
+	Reason     string `json:"reason,omitempty"`
+}
+
+// decisions 校验审批请求并转为图状态中的审批结果
+func (a *pendingApproval) decisions(req approvalRequest) (map[string]toolDecision, error) {
+	pending := make(map[string]bool)
+	for _, tc := range a.ToolCalls {
+		if tc.RequiresApproval {
+			pending[tc.ID] = true
+		}
+	}
+	out := make(map[string]toolDecision, len(pending))
+	for _, d := range req.Decisions {
+		if !pending[d.ToolCallID] {
+			return nil, fmt.Errorf("tool call %q 不需要审批或不存在", d.ToolCallID)
+		}
+		td, err := newToolDecision(d.Action, d.Arguments, d.Reason)
+		if err != nil {
+			return nil, fmt.Errorf("tool call %q: %w", d.ToolCallID, err)
+		}
+		out[d.ToolCallID] = td
+	}
+	for id := range pending {
+		if _, ok := out[id]; ok {
+			continue
+		}
+		if req.Action == "" {
+			return nil, fmt.Errorf("tool call %q 缺少审批结果", id)
+		}
+		td, err := newToolDecision(req.Action, "", "")
+		if err != nil {
+			return nil, err
+		}
+		out[id] = td
+	}
+	return out, nil
+}
+
+func newToolDecision(action, arguments, reason string) (toolDecision, error) {
+	switch action {
+	case approvalApprove, approvalReject:
+	case approvalEdit:
+		var args map[string]any
+		if err := json.Unmarshal([]byte(arguments), &args); err != nil {
+			return toolDecision{}, fmt.Errorf("edit 需要 JSON 对象形式的 arguments: %w", err)
+		}
+	default:
+		return toolDecision{}, fmt.Errorf("action 只能是 approve、edit 或 reject，当前为 %q", action)
+	}
+	return toolDecision{Action: action, Arguments: arguments, Reason: reason}, nil
+}
+
+// ApprovalStore 保存等待审批的运行：图的 checkpoint 与对外展示的待审批记录
+type ApprovalStore interface {
+	compose.CheckPointStore
+	// GetApproval 读取待审批记录，不存在时返回 errApprovalNotFound
+	GetApproval(ctx context.Context, id string) (*pendingApproval, error)
+	// SaveApproval 创建或覆盖待审批记录
+	SaveApproval(ctx context.Context, a *pendingApproval) error
+	// DeleteApproval 删除待审批记录及其 checkpoint，不存在时不报错
+	DeleteApproval(ctx context.Context, id string) error
+	// ListApprovals 按创建时间倒序列出待审批记录
+	ListApprovals(ctx context.Context) ([]*pendingApproval, error)
+}
+
+// memoryApprovalStore 进程内存储，重启后丢失
+type memoryApprovalStore struct {
+	mu          sync.RWMutex
+	checkpoints map[string][]byte
+	approvals   map[string]*pendingApproval
+}
+
+func newMemoryApprovalStore() *memoryApprovalStore {
+	return &memoryApprovalStore{
+		checkpoints: make(map[string][]byte),
+		approvals:   make(map[string]*pendingApproval),
+	}
+}
+
+func (s *memoryApprovalStore) Get(_ context.Context, id string) ([]byte, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	cp, ok := s.checkpoints[id]
+	return cp, ok, nil
+}
+
+func (s *memoryApprovalStore) Set(_ context.Context, id string, cp []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.checkpoints[id] = cp
+	return nil
+}
+
+func (s *memoryApprovalStore) GetApproval(_ context.Context, id string) (*pendingApproval, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	a, ok := s.approvals[id]
+	if !ok {
+		return nil, errApprovalNotFound
+	}
+	cp := *a
+	return &cp, nil
+}
+
+func (s *memoryApprovalStore) SaveApproval(_ context.Context, a *pendingApproval) error {
+	cp := *a
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.approvals[a.ID] = &cp
+	return nil
+}
+
+func (s *memoryApprovalStore) DeleteApproval(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.approvals, id)
+	delete(s.checkpoints, id)
+	return nil
+}
+
+func (s *memoryApprovalStore) ListApprovals(_ context.Context) ([]*pendingApproval, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]*pendingApproval, 0, len(s.approvals))
+	for _, a := range s.approvals {
+		cp := *a
+		out = append(out, &cp)
+	}
+	sortApprovals(out)
+	return out, nil
+}
+
+// fileApprovalStore 目录下每个运行两个文件：<id>.checkpoint 与 <id>.json
+type fileApprovalStore struct {
+	mu  sync.Mutex
+	dir string
+}
+
+func newFileApprovalStore(dir string) (*fileApprovalStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("创建审批目录失败: %w", err)
+	}
+	return &fileApprovalStore{dir: dir}, nil
+}
+
+func (s *fileApprovalStore) path(id, ext string) string {
+	return filepath.Join(s.dir, id+ext)
+}
+
+// write 先写临时文件再重命名，避免进程中断留下半截文件
+func (s *fileApprovalStore) write(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (s *fileApprovalStore) Get(_ context.Context, id string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cp, err := os.ReadFile(s.path(id, ".checkpoint"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return cp, true, nil
+}
+
+func (s *fileApprovalStore) Set(_ context.Context, id string, cp []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.write(s.path(id, ".checkpoint"), cp)
+}
+
+func (s *fileApprovalStore) GetApproval(_ context.Context, id string) (*pendingApproval, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.read(s.path(id, ".json"))
+}
+
+func (s *fileApprovalStore) read(path string) (*pendingApproval, error) {
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, errApprovalNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var a pendingApproval
+	if err := json.Unmarshal(raw, &a); err != nil {
+		return nil, fmt.Errorf("解析审批文件 %s 失败: %w", path, err)
+	}
+	return &a, nil
+}
+
+func (s *fileApprovalStore) SaveApproval(_ context.Context, a *pendingApproval) error {
+	raw, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.write(s.path(a.ID, ".json"), raw)
+}
+
+func (s *fileApprovalStore) DeleteApproval(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, ext := range []string{".json", ".checkpoint"} {
+		if err := os.Remove(s.path(id, ext)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *fileApprovalStore) ListApprovals(_ context.Context) ([]*pendingApproval, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*pendingApproval, 0, len(entries))
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		a, err := s.read(filepath.Join(s.dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, a)
+	}
+	sortApprovals(out)
+	return out, nil
+}
+
+func sortApprovals(out []*pendingApproval) {
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.After(out[j].CreatedAt) })
+}
+
+// approvalManager 负责暂停运行时保存待审批记录，以及审批后从 checkpoint 继续运行
+type approvalManager struct {
+	store    ApprovalStore
+	sessions *sessionManager
+	profiles []*agentProfile
+
+	mu       sync.Mutex
+	resuming map[string]bool
+}
+
+func newApprovalManager(store ApprovalStore, sessions *sessionManager, profiles []*agentProfile) *approvalManager {
+	return &approvalManager{store: store, sessions: sessions, profiles: profiles, resuming: make(map[string]bool)}
+}
+
+// runOptions 新运行的图选项：暂停时 checkpoint 以 id 保存
+func (m *approvalManager) runOptions(id string) []compose.Option {
+	return []compose.Option{compose.WithCheckPointID(id)}
+}
+
+// resumeOptions 从 id 的 checkpoint 继续运行，并把审批结果写入 ReAct 图的状态；
+// ReAct 图作为子图嵌入时（如 plan_agent）外层图的状态原样保留
+func (m *approvalManager) resumeOptions(id string, decisions map[string]toolDecision) []compose.Option {
+	return []compose.Option{
+		compose.WithCheckPointID(id),
+		compose.WithStateModifier(func(_ context.Context, _ compose.NodePath, state any) error {
+			if s, ok := state.(*agentState); ok {
+				s.Decisions = decisions
+			}
+			return nil
+		}),
+	}
+}
+
+// interruptedAgentState 找到中断所在的 ReAct 图的状态，ReAct 图可能嵌在其他图中
+func interruptedAgentState(info *compose.InterruptInfo) (*agentState, bool) {
+	if s, ok := info.State.(*agentState); ok {
+		return s, true
+	}
+	for _, sub := range info.SubGraphs {
+		if s, ok := interruptedAgentState(sub); ok {
+			return s, true
+		}
+	}
+	return nil, false
+}
+
+// pause 判断 err 是否为等待审批的中断；是则根据中断时的图状态补全并保存待审批记录 a。
+// 返回的 error 为保存失败的原因
+func (m *approvalManager) pause(ctx context.Context, err error, profile *agentProfile, a *pendingApproval) (bool, error) {
+	info, ok := compose.ExtractInterruptInfo(err)
+	if !ok {
+		return false, nil
+	}
+	state, ok := interruptedAgentState(info)
+	if !ok || len(state.Output) == 0 {
+		return true, fmt.Errorf("unexpected interrupt state %T", info.State)
+	}
+	last := state.Output[len(state.Output)-1]
+	a.Agent = profile.Name
+	a.CreatedAt = time.Now()
+	a.ToolCalls = make([]pendingToolCall, 0, len(last.ToolCalls))
+	for _, tc := range last.ToolCalls {
+		a.ToolCalls = append(a.ToolCalls, pendingToolCall{
+			ID:               tc.ID,
+			Name:             tc.Function.Name,
+			Arguments:        tc.Function.Arguments,
+			RequiresApproval: profile.Config.RequiresApproval != nil && profile.Config.RequiresApproval(tc.Function.Name),
+		})
+	}
+	if err := m.store.SaveApproval(ctx, a); err != nil {
+		return true, err
+	}
+	// 待审批记录占用会话，本轮运行结束时的 release 不会让会话空出来
+	m.sessions.keep(a.SessionID)
+	return true, nil
+}
+
+// keepSessions 启动时让存储中已有的待审批记录重新占用各自的会话
+func (m *approvalManager) keepSessions(ctx context.Context) error {
+	list, err := m.store.ListApprovals(ctx)
+	if err != nil {
+		return err
+	}
+	for _, a := range list {
+		m.sessions.keep(a.SessionID)
+	}
+	return nil
+}
+
+// claim 标记审批正在处理，避免同一个运行被并发继续
+func (m *approvalManager) claim(id string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.resuming[id] {
+		return false
+	}
+	m.resuming[id] = true
+	return true
+}
+
+func (m *approvalManager) release(id string) {
+	m.mu.Lock()
+	delete(m.resuming, id)
+	m.mu.Unlock()
+}
+
+// discard 运行结束（或放弃）后删除待审批记录与 checkpoint；记录对会话的占用由调用方 release
+func (m *approvalManager) discard(ctx context.Context, id string) {
+	if err := m.store.DeleteApproval(ctx, id); err != nil {
+		log.Printf("delete approval %s error: %v", id, err)
+	}
+}
+
+// resume 在当前请求中继续运行，返回最终响应的状态码与响应体
+// 会话在暂停期间一直由待审批记录占用，继续运行沿用这次占用，结束时释放；再次暂停时由 pause 重新占用
+func (m *approvalManager) resume(ctx context.Context, a *pendingApproval, decisions map[string]toolDecision) (int, agentResponse) {
+	profile := findProfile(m.profiles, a.Agent)
+	if profile == nil {
+		m.discard(ctx, a.ID)
+		m.sessions.release(a.SessionID)
+		return http.StatusInternalServerError, agentResponse{SessionID: a.SessionID, Error: fmt.Sprintf("unknown agent %q", a.Agent)}
+	}
+	// 读取失败时记录保持待审批，可以重新提交
+	sess, err := m.sessions.reload(ctx, a.SessionID)
+	if err != nil {
+		return http.StatusInternalServerError, agentResponse{SessionID: a.SessionID, Error: err.Error()}
+	}
+	defer m.sessions.release(a.SessionID)
+
+	respMsgs, err := profile.Agent.Invoke(ctx, nil, m.resumeOptions(a.ID, decisions)...)
+	if paused, saveErr := m.pause(ctx, err, profile, a); paused {
+		if saveErr != nil {
+			return http.StatusInternalServerError, agentResponse{SessionID: a.SessionID, Error: saveErr.Error()}
+		}
+		return http.StatusAccepted, agentResponse{SessionID: a.SessionID, Approval: a}
+	}
+	m.discard(ctx, a.ID)
+	if err != nil {
+		return http.StatusInternalServerError, agentResponse{SessionID: a.SessionID, Error: err.Error()}
+	}
+
+	if err := m.sessions.saveTurn(ctx, sess, a.Input, respMsgs); err != nil {
+		log.Printf("save session %s error: %v", a.SessionID, err)
+	}
+	return http.StatusOK, agentResponse{Output: profile.Output(respMsgs), SessionID: a.SessionID, Plan: planFromMessages(respMsgs)}
+}
+
+// approvalsHandler GET /approvals 列出待审批的运行
+func approvalsHandler(m *approvalManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		list, err := m.store.ListApprovals(r.Context())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_ = json.NewEncoder(w).Encode(map[string]any{"approvals": list})
+	}
+}
+
+// approvalHandler GET /approvals/{id} 查看待审批记录，POST /approvals/{id} 提交审批结果并继续运行
+func approvalHandler(m *approvalManager, jobs *jobManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		id := r.PathValue("id")
+		if !approvalIDPattern.MatchString(id) {
+			http.Error(w, "invalid approval id", http.StatusBadRequest)
+			return
+		}
+		if r.Method != http.MethodGet && r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		a, err := m.store.GetApproval(ctx, id)
+		if errors.Is(err, errApprovalNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if r.Method == http.MethodGet {
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			_ = json.NewEncoder(w).Encode(a)
+			return
+		}
+
+		var req approvalRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		decisions, err := a.decisions(req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if !m.claim(id) {
+			http.Error(w, errApprovalBusy.Error(), http.StatusConflict)
+			return
+		}
+		defer m.release(id)
+
+		// 任务发起的运行回到任务队列继续，由 worker 记录结果
+		if a.JobID != "" {
+			j, err := jobs.resume(a.JobID, m.resumeOptions(id, decisions))
+			switch {
+			case errors.Is(err, errJobNotFound):
+				m.discard(ctx, id)
+				m.sessions.release(a.SessionID)
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			case errors.Is(err, errJobNotAwaiting):
+				http.Error(w, err.Error(), http.StatusConflict)
+				return
+			case err != nil:
+				http.Error(w, err.Error(), http.StatusServiceUnavailable)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.Header().Set("Location", "/jobs/"+a.JobID)
+			w.WriteHeader(http.StatusAccepted)
+			_ = json.NewEncoder(w).Encode(j.snapshot())
+			return
+		}
+
+		status, resp := m.resume(ctx, a, decisions)
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(resp)
+	}
+}
+
+func newApprovalID() string {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		log.Printf("generate approval id error: %v", err)
+	}
+	return "apr-" + hex.EncodeToString(b)
+}
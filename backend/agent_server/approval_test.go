@@ -0,0 +1,153 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/aistudiolabx/eino-demo/backend/agenttest"
+	"github.com/aistudiolabx/eino-demo/backend/mcp_server/mcptest"
+)
+
+// novelCall tool_agent 调用需要审批的 novel_to_script
+var novelCall = agenttest.CallTools(agenttest.ToolCall("call_1", "novel_to_script", `{"text":"从前有座山"}`))
+
+// runningHubCalled 桩服务是否收到了创建 RunningHub 任务的请求
+func runningHubCalled(s *testServer) bool {
+	return slices.ContainsFunc(s.MCP.Upstream.Requests(), func(r string) bool { return strings.Contains(r, "/runninghub/task/openapi/create") })
+}
+
+func TestApprovalApprove(t *testing.T) {
+	s := newTestServer(t, agenttest.NewFakeChatModel(novelCall), &mcptest.Upstream{Script: "第一幕：山。"})
+
+	var resp agentResponse
+	if status := s.do(t, http.MethodPost, "/tool_agent", agentRequest{Input: "转成剧本", SessionID: "s1"}, &resp); status != http.StatusAccepted {
+		t.Fatalf("status = %d, want 202; error = %s", status, resp.Error)
+	}
+	a := resp.Approval
+	if a == nil || len(a.ToolCalls) != 1 || !a.ToolCalls[0].RequiresApproval || a.Agent != "tool_agent" {
+		t.Fatalf("approval = %+v", a)
+	}
+	if runningHubCalled(s) {
+		t.Fatal("novel_to_script executed before approval")
+	}
+
+	var pending pendingApproval
+	if status := s.do(t, http.MethodGet, "/approvals/"+a.ID, nil, &pending); status != http.StatusOK || pending.ID != a.ID {
+		t.Fatalf("get approval status = %d, approval = %+v", status, pending)
+	}
+
+	resp = agentResponse{}
+	if status := s.do(t, http.MethodPost, "/approvals/"+a.ID, approvalRequest{Action: approvalApprove}, &resp); status != http.StatusOK {
+		t.Fatalf("approve status = %d, error = %s", status, resp.Error)
+	}
+	if resp.Output != "第一幕：山。" {
+		t.Errorf("output = %q", resp.Output)
+	}
+	if !runningHubCalled(s) {
+		t.Error("novel_to_script not executed after approval")
+	}
+	if status := s.do(t, http.MethodGet, "/approvals/"+a.ID, nil, nil); status != http.StatusNotFound {
+		t.Errorf("approval still exists after resume, status = %d", status)
+	}
+
+	var sess Session
+	s.do(t, http.MethodGet, "/sessions/s1", nil, &sess)
+	if len(sess.Messages) != 3 || sess.Messages[0].Content != "转成剧本" {
+		t.Errorf("session messages = %v", sess.Messages)
+	}
+}
+
+func TestApprovalReject(t *testing.T) {
+	s := newTestServer(t, agenttest.NewFakeChatModel(novelCall), nil)
+
+	var resp agentResponse
+	if status := s.do(t, http.MethodPost, "/tool_agent", agentRequest{Input: "转成剧本"}, &resp); status != http.StatusAccepted {
+		t.Fatalf("status = %d, want 202; error = %s", status, resp.Error)
+	}
+	a := resp.Approval
+
+	if status := s.do(t, http.MethodPost, "/approvals/"+a.ID, approvalRequest{}, nil); status != http.StatusBadRequest {
+		t.Errorf("missing decision status = %d, want 400", status)
+	}
+
+	// tool_agent 直接返回工具结果，被拒绝时返回的是拒绝说明
+	resp = agentResponse{}
+	req := approvalRequest{Decisions: []approvalDecision{{ToolCallID: "call_1", Action: approvalReject, Reason: "太贵"}}}
+	if status := s.do(t, http.MethodPost, "/approvals/"+a.ID, req, &resp); status != http.StatusOK {
+		t.Fatalf("reject status = %d, error = %s", status, resp.Error)
+	}
+	if !strings.Contains(resp.Output, "novel_to_script") || !strings.Contains(resp.Output, "太贵") {
+		t.Errorf("output = %q, want the rejection notice", resp.Output)
+	}
+	if runningHubCalled(s) {
+		t.Error("rejected novel_to_script was executed")
+	}
+}
+
+// 待审批期间会话保持进行中：同一会话的新一轮返回 409，审批后的运行结束后才能继续对话，
+// 避免新一轮先写入会话、审批后的运行再基于旧历史追加
+func TestApprovalKeepsSessionBusy(t *testing.T) {
+	cm := agenttest.NewFakeChatModel(novelCall, agenttest.Reply("在的"))
+	s := newTestServer(t, cm, &mcptest.Upstream{Script: "第一幕：山。"})
+
+	var resp agentResponse
+	if status := s.do(t, http.MethodPost, "/tool_agent", agentRequest{Input: "转成剧本", SessionID: "s1"}, &resp); status != http.StatusAccepted {
+		t.Fatalf("status = %d, want 202; error = %s", status, resp.Error)
+	}
+	a := resp.Approval
+
+	turn := agentRequest{Input: "在吗", SessionID: "s1"}
+	for path, body := range map[string]any{
+		"/agent":        turn,
+		"/tool_agent":   turn,
+		"/agent/stream": turn,
+		"/jobs":         jobRequest{agentRequest: turn, Agent: "agent"},
+	} {
+		if status := s.do(t, http.MethodPost, path, body, nil); status != http.StatusConflict {
+			t.Errorf("%s while awaiting approval status = %d, want 409", path, status)
+		}
+	}
+	// 其他会话不受影响
+	if status := s.do(t, http.MethodPost, "/agent", agentRequest{Input: "在吗", SessionID: "s2"}, nil); status != http.StatusOK {
+		t.Errorf("other session status = %d, want 200", status)
+	}
+
+	if status := s.do(t, http.MethodPost, "/approvals/"+a.ID, approvalRequest{Action: approvalApprove}, nil); status != http.StatusOK {
+		t.Fatalf("approve status = %d", status)
+	}
+	var sess Session
+	s.do(t, http.MethodGet, "/sessions/s1", nil, &sess)
+	if len(sess.Messages) != 3 || sess.Messages[0].Content != "转成剧本" {
+		t.Errorf("session messages = %v", sess.Messages)
+	}
+	if _, err := s.Sessions.load(t.Context(), "s1"); err != nil {
+		t.Fatalf("session still busy after approval: %v", err)
+	}
+	s.Sessions.release("s1")
+}
+
+// 取消等待审批的任务时放弃待审批记录并释放会话
+func TestApprovalDiscardReleasesSession(t *testing.T) {
+	s := newTestServer(t, agenttest.NewFakeChatModel(novelCall), nil)
+
+	var v jobView
+	req := jobRequest{agentRequest: agentRequest{Input: "转成剧本", SessionID: "s1"}, Agent: "tool_agent"}
+	s.do(t, http.MethodPost, "/jobs", req, &v)
+	v = s.waitJob(t, v.ID, func(v jobView) bool { return v.Status == jobAwaitingApproval })
+	if status := s.do(t, http.MethodPost, "/agent", agentRequest{Input: "在吗", SessionID: "s1"}, nil); status != http.StatusConflict {
+		t.Errorf("turn while awaiting approval status = %d, want 409", status)
+	}
+
+	if status := s.do(t, http.MethodDelete, "/jobs/"+v.ID, nil, nil); status != http.StatusOK {
+		t.Fatalf("cancel status = %d", status)
+	}
+	if status := s.do(t, http.MethodGet, "/approvals/"+v.Approval.ID, nil, nil); status != http.StatusNotFound {
+		t.Errorf("approval still exists after cancel, status = %d", status)
+	}
+	if _, err := s.Sessions.load(t.Context(), "s1"); err != nil {
+		t.Fatalf("session still busy after cancel: %v", err)
+	}
+	s.Sessions.release("s1")
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/eino/callbacks"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/compose"
+	"github.com/cloudwego/eino/schema"
+	callbackutils "github.com/cloudwego/eino/utils/callbacks"
+)
+
+// 请求体 "verbose": true 时，/agent、/tool_agent 的响应与 /agent/stream 的 done 事件附带 trace 字段：
+//
+//	{
+//	  "messages": [...],          本次运行产生的全部消息（assistant 与 tool），格式同 schema.Message
+//	  "steps": [                  每次模型调用与工具调用，按开始时间排序
+//	    {"type": "model", "name": "Ollama", "started_at": "...", "duration_ms": 812.5,
+//	     "finish_reason": "tool_calls", "usage": {...}, "tool_calls": [{"id", "name", "arguments"}]},
+//	    {"type": "tool", "name": "weather", "started_at": "...", "duration_ms": 120.3,
+//	     "tool_call_id": "...", "arguments": "{\"city\":\"Shanghai\"}", "result": "<工具原始输出>"}
+//	  ],
+//	  "finish_reason": "stop",    最后一次模型调用的结束原因
+//	  "usage": {...},             token 用量合计，模型未返回用量时为估算值
+//	  "duration_ms": 1032.8
+//	}
+//
+// 运行失败时 trace 仍会返回已完成的步骤，失败的步骤带 error 字段。
+
+// 步骤类型
+const (
+	traceStepModel = "model"
+	traceStepTool  = "tool"
+)
+
+// executionTrace 一次 agent 运行的完整过程
+type executionTrace struct {
+	Messages     []*schema.Message `json:"messages"`
+	Steps        []*traceStep      `json:"steps"`
+	FinishReason string            `json:"finish_reason,omitempty"`
+	Usage        *chatUsage        `json:"usage"`
+	DurationMs   float64           `json:"duration_ms"`
+}
+
+// traceStep 一次模型调用或工具调用
+type traceStep struct {
+	Type       string    `json:"type"`
+	Name       string    `json:"name"`
+	StartedAt  time.Time `json:"started_at"`
+	DurationMs float64   `json:"duration_ms"`
+	Error      string    `json:"error,omitempty"`
+
+	// 模型调用
+	FinishReason string            `json:"finish_reason,omitempty"`
+	Usage        *chatUsage        `json:"usage,omitempty"`
+	ToolCalls    []streamToolEvent `json:"tool_calls,omitempty"`
+
+	// 工具调用，Result 为工具的原始输出（不做 content 字段提取）
+	ToolCallID string `json:"tool_call_id,omitempty"`
+	Arguments  string `json:"arguments,omitempty"`
+	Result     string `json:"result,omitempty"`
+}
+
+type traceStepKey struct{}
+
+// traceRecorder 通过单次运行的回调记录每个步骤；回调可能并发触发（并行执行的工具），所有写入都加锁
+type traceRecorder struct {
+	start time.Time
+
+	mu    sync.Mutex
+	steps []*traceStep
+	// wg 等待异步读取的流式输出结束
+	wg sync.WaitGroup
+}
+
+func newTraceRecorder() *traceRecorder {
+	return &traceRecorder{start: time.Now()}
+}
+
+// options 返回运行时需要附加的图选项；未开启 verbose（r 为 nil）时返回 nil
+func (r *traceRecorder) options() []compose.Option {
+	if r == nil {
+		return nil
+	}
+	return []compose.Option{compose.WithCallbacks(r.handler())}
+}
+
+// begin 记录步骤开始，并把步骤放进 ctx 供结束回调取用
+func (r *traceRecorder) begin(ctx context.Context, step *traceStep) context.Context {
+	step.StartedAt = time.Now()
+	r.mu.Lock()
+	r.steps = append(r.steps, step)
+	r.mu.Unlock()
+	return context.WithValue(ctx, traceStepKey{}, step)
+}
+
+// end 在锁内补全步骤的结果与耗时
+func (r *traceRecorder) end(ctx context.Context, err error, fill func(step *traceStep)) {
+	step, ok := ctx.Value(traceStepKey{}).(*traceStep)
+	if !ok {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	step.DurationMs = durationMs(time.Since(step.StartedAt))
+	if err != nil {
+		step.Error = err.Error()
+	}
+	if fill != nil {
+		fill(step)
+	}
+}
+
+func (r *traceRecorder) handler() callbacks.Handler {
+	modelEnd := func(step *traceStep, msg *schema.Message, usage *model.TokenUsage) {
+		if usage != nil {
+			step.Usage = &chatUsage{PromptTokens: usage.PromptTokens, CompletionTokens: usage.CompletionTokens, TotalTokens: usage.TotalTokens}
+		}
+		if msg == nil {
+			return
+		}
+		if msg.ResponseMeta != nil {
+			step.FinishReason = msg.ResponseMeta.FinishReason
+		}
+		for _, tc := range msg.ToolCalls {
+			step.ToolCalls = append(step.ToolCalls, streamToolEvent{ID: tc.ID, Name: tc.Function.Name, Arguments: tc.Function.Arguments})
+		}
+	}
+
+	modelHandler := &callbackutils.ModelCallbackHandler{
+		OnStart: func(ctx context.Context, info *callbacks.RunInfo, _ *model.CallbackInput) context.Context {
+			return r.begin(ctx, &traceStep{Type: traceStepModel, Name: info.Type})
+		},
+		OnEnd: func(ctx context.Context, _ *callbacks.RunInfo, output *model.CallbackOutput) context.Context {
+			r.end(ctx, nil, func(step *traceStep) { modelEnd(step, output.Message, callbackUsage(output)) })
+			return ctx
+		},
+		OnEndWithStreamOutput: func(ctx context.Context, _ *callbacks.RunInfo, output *schema.StreamReader[*model.CallbackOutput]) context.Context {
+			r.wg.Add(1)
+			go func() {
+				defer r.wg.Done()
+				defer output.Close()
+				var chunks []*schema.Message
+				var usage *model.TokenUsage
+				for {
+					chunk, err := output.Recv()
+					if errors.Is(err, io.EOF) {
+						break
+					}
+					if err != nil {
+						r.end(ctx, err, nil)
+						return
+					}
+					if chunk.Message != nil {
+						chunks = append(chunks, chunk.Message)
+					}
+					if u := callbackUsage(chunk); u != nil {
+						usage = u
+					}
+				}
+				msg, err := schema.ConcatMessages(chunks)
+				r.end(ctx, nil, func(step *traceStep) {
+					if err != nil {
+						msg = nil
+					}
+					modelEnd(step, msg, usage)
+				})
+			}()
+			return ctx
+		},
+		OnError: func(ctx context.Context, _ *callbacks.RunInfo, err error) context.Context {
+			r.end(ctx, err, nil)
+			return ctx
+		},
+	}
+
+	toolHandler := &callbackutils.ToolCallbackHandler{
+		OnStart: func(ctx context.Context, info *callbacks.RunInfo, input *tool.CallbackInput) context.Context {
+			return r.begin(ctx, &traceStep{
+				Type:       traceStepTool,
+				Name:       info.Name,
+				ToolCallID: compose.GetToolCallID(ctx),
+				Arguments:  input.ArgumentsInJSON,
+			})
+		},
+		OnEnd: func(ctx context.Context, _ *callbacks.RunInfo, output *tool.CallbackOutput) context.Context {
+			r.end(ctx, nil, func(step *traceStep) { step.Result = output.Response })
+			return ctx
+		},
+		OnEndWithStreamOutput: func(ctx context.Context, _ *callbacks.RunInfo, output *schema.StreamReader[*tool.CallbackOutput]) context.Context {
+			r.wg.Add(1)
+			go func() {
+				defer r.wg.Done()
+				defer output.Close()
+				var result string
+				for {
+					chunk, err := output.Recv()
+					if errors.Is(err, io.EOF) {
+						break
+					}
+					if err != nil {
+						r.end(ctx, err, func(step *traceStep) { step.Result = result })
+						return
+					}
+					result += chunk.Response
+				}
+				r.end(ctx, nil, func(step *traceStep) { step.Result = result })
+			}()
+			return ctx
+		},
+		OnError: func(ctx context.Context, _ *callbacks.RunInfo, err error) context.Context {
+			r.end(ctx, err, nil)
+			return ctx
+		},
+	}
+
+	return callbackutils.NewHandlerHelper().ChatModel(modelHandler).Tool(toolHandler).Handler()
+}
+
+// trace 等待流式输出读完后汇总本次运行；input 为发送给 agent 的消息，output 为 agent 产生的消息（运行失败时可为空）。
+// r 为 nil（未开启 verbose）时返回 nil
+func (r *traceRecorder) trace(input, output []*schema.Message) *executionTrace {
+	if r == nil {
+		return nil
+	}
+	r.wg.Wait()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t := &executionTrace{
+		Messages:   output,
+		Steps:      r.steps,
+		Usage:      usageOf(input, output),
+		DurationMs: durationMs(time.Since(r.start)),
+	}
+	if t.Messages == nil {
+		t.Messages = []*schema.Message{}
+	}
+	if t.Steps == nil {
+		t.Steps = []*traceStep{}
+	}
+	for i := len(r.steps) - 1; i >= 0; i-- {
+		if r.steps[i].Type == traceStepModel {
+			t.FinishReason = r.steps[i].FinishReason
+			break
+		}
+	}
+	return t
+}
+
+func durationMs(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
@@ -2,39 +2,64 @@ package main
 
 import (
 	"encoding/json"
+	"fmt"
+	"log"
 	"net/http"
 	"strings"
 
-	"github.com/cloudwego/eino/compose"
 	"github.com/cloudwego/eino/schema"
 )
 
+// withCORS 为接口加上 CORS 头并处理预检请求，允许前端跨域访问
+func withCORS(origin, methods string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Set("Access-Control-Allow-Methods", methods)
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, traceparent")
+		w.Header().Set("Access-Control-Expose-Headers", traceIDHeader)
+
+		// 预检请求
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next(w, r)
+	}
+}
+
 func healthzHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	_, _ = w.Write([]byte("ok"))
 }
 
-func agentHandler(agent compose.Runnable[[]*schema.Message, []*schema.Message]) http.HandlerFunc {
+// agentHandler /agent、/tool_agent、/plan_agent：系统提示由 profile 的默认模板渲染（请求可通过 prompt 字段改选其他模板），
+// 回复由 profile.Output 取出（tool_agent 取工具结果，见 toolAgentOutput）
+func agentHandler(profile *agentProfile, sessions *sessionManager, prompts *promptStore, approvals *approvalManager) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		ctx := r.Context()
-
-		// CORS 头
-		w.Header().Set("Access-Control-Allow-Origin", "http://localhost:8081")
-		w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-
-		// 预检请求
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusNoContent)
+		req, ok := decodeAgentRequest(w, r)
+		if !ok {
 			return
 		}
+		serveAgent(w, r, req, profile, nil, sessions, prompts, approvals)
+	}
+}
 
+// chatRequest POST /chat 请求体
+type chatRequest struct {
+	agentRequest
+	// Agent 可选，直接指定子 agent，跳过意图判断
+	Agent string `json:"agent,omitempty"`
+}
+
+// chatHandler /chat：统一入口，由 router 判断意图后交给对应的子 agent，响应的 route 字段说明选择结果
+func chatHandler(router *agentRouter, sessions *sessionManager, prompts *promptStore, approvals *approvalManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
 			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
 
-		var req agentRequest
+		var req chatRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			http.Error(w, "bad request", http.StatusBadRequest)
 			return
@@ -45,104 +70,136 @@ func agentHandler(agent compose.Runnable[[]*schema.Message, []*schema.Message])
 			return
 		}
 
-		// 系统提示：要求模型在用户请求天气或小说转剧本时必须调用对应工具，避免只返回纯文本导致 Tools 节点报错
-		systemPrompt := `你是一个具备工具调用能力的助手。请根据用户意图调用对应工具，不要仅用文字回复。
-
-- 当用户询问某地天气、城市天气时，你必须调用 weather 工具，参数 city 填城市名（如 Beijing、上海）。
-- 当用户要求将小说转成剧本、或提供小说正文要转换时，你必须调用 novel_to_script 工具，参数 text 填小说正文或用户提供的文本，可选参数 seed 可填数字字符串。
-- 以上场景下必须先调用工具，再根据工具返回结果组织回复；不要不调用工具而直接文字回答。
-- 特别地，当 novel_to_script 工具返回后，你的最终回复只输出工具返回的剧本文本内容本身，不要加任何总结、开场白、结束语或链接说明（例如不要写 "Great! Here is the script:" 或 "You can download..." 等），只输出剧本正文。`
-
-		msgs := []*schema.Message{
-			schema.SystemMessage(systemPrompt),
-			{
-				Role:    schema.User,
-				Content: req.Input,
-			},
+		var sub *subAgent
+		var route routeDecision
+		if req.Agent != "" {
+			if sub = router.find(req.Agent); sub == nil {
+				http.Error(w, fmt.Sprintf("unknown agent %q", req.Agent), http.StatusBadRequest)
+				return
+			}
+			route = routeDecision{Agent: sub.Profile.Name, By: routeByRequest}
+			routeDecisions.Inc(route.Agent, route.By)
+		} else {
+			sub, route = router.route(r.Context(), req.Input)
 		}
+		serveAgent(w, r, req.agentRequest, sub.Profile, &route, sessions, prompts, approvals)
+	}
+}
 
-		respMsgs, err := agent.Invoke(ctx, msgs)
-		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			_ = json.NewEncoder(w).Encode(agentResponse{Error: err.Error()})
-			return
-		}
+// decodeAgentRequest 解析并校验请求体，失败时已写出错误响应
+func decodeAgentRequest(w http.ResponseWriter, r *http.Request) (agentRequest, bool) {
+	var req agentRequest
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return req, false
+	}
 
-		// 优先取 assistant 的回复；如果没有，则退回最后一条消息的内容（通常包含 tool 结果）
-		var out string
-		for _, m := range respMsgs {
-			if m.Role == schema.Assistant {
-				out = m.Content
-				break
-			}
-		}
-		if out == "" && len(respMsgs) > 0 {
-			out = respMsgs[len(respMsgs)-1].Content
-		}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return req, false
+	}
 
-		w.Header().Set("Content-Type", "application/json; charset=utf-8")
-		_ = json.NewEncoder(w).Encode(agentResponse{Output: out})
+	if req.Input == "" {
+		http.Error(w, "input is required", http.StatusBadRequest)
+		return req, false
 	}
+	return req, true
 }
 
-// toolAgentHandler 专门处理 ToolAgent 的响应，从 JSON 格式中提取 content 字段
-func toolAgentHandler(agent compose.Runnable[[]*schema.Message, []*schema.Message]) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		ctx := r.Context()
-
-		// CORS 头
-		w.Header().Set("Access-Control-Allow-Origin", "http://localhost:8081")
-		w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+// serveAgent 以 profile 同步运行一轮对话并写出响应：加载会话、渲染系统提示、运行图，
+// 需要审批时返回 202，完成后写回会话并用 profile.Output 提取最终回复；route 非 nil 时随响应返回
+func serveAgent(w http.ResponseWriter, r *http.Request, req agentRequest, profile *agentProfile, route *routeDecision,
+	sessions *sessionManager, prompts *promptStore, approvals *approvalManager) {
+	ctx := r.Context()
 
-		// 预检请求
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusNoContent)
-			return
-		}
+	sess, err := sessions.load(ctx, req.SessionID)
+	if err != nil {
+		http.Error(w, err.Error(), sessionErrorStatus(err))
+		return
+	}
+	defer sessions.release(req.SessionID)
+	systemPrompt, err := prompts.systemPrompt(r, req, profile)
+	if err != nil {
+		http.Error(w, err.Error(), promptErrorStatus(err))
+		return
+	}
+	msgs := sessions.buildMessages(systemPrompt, sess, req.Input)
 
-		if r.Method != http.MethodPost {
-			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
-			return
-		}
+	var rec *traceRecorder
+	if req.Verbose {
+		rec = newTraceRecorder()
+	}
+	approvalID := newApprovalID()
+	respMsgs, err := profile.Agent.Invoke(ctx, msgs, append(rec.options(), approvals.runOptions(approvalID)...)...)
+	a := &pendingApproval{ID: approvalID, SessionID: req.SessionID, Input: req.Input}
+	if paused, saveErr := approvals.pause(ctx, err, profile, a); paused {
+		writeApprovalRequired(w, a, saveErr, agentResponse{Trace: rec.trace(msgs, respMsgs), Route: route})
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		_ = json.NewEncoder(w).Encode(agentResponse{Error: err.Error(), Trace: rec.trace(msgs, respMsgs), Route: route})
+		return
+	}
 
-		var req agentRequest
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "bad request", http.StatusBadRequest)
-			return
-		}
+	if err := sessions.saveTurn(ctx, sess, req.Input, respMsgs); err != nil {
+		log.Printf("save session %s error: %v", req.SessionID, err)
+	}
 
-		if req.Input == "" {
-			http.Error(w, "input is required", http.StatusBadRequest)
-			return
-		}
+	out := profile.Output(respMsgs)
 
-		msgs := []*schema.Message{
-			{
-				Role:    schema.User,
-				Content: req.Input,
-			},
-		}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	_ = json.NewEncoder(w).Encode(agentResponse{
+		Output:    out,
+		SessionID: req.SessionID,
+		Trace:     rec.trace(msgs, respMsgs),
+		Route:     route,
+		Plan:      planFromMessages(respMsgs),
+	})
+}
 
-		respMsgs, err := agent.Invoke(ctx, msgs)
-		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			_ = json.NewEncoder(w).Encode(agentResponse{Error: err.Error()})
-			return
-		}
+// writeApprovalRequired 运行暂停等待审批时返回 202 与待审批记录；saveErr 非空表示记录保存失败，运行无法继续。
+// resp 中已填好的 Trace、Route 等字段原样返回
+func writeApprovalRequired(w http.ResponseWriter, a *pendingApproval, saveErr error, resp agentResponse) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	resp.SessionID = a.SessionID
+	if saveErr != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		resp.Error = saveErr.Error()
+		_ = json.NewEncoder(w).Encode(resp)
+		return
+	}
+	w.Header().Set("Location", "/approvals/"+a.ID)
+	w.WriteHeader(http.StatusAccepted)
+	resp.Approval = a
+	_ = json.NewEncoder(w).Encode(resp)
+}
 
-		// 获取最后一条消息的内容（ToolAgent 返回的是工具结果）
-		var out string
-		if len(respMsgs) > 0 {
-			out = respMsgs[len(respMsgs)-1].Content
+// finalAnswer 取最后一条不含 tool call 的 assistant 回复（即 ReAct 循环的最终回答）；
+// 如果没有，则退回最后一条消息的内容（通常包含 tool 结果）
+func finalAnswer(msgs []*schema.Message) string {
+	for i := len(msgs) - 1; i >= 0; i-- {
+		if m := msgs[i]; m.Role == schema.Assistant && len(m.ToolCalls) == 0 {
+			return m.Content
 		}
+	}
+	if len(msgs) > 0 {
+		return msgs[len(msgs)-1].Content
+	}
+	return ""
+}
 
-		// 尝试解析 JSON 格式，提取 content 字段
-		extractedContent := extractContentFromJSON(out)
-
-		w.Header().Set("Content-Type", "application/json; charset=utf-8")
-		_ = json.NewEncoder(w).Encode(agentResponse{Output: extractedContent})
+// toolAgentOutput ToolAgent 的输出：调用了工具时最后一条是 tool 消息（工具结果），尝试解析 JSON 格式提取 content 字段；
+// 模型未调用工具时最后一条是 assistant 的文字回复，原样返回
+func toolAgentOutput(msgs []*schema.Message) string {
+	if len(msgs) == 0 {
+		return ""
+	}
+	last := msgs[len(msgs)-1]
+	if last.Role == schema.Tool {
+		return extractContentFromJSON(last.Content)
 	}
+	return last.Content
 }
 
 // extractContentFromJSON 从 JSON 格式中提取 content 字段的文本内容
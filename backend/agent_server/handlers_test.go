@@ -0,0 +1,149 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aistudiolabx/eino-demo/backend/agenttest"
+	"github.com/cloudwego/eino/schema"
+)
+
+func TestAgentEndpointSession(t *testing.T) {
+	cm := agenttest.NewFakeChatModel(
+		agenttest.CallTools(agenttest.ToolCall("call_1", "weather", `{"city":"Beijing"}`)),
+		agenttest.Reply("北京今天晴。"),
+		agenttest.Reply("明天也不错。"),
+	)
+	s := newTestServer(t, cm, nil)
+
+	var resp agentResponse
+	if status := s.do(t, http.MethodPost, "/agent", agentRequest{Input: "北京天气怎么样", SessionID: "s1"}, &resp); status != http.StatusOK {
+		t.Fatalf("status = %d, error = %s", status, resp.Error)
+	}
+	if resp.Output != "北京今天晴。" || resp.SessionID != "s1" {
+		t.Errorf("response = %+v", resp)
+	}
+
+	resp = agentResponse{}
+	if status := s.do(t, http.MethodPost, "/agent", agentRequest{Input: "明天呢", SessionID: "s1"}, &resp); status != http.StatusOK {
+		t.Fatalf("status = %d, error = %s", status, resp.Error)
+	}
+	if resp.Output != "明天也不错。" {
+		t.Errorf("output = %q", resp.Output)
+	}
+
+	// 第二轮的模型输入包含系统提示、第一轮的完整历史（含工具调用）与本轮输入
+	calls := cm.Calls()
+	in := calls[len(calls)-1]
+	if in[0].Role != schema.System {
+		t.Errorf("first message role = %s, want system", in[0].Role)
+	}
+	var roles []string
+	for _, m := range in[1:] {
+		roles = append(roles, string(m.Role))
+	}
+	if got := strings.Join(roles, ","); got != "user,assistant,tool,assistant,user" {
+		t.Errorf("history roles = %s", got)
+	}
+
+	var sess Session
+	if status := s.do(t, http.MethodGet, "/sessions/s1", nil, &sess); status != http.StatusOK {
+		t.Fatalf("get session status = %d", status)
+	}
+	if len(sess.Messages) != 6 {
+		t.Errorf("session has %d messages, want 6", len(sess.Messages))
+	}
+}
+
+func TestAgentEndpointRejectsBusySession(t *testing.T) {
+	started := make(chan struct{})
+	unblock := make(chan struct{})
+	cm := agenttest.NewFakeChatModel(agenttest.Turn{Respond: func([]*schema.Message) (*schema.Message, error) {
+		close(started)
+		<-unblock
+		return schema.AssistantMessage("好的。", nil), nil
+	}})
+	s := newTestServer(t, cm, nil)
+
+	done := make(chan int)
+	go func() {
+		var resp agentResponse
+		done <- s.do(t, http.MethodPost, "/agent", agentRequest{Input: "你好", SessionID: "s1"}, &resp)
+	}()
+	<-started
+	if status := s.do(t, http.MethodPost, "/agent", agentRequest{Input: "在吗", SessionID: "s1"}, nil); status != http.StatusConflict {
+		t.Errorf("concurrent turn status = %d, want 409", status)
+	}
+	close(unblock)
+	if status := <-done; status != http.StatusOK {
+		t.Errorf("first turn status = %d", status)
+	}
+
+	var sess Session
+	s.do(t, http.MethodGet, "/sessions/s1", nil, &sess)
+	if len(sess.Messages) != 2 {
+		t.Errorf("session has %d messages, want 2", len(sess.Messages))
+	}
+}
+
+func TestChatRoutes(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		req     chatRequest
+		agent   string
+		by      string
+		turns   []agenttest.Turn
+		wantOut string
+	}{
+		{
+			name:    "keywords",
+			req:     chatRequest{agentRequest: agentRequest{Input: "上海天气怎么样"}},
+			agent:   "agent",
+			by:      routeByRules,
+			turns:   []agenttest.Turn{agenttest.Reply("上海多云。")},
+			wantOut: "上海多云。",
+		},
+		{
+			name:  "llm",
+			req:   chatRequest{agentRequest: agentRequest{Input: "帮我改写这段文字"}},
+			agent: "tool_agent",
+			by:    routeByLLM,
+			// 第一次调用是意图判断，第二次由 tool_agent 处理
+			turns:   []agenttest.Turn{agenttest.Reply("tool_agent"), agenttest.Reply("请提供小说正文。")},
+			wantOut: "请提供小说正文。",
+		},
+		{
+			name:    "explicit",
+			req:     chatRequest{agentRequest: agentRequest{Input: "天气"}, Agent: "tool_agent"},
+			agent:   "tool_agent",
+			by:      routeByRequest,
+			turns:   []agenttest.Turn{agenttest.Reply("请提供小说正文。")},
+			wantOut: "请提供小说正文。",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cm := agenttest.NewFakeChatModel(tc.turns...)
+			s := newTestServer(t, cm, nil)
+
+			var resp agentResponse
+			if status := s.do(t, http.MethodPost, "/chat", tc.req, &resp); status != http.StatusOK {
+				t.Fatalf("status = %d, error = %s", status, resp.Error)
+			}
+			if resp.Route == nil || resp.Route.Agent != tc.agent || resp.Route.By != tc.by {
+				t.Errorf("route = %+v, want %s by %s", resp.Route, tc.agent, tc.by)
+			}
+			if resp.Output != tc.wantOut {
+				t.Errorf("output = %q, want %q", resp.Output, tc.wantOut)
+			}
+			if cm.Remaining() != 0 {
+				t.Errorf("%d scripted turns left", cm.Remaining())
+			}
+		})
+	}
+
+	s := newTestServer(t, agenttest.NewFakeChatModel(), nil)
+	if status := s.do(t, http.MethodPost, "/chat", chatRequest{agentRequest: agentRequest{Input: "hi"}, Agent: "nope"}, nil); status != http.StatusBadRequest {
+		t.Errorf("unknown agent status = %d, want 400", status)
+	}
+}
@@ -0,0 +1,501 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/aistudiolabx/eino-demo/backend/config"
+	"github.com/cloudwego/eino/compose"
+	"github.com/cloudwego/eino/schema"
+)
+
+// 异步任务接口，适合小说转剧本等可能运行数分钟、会被代理超时中断的调用：
+//
+//	POST   /jobs       请求体同 /agent，另需 "agent" 指定 profile（agent 或 tool_agent），返回 202 与任务状态
+//	GET    /jobs/{id}  查询状态、进度与结果
+//	DELETE /jobs/{id}  取消任务：排队中与等待审批的任务直接取消，运行中的任务取消 ctx（经 MCP 传到 RunningHub 轮询）
+//
+// 任务状态：queued → running → succeeded | failed | canceled。
+// 调用需要审批的工具前任务进入 awaiting_approval，approval 为待审批记录（见 approval.go），
+// 审批后任务重新排队（queued → running）并从暂停处继续。
+// events 记录工具调用（tool_start、tool_end、tool_error，结构同 /agent/stream 的事件），
+// progress 为 MCP Server 最近一次回报的进度。任务只保存在内存中，服务重启后丢失。
+
+type jobStatus string
+
+const (
+	jobQueued    jobStatus = "queued"
+	jobRunning   jobStatus = "running"
+	jobSucceeded jobStatus = "succeeded"
+	jobFailed    jobStatus = "failed"
+	jobCanceled  jobStatus = "canceled"
+	// jobAwaitingApproval 运行已暂停，等待 POST /approvals/{id}
+	jobAwaitingApproval jobStatus = "awaiting_approval"
+)
+
+// maxJobEvents 单个任务保留的事件数上限，超出后丢弃最早的事件
+const maxJobEvents = 200
+
+var (
+	errJobNotFound  = errors.New("job not found")
+	errJobQueueFull = errors.New("job queue is full")
+	errJobFinished  = errors.New("job already finished")
+	// errJobNotAwaiting 任务不在等待审批（已审批或已取消）
+	errJobNotAwaiting = errors.New("job is not awaiting approval")
+	// errJobCanceled 作为取消原因传给任务的 ctx，用于区分取消与超时、其他错误
+	errJobCanceled = errors.New("job canceled")
+)
+
+var jobIDPattern = regexp.MustCompile(`^job-[0-9a-f]{24}$`)
+
+// jobRequest POST /jobs 请求体
+type jobRequest struct {
+	agentRequest
+	// Agent 执行任务的 profile 名
+	Agent string `json:"agent"`
+}
+
+// jobEvent 任务运行中的一个事件
+type jobEvent struct {
+	Time  time.Time `json:"time"`
+	Event string    `json:"event"`
+	Data  any       `json:"data"`
+}
+
+// jobView 任务状态的快照，即 GET /jobs/{id} 的响应
+type jobView struct {
+	ID         string        `json:"id"`
+	Agent      string        `json:"agent"`
+	Status     jobStatus     `json:"status"`
+	SessionID  string        `json:"session_id,omitempty"`
+	CreatedAt  time.Time     `json:"created_at"`
+	StartedAt  *time.Time    `json:"started_at,omitempty"`
+	FinishedAt *time.Time    `json:"finished_at,omitempty"`
+	Progress   *toolProgress `json:"progress,omitempty"`
+	// Approval 状态为 awaiting_approval 时的待审批记录
+	Approval *pendingApproval `json:"approval,omitempty"`
+	Events   []jobEvent       `json:"events"`
+	Output   string           `json:"output,omitempty"`
+	// Plan plan_agent 任务的执行计划
+	Plan  *executionPlan  `json:"plan,omitempty"`
+	Error string          `json:"error,omitempty"`
+	Trace *executionTrace `json:"trace,omitempty"`
+}
+
+// job 一个异步任务；view 中的字段由 mu 保护
+type job struct {
+	profile *agentProfile
+	input   string
+	msgs    []*schema.Message
+	session *Session
+	verbose bool
+	// baseCtx 保留提交请求的 ctx 中的值（如 trace ID），但不随请求结束而取消
+	baseCtx context.Context
+	// approvalID 运行暂停时 checkpoint 与待审批记录的 ID
+	approvalID string
+	// resume 非空时从 checkpoint 继续运行（审批后重新排队的任务）
+	resume []compose.Option
+
+	mu     sync.Mutex
+	view   jobView
+	cancel context.CancelCauseFunc
+}
+
+func (j *job) snapshot() jobView {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	v := j.view
+	v.Events = append([]jobEvent{}, j.view.Events...)
+	return v
+}
+
+// emit 记录工具调用事件，供 newStreamCallbacks 使用
+func (j *job) emit(event string, data any) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.view.Events = append(j.view.Events, jobEvent{Time: time.Now(), Event: event, Data: data})
+	if n := len(j.view.Events); n > maxJobEvents {
+		j.view.Events = append([]jobEvent(nil), j.view.Events[n-maxJobEvents:]...)
+	}
+}
+
+func (j *job) progress(p toolProgress) {
+	j.mu.Lock()
+	j.view.Progress = &p
+	j.mu.Unlock()
+}
+
+func (j *job) finished() bool {
+	switch j.view.Status {
+	case jobSucceeded, jobFailed, jobCanceled:
+		return true
+	}
+	return false
+}
+
+// jobManager 保存任务并用固定数量的 worker 从有界队列中取任务执行
+type jobManager struct {
+	cfg       config.JobsConfig
+	sessions  *sessionManager
+	approvals *approvalManager
+	queue     chan *job
+
+	mu   sync.Mutex
+	jobs map[string]*job
+}
+
+func newJobManager(cfg config.JobsConfig, sessions *sessionManager, approvals *approvalManager) *jobManager {
+	return &jobManager{
+		cfg:       cfg,
+		sessions:  sessions,
+		approvals: approvals,
+		queue:     make(chan *job, cfg.QueueSize),
+		jobs:      make(map[string]*job),
+	}
+}
+
+// start 启动 worker 与过期任务清理，ctx 结束时停止
+func (m *jobManager) start(ctx context.Context) {
+	for i := 0; i < m.cfg.Workers; i++ {
+		go m.worker(ctx)
+	}
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				m.purge()
+			}
+		}
+	}()
+}
+
+// submit 登记任务并放入队列；队列已满时返回 errJobQueueFull，任务不会被登记
+func (m *jobManager) submit(j *job) error {
+	select {
+	case m.queue <- j:
+	default:
+		return errJobQueueFull
+	}
+	m.mu.Lock()
+	m.jobs[j.view.ID] = j
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *jobManager) get(id string) (*job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j, ok := m.jobs[id]
+	if !ok {
+		return nil, errJobNotFound
+	}
+	return j, nil
+}
+
+// resume 审批后让暂停的任务重新排队，opts 为从 checkpoint 继续运行的图选项
+func (m *jobManager) resume(id string, opts []compose.Option) (*job, error) {
+	j, err := m.get(id)
+	if err != nil {
+		return nil, err
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.view.Status != jobAwaitingApproval {
+		return j, errJobNotAwaiting
+	}
+	// 暂停期间会话由待审批记录占用，继续运行沿用这次占用，由 run 结束时释放
+	sess, err := m.sessions.reload(j.baseCtx, j.view.SessionID)
+	if err != nil {
+		return j, err
+	}
+	select {
+	case m.queue <- j:
+	default:
+		return j, errJobQueueFull
+	}
+	j.session = sess
+	j.view.Status = jobQueued
+	j.view.Approval = nil
+	j.resume = opts
+	return j, nil
+}
+
+// cancelJob 取消任务：排队中与等待审批的任务直接标记为 canceled，运行中的任务取消其 ctx，由 worker 记录最终状态
+func (m *jobManager) cancelJob(id string) (*job, error) {
+	j, err := m.get(id)
+	if err != nil {
+		return nil, err
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	switch {
+	case j.finished():
+		return j, errJobFinished
+	case j.view.Status == jobQueued || j.view.Status == jobAwaitingApproval:
+		if j.view.Status == jobAwaitingApproval || j.resume != nil {
+			m.approvals.discard(j.baseCtx, j.approvalID)
+		}
+		// 排队中的任务占用着会话，等待审批的任务由待审批记录占用，两种情况都在这里释放
+		m.sessions.release(j.view.SessionID)
+		now := time.Now()
+		j.view.Status = jobCanceled
+		j.view.FinishedAt = &now
+		j.view.Approval = nil
+		j.view.Error = errJobCanceled.Error()
+	default:
+		j.cancel(errJobCanceled)
+	}
+	return j, nil
+}
+
+// purge 删除结束时间早于保留期的任务
+func (m *jobManager) purge() {
+	deadline := time.Now().Add(-m.cfg.Retention)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, j := range m.jobs {
+		j.mu.Lock()
+		expired := j.finished() && j.view.FinishedAt.Before(deadline)
+		j.mu.Unlock()
+		if expired {
+			delete(m.jobs, id)
+		}
+	}
+}
+
+func (m *jobManager) worker(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case j := <-m.queue:
+			m.run(j)
+		}
+	}
+}
+
+// run 执行一个任务；排队期间已被取消的任务直接跳过
+func (m *jobManager) run(j *job) {
+	ctx, cancel := context.WithCancelCause(j.baseCtx)
+	defer cancel(nil)
+	ctx, cancelTimeout := context.WithTimeout(ctx, m.cfg.Timeout)
+	defer cancelTimeout()
+
+	j.mu.Lock()
+	if j.view.Status != jobQueued {
+		j.mu.Unlock()
+		return
+	}
+	now := time.Now()
+	j.view.Status = jobRunning
+	if j.view.StartedAt == nil {
+		j.view.StartedAt = &now
+	}
+	j.cancel = cancel
+	resume := j.resume
+	j.mu.Unlock()
+	// 任务结束或暂停等待审批时释放本次运行对会话的占用；暂停后会话由待审批记录占用（见 approvalManager.pause）
+	defer m.sessions.release(j.view.SessionID)
+
+	var rec *traceRecorder
+	if j.verbose {
+		rec = newTraceRecorder()
+	}
+	handler, wait := newStreamCallbacks(j.emit)
+	opts := append(rec.options(), compose.WithCallbacks(handler))
+	// 首次运行以 msgs 为输入；审批后继续时输入取自 checkpoint
+	input := j.msgs
+	if resume != nil {
+		input = nil
+		opts = append(opts, resume...)
+	} else {
+		opts = append(opts, m.approvals.runOptions(j.approvalID)...)
+	}
+	respMsgs, err := j.profile.Agent.Invoke(withToolProgress(ctx, j.progress), input, opts...)
+	wait()
+
+	a := &pendingApproval{ID: j.approvalID, SessionID: j.view.SessionID, JobID: j.view.ID, Input: j.input}
+	if paused, saveErr := m.approvals.pause(ctx, err, j.profile, a); paused {
+		j.mu.Lock()
+		defer j.mu.Unlock()
+		j.resume = nil
+		if saveErr == nil {
+			j.view.Status = jobAwaitingApproval
+			j.view.Approval = a
+			return
+		}
+		finished := time.Now()
+		j.view.FinishedAt = &finished
+		j.view.Status = jobFailed
+		j.view.Error = saveErr.Error()
+		return
+	}
+	if resume != nil {
+		m.approvals.discard(ctx, j.approvalID)
+	}
+
+	if err == nil {
+		if err := m.sessions.saveTurn(ctx, j.session, j.input, respMsgs); err != nil {
+			log.Printf("save session %s error: %v", j.view.SessionID, err)
+		}
+	}
+
+	trace := rec.trace(j.msgs, respMsgs)
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	finished := time.Now()
+	j.view.FinishedAt = &finished
+	j.view.Trace = trace
+	switch {
+	case err == nil:
+		j.view.Status = jobSucceeded
+		j.view.Output = j.profile.Output(respMsgs)
+		j.view.Plan = planFromMessages(respMsgs)
+	case errors.Is(context.Cause(ctx), errJobCanceled):
+		j.view.Status = jobCanceled
+		j.view.Error = errJobCanceled.Error()
+	default:
+		j.view.Status = jobFailed
+		j.view.Error = err.Error()
+	}
+}
+
+// jobsHandler POST /jobs 提交任务
+func jobsHandler(m *jobManager, profiles []*agentProfile, prompts *promptStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req jobRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+
+		if req.Input == "" {
+			http.Error(w, "input is required", http.StatusBadRequest)
+			return
+		}
+		profile := findProfile(profiles, req.Agent)
+		if profile == nil {
+			http.Error(w, fmt.Sprintf("unknown agent %q", req.Agent), http.StatusBadRequest)
+			return
+		}
+
+		// 会话从提交起保持进行中，直到任务结束；暂停等待审批期间同样保持（见 jobManager.run）
+		sess, err := m.sessions.load(ctx, req.SessionID)
+		if err != nil {
+			http.Error(w, err.Error(), sessionErrorStatus(err))
+			return
+		}
+		systemPrompt, err := prompts.systemPrompt(r, req.agentRequest, profile)
+		if err != nil {
+			m.sessions.release(req.SessionID)
+			http.Error(w, err.Error(), promptErrorStatus(err))
+			return
+		}
+
+		j := &job{
+			profile: profile,
+			input:   req.Input,
+			msgs:    m.sessions.buildMessages(systemPrompt, sess, req.Input),
+			session: sess,
+			verbose: req.Verbose,
+			baseCtx: context.WithoutCancel(ctx),
+			// 一个任务最多对应一个暂停中的运行，审批 ID 在提交时确定
+			approvalID: newApprovalID(),
+			view: jobView{
+				ID:        newJobID(),
+				Agent:     profile.Name,
+				Status:    jobQueued,
+				SessionID: req.SessionID,
+				CreatedAt: time.Now(),
+				Events:    []jobEvent{},
+			},
+		}
+		if err := m.submit(j); err != nil {
+			m.sessions.release(req.SessionID)
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Header().Set("Location", "/jobs/"+j.view.ID)
+		w.WriteHeader(http.StatusAccepted)
+		_ = json.NewEncoder(w).Encode(j.snapshot())
+	}
+}
+
+// jobHandler GET /jobs/{id} 查询任务，DELETE /jobs/{id} 取消任务
+func jobHandler(m *jobManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		if !jobIDPattern.MatchString(id) {
+			http.Error(w, "invalid job id", http.StatusBadRequest)
+			return
+		}
+
+		var (
+			j   *job
+			err error
+		)
+		switch r.Method {
+		case http.MethodGet:
+			j, err = m.get(id)
+		case http.MethodDelete:
+			j, err = m.cancelJob(id)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		switch {
+		case errors.Is(err, errJobNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		case errors.Is(err, errJobFinished):
+			// 已结束的任务无法取消，返回 409 与当前状态
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.WriteHeader(http.StatusConflict)
+			_ = json.NewEncoder(w).Encode(j.snapshot())
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_ = json.NewEncoder(w).Encode(j.snapshot())
+	}
+}
+
+// findProfile 按名字查找 profile，不存在时返回 nil
+func findProfile(profiles []*agentProfile, name string) *agentProfile {
+	for _, p := range profiles {
+		if p.Name == name {
+			return p
+		}
+	}
+	return nil
+}
+
+func newJobID() string {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		log.Printf("generate job id error: %v", err)
+	}
+	return "job-" + hex.EncodeToString(b)
+}
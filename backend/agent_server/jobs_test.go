@@ -0,0 +1,111 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/aistudiolabx/eino-demo/backend/agenttest"
+	"github.com/aistudiolabx/eino-demo/backend/mcp_server/mcptest"
+)
+
+// jobDone 任务已结束
+func jobDone(v jobView) bool {
+	return v.Status == jobSucceeded || v.Status == jobFailed || v.Status == jobCanceled
+}
+
+func TestJobSucceeds(t *testing.T) {
+	cm := agenttest.NewFakeChatModel(
+		agenttest.CallTools(agenttest.ToolCall("call_1", "weather", `{"city":"Shenzhen"}`)),
+		agenttest.Reply("深圳有小雨。"),
+	)
+	s := newTestServer(t, cm, nil)
+
+	var v jobView
+	req := jobRequest{agentRequest: agentRequest{Input: "深圳天气", SessionID: "s1"}, Agent: "agent"}
+	if status := s.do(t, http.MethodPost, "/jobs", req, &v); status != http.StatusAccepted {
+		t.Fatalf("submit status = %d", status)
+	}
+	v = s.waitJob(t, v.ID, jobDone)
+	if v.Status != jobSucceeded || v.Output != "深圳有小雨。" {
+		t.Fatalf("job = %+v", v)
+	}
+	var events []string
+	for _, e := range v.Events {
+		events = append(events, e.Event)
+	}
+	if len(events) != 2 || events[0] != sseEventToolStart || events[1] != sseEventToolEnd {
+		t.Errorf("events = %v, want tool_start, tool_end", events)
+	}
+
+	var sess Session
+	s.do(t, http.MethodGet, "/sessions/s1", nil, &sess)
+	if len(sess.Messages) != 4 {
+		t.Errorf("session has %d messages, want 4", len(sess.Messages))
+	}
+
+	if status := s.do(t, http.MethodDelete, "/jobs/"+v.ID, nil, nil); status != http.StatusConflict {
+		t.Errorf("cancel finished job status = %d, want 409", status)
+	}
+}
+
+func TestJobApproval(t *testing.T) {
+	s := newTestServer(t, agenttest.NewFakeChatModel(novelCall), &mcptest.Upstream{Script: "第一幕：山。", Polls: 2})
+
+	var v jobView
+	req := jobRequest{agentRequest: agentRequest{Input: "转成剧本", SessionID: "s1"}, Agent: "tool_agent"}
+	if status := s.do(t, http.MethodPost, "/jobs", req, &v); status != http.StatusAccepted {
+		t.Fatalf("submit status = %d", status)
+	}
+	v = s.waitJob(t, v.ID, func(v jobView) bool { return v.Status != jobQueued && v.Status != jobRunning })
+	if v.Status != jobAwaitingApproval || v.Approval == nil || v.Approval.JobID != v.ID {
+		t.Fatalf("job = %+v, want awaiting_approval", v)
+	}
+
+	// 等待审批期间会话保持进行中
+	if _, err := s.Sessions.load(t.Context(), "s1"); !errors.Is(err, errSessionBusy) {
+		t.Fatalf("load while awaiting approval error = %v, want errSessionBusy", err)
+	}
+
+	var resumed jobView
+	if status := s.do(t, http.MethodPost, "/approvals/"+v.Approval.ID, approvalRequest{Action: approvalApprove}, &resumed); status != http.StatusAccepted {
+		t.Fatalf("approve status = %d", status)
+	}
+	v = s.waitJob(t, v.ID, jobDone)
+	if v.Status != jobSucceeded || v.Output != "第一幕：山。" {
+		t.Fatalf("job = %+v", v)
+	}
+	if !runningHubCalled(s) {
+		t.Error("novel_to_script not executed after approval")
+	}
+	if _, err := s.Sessions.load(t.Context(), "s1"); err != nil {
+		t.Fatalf("session still busy after the job finished: %v", err)
+	}
+	s.Sessions.release("s1")
+}
+
+func TestJobCancelWhileRunning(t *testing.T) {
+	// RunningHub 任务一直不结束，取消经 MCP 调用的 ctx 传到工具
+	s := newTestServer(t, agenttest.NewFakeChatModel(novelCall), &mcptest.Upstream{Polls: 1 << 20})
+
+	var v jobView
+	req := jobRequest{agentRequest: agentRequest{Input: "转成剧本", SessionID: "s1"}, Agent: "tool_agent"}
+	s.do(t, http.MethodPost, "/jobs", req, &v)
+	v = s.waitJob(t, v.ID, func(v jobView) bool { return v.Status == jobAwaitingApproval })
+	s.do(t, http.MethodPost, "/approvals/"+v.Approval.ID, approvalRequest{Action: approvalApprove}, nil)
+	s.waitJob(t, v.ID, func(jobView) bool { return runningHubCalled(s) })
+
+	if status := s.do(t, http.MethodPost, "/agent", agentRequest{Input: "在吗", SessionID: "s1"}, nil); status != http.StatusConflict {
+		t.Errorf("turn during running job status = %d, want 409", status)
+	}
+	if status := s.do(t, http.MethodDelete, "/jobs/"+v.ID, nil, nil); status != http.StatusOK {
+		t.Fatalf("cancel status = %d", status)
+	}
+	v = s.waitJob(t, v.ID, jobDone)
+	if v.Status != jobCanceled {
+		t.Fatalf("job = %+v, want canceled", v)
+	}
+	if _, err := s.Sessions.load(t.Context(), "s1"); err != nil {
+		t.Errorf("session not released after cancel: %v", err)
+	}
+}
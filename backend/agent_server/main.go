@@ -2,35 +2,179 @@ package main
 
 import (
 	"context"
+	"flag"
 	"log"
 	"net/http"
+
+	"github.com/aistudiolabx/eino-demo/backend/config"
+	"github.com/aistudiolabx/eino-demo/backend/metrics"
+	"github.com/cloudwego/eino/callbacks"
+	"github.com/cloudwego/eino/components/tool"
 )
 
 func main() {
+	configPath := flag.String("config", "", "配置文件路径（YAML/JSON），不指定则使用默认配置与环境变量")
+	flag.Parse()
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatalf("load config error: %v", err)
+	}
+
 	ctx := context.Background()
 
-	agent, err := buildAgent(ctx, false)
+	// 指标与追踪：注册全局回调（需在编译 agent 之前注册），指标统计每次模型与工具调用，追踪为每个节点记录 span；
+	// available 为全部已连接的工具，工具指标只按其中的工具名区分
+	available := &toolCatalog{}
+	callbacks.AppendGlobalHandlers(newMetricsCallbacks(available.has))
+	exporter, err := newSpanExporter(cfg.Agent.Tracing)
 	if err != nil {
-		log.Fatalf("buildAgent error: %v", err)
+		log.Fatalf("newSpanExporter error: %v", err)
+	}
+	if exporter != nil {
+		callbacks.AppendGlobalHandlers(newTracingHandler(exporter))
 	}
-	toolAgent, err := buildAgent(ctx, true)
+
+	// 聊天模型：由 agent.model 配置（或 MODEL_PROVIDER 等环境变量）选择，默认本地 Ollama qwen2.5:7b
+	chatModel, err := newChatModel(ctx, cfg.Agent.Model)
 	if err != nil {
-		log.Fatalf("buildToolAgent error: %v", err)
+		log.Fatalf("newChatModel error: %v", err)
+	}
+
+	// 等待人工审批的运行：memory（默认）或 file
+	var approvalStore ApprovalStore
+	switch cfg.Agent.Approval.Store {
+	case "file":
+		approvalStore, err = newFileApprovalStore(cfg.Agent.Approval.Dir)
+		if err != nil {
+			log.Fatalf("newFileApprovalStore error: %v", err)
+		}
+	default:
+		approvalStore = newMemoryApprovalStore()
+	}
+
+	// 每个对外 agent 一个 profile，只绑定配置允许的工具；先以空工具集编译，
+	// MCP Server 在后台连接（断线自动重连），工具变化时重新编译并替换
+	agent, toolAgent, planAgent := newProfiles(cfg.Agent, approvalStore)
+	profiles := []*agentProfile{agent, toolAgent, planAgent}
+	rebuild := func(tools []tool.BaseTool) error {
+		if err := rebuildProfiles(ctx, chatModel, profiles, tools); err != nil {
+			return err
+		}
+		return available.set(ctx, tools)
+	}
+	if err := rebuild(nil); err != nil {
+		log.Fatalf("%v", err)
 	}
+	startMCPToolset(ctx, cfg.Agent.MCPServers, func(tools []tool.BaseTool) {
+		if err := rebuild(tools); err != nil {
+			log.Printf("rebuild agent error, keep previous tools: %v", err)
+			return
+		}
+		for _, p := range profiles {
+			log.Printf("%s rebound with %d of %d tools: %v", p.Name, len(p.Tools.names()), len(tools), p.Tools.names())
+		}
+	})
+
+	// 会话存储：memory（默认）或 file
+	var store SessionStore
+	switch cfg.Agent.Session.Store {
+	case "file":
+		store, err = newFileSessionStore(cfg.Agent.Session.Dir)
+		if err != nil {
+			log.Fatalf("newFileSessionStore error: %v", err)
+		}
+	default:
+		store = newMemorySessionStore()
+	}
+	sessions := &sessionManager{
+		store: store,
+		budget: historyBudget{
+			MaxMessages: cfg.Agent.Session.MaxMessages,
+			MaxTokens:   cfg.Agent.Session.MaxTokens,
+		},
+	}
+
+	// 系统提示模板：启动时检查各接口的默认模板能否加载，之后按文件修改时间自动重新加载
+	prompts := newPromptStore(cfg.Agent.Prompts)
+	for _, p := range profiles {
+		if _, err := prompts.load(p.Prompt); err != nil {
+			log.Fatalf("load prompt error: %v", err)
+		}
+	}
+
+	// 人工审批：暂停的运行在审批后从 checkpoint 继续
+	approvals := newApprovalManager(approvalStore, sessions, profiles)
+	if err := approvals.keepSessions(ctx); err != nil {
+		log.Fatalf("load approvals error: %v", err)
+	}
+
+	// /chat 意图路由：按配置把 profile 注册为子 agent；新增子 agent 时创建 profile 并在这里注册即可
+	var routes []*subAgent
+	for _, rc := range cfg.Agent.Router.Routes {
+		p := findProfile(profiles, rc.Agent)
+		if p == nil {
+			log.Fatalf("agent.router.routes: unknown agent %q", rc.Agent)
+		}
+		p.Endpoints = append(p.Endpoints, "/chat")
+		routes = append(routes, &subAgent{Profile: p, Description: rc.Description, Keywords: rc.Keywords})
+	}
+	router, err := newAgentRouter(cfg.Agent.Router, chatModel, routes...)
+	if err != nil {
+		log.Fatalf("agent.router: %v", err)
+	}
+
+	// 异步任务：固定数量的 worker 从有界队列中取任务执行
+	jobs := newJobManager(cfg.Agent.Jobs, sessions, approvals)
+	jobs.start(ctx)
 
 	mux := http.NewServeMux()
+	origin := cfg.Agent.CORSOrigin
+	// handle 注册接口并统计请求数与耗时（endpoint 标签为路由模式）
+	handle := func(pattern string, h http.HandlerFunc) {
+		mux.Handle(pattern, metrics.InstrumentHandler(pattern, h))
+	}
 
-	// 简单的健康检查
+	// 简单的健康检查与 Prometheus 指标
 	mux.HandleFunc("/healthz", healthzHandler)
+	mux.Handle("/metrics", metrics.Handler())
+
+	// agent 调用接口（带简单 CORS 支持，允许前端跨域访问）
+	handle("/agent", withCORS(origin, "POST, OPTIONS", agentHandler(agent, sessions, prompts, approvals)))
+	handle("/agent/stream", withCORS(origin, "POST, OPTIONS", agentStreamHandler(agent, sessions, prompts, approvals)))
+	handle("/tool_agent", withCORS(origin, "POST, OPTIONS", agentHandler(toolAgent, sessions, prompts, approvals)))
+	handle("/plan_agent", withCORS(origin, "POST, OPTIONS", agentHandler(planAgent, sessions, prompts, approvals)))
+	handle("/chat", withCORS(origin, "POST, OPTIONS", chatHandler(router, sessions, prompts, approvals)))
+
+	// OpenAI Chat Completions 兼容接口，model 选择使用哪个 agent
+	openAIModels := []openAIModel{
+		{ID: "eino-agent", Profile: agent},
+		{ID: "eino-tool-agent", Profile: toolAgent},
+		{ID: "eino-plan-agent", Profile: planAgent},
+	}
+	handle("/v1/models", withCORS(origin, "GET, OPTIONS", modelsHandler(openAIModels)))
+	handle("/v1/chat/completions", withCORS(origin, "POST, OPTIONS", chatCompletionsHandler(openAIModels, prompts, approvals)))
+
+	// 工具自省：全部可用工具与各接口实际绑定的工具
+	handle("/tools", withCORS(origin, "GET, OPTIONS", toolsHandler(available, profiles)))
+
+	// 异步任务接口
+	handle("/jobs", withCORS(origin, "POST, OPTIONS", jobsHandler(jobs, profiles, prompts)))
+	handle("/jobs/{id}", withCORS(origin, "GET, DELETE, OPTIONS", jobHandler(jobs)))
+
+	// 人工审批接口
+	handle("/approvals", withCORS(origin, "GET, OPTIONS", approvalsHandler(approvals)))
+	handle("/approvals/{id}", withCORS(origin, "GET, POST, OPTIONS", approvalHandler(approvals, jobs)))
 
-	// agent 调用接口（带简单 CORS 支持，允许前端在 8081 访问）
-	mux.HandleFunc("/agent", agentHandler(agent))
-	mux.HandleFunc("/tool_agent", toolAgentHandler(toolAgent))
+	// 会话管理接口
+	handle("/sessions", withCORS(origin, "GET, OPTIONS", sessionsHandler(store)))
+	handle("/sessions/{id}", withCORS(origin, "GET, DELETE, OPTIONS", sessionHandler(store)))
 
-	addr := ":8082"
+	addr := cfg.Agent.Addr
 	log.Printf("Agent server listening on %s\n", addr)
 
-	if err := http.ListenAndServe(addr, mux); err != nil {
+	// 每个响应都带 X-Trace-Id，与导出的 span 对应
+	if err := http.ListenAndServe(addr, withTraceID(mux)); err != nil {
 		log.Fatalf("agent server error: %v", err)
 	}
 }
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/aistudiolabx/eino-demo/backend/mcpext"
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/client/transport"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// mcpCancelTimeout 发送取消通知的超时；调用方 ctx 已结束，需要单独的 ctx
+const mcpCancelTimeout = 5 * time.Second
+
+// toolProgress 工具调用过程中 MCP Server 回报的一条进度
+type toolProgress struct {
+	Tool     string  `json:"tool"`
+	Progress float64 `json:"progress"`
+	Total    float64 `json:"total,omitempty"`
+	Message  string  `json:"message,omitempty"`
+}
+
+type toolProgressKey struct{}
+
+// withToolProgress 让 ctx 内发起的 MCP 工具调用把进度交给 sink；sink 需可并发调用
+func withToolProgress(ctx context.Context, sink func(toolProgress)) context.Context {
+	return context.WithValue(ctx, toolProgressKey{}, sink)
+}
+
+// progressSinks 进行中的工具调用的进度接收方，按 progressToken 索引
+type progressSinks struct {
+	mu    sync.Mutex
+	sinks map[string]progressSink
+}
+
+type progressSink struct {
+	tool string
+	fn   func(toolProgress)
+}
+
+var mcpProgress = &progressSinks{sinks: make(map[string]progressSink)}
+
+func (p *progressSinks) add(token, toolName string, fn func(toolProgress)) {
+	p.mu.Lock()
+	p.sinks[token] = progressSink{tool: toolName, fn: fn}
+	p.mu.Unlock()
+}
+
+func (p *progressSinks) remove(token string) {
+	p.mu.Lock()
+	delete(p.sinks, token)
+	p.mu.Unlock()
+}
+
+// dispatch 把 notifications/progress 交给对应调用的接收方，未知 token（调用已结束）直接丢弃
+func (p *progressSinks) dispatch(n mcp.JSONRPCNotification) {
+	fields := n.Params.AdditionalFields
+	token := mcpext.TokenKey(fields["progressToken"])
+	p.mu.Lock()
+	sink, ok := p.sinks[token]
+	p.mu.Unlock()
+	if !ok {
+		return
+	}
+	ev := toolProgress{Tool: sink.tool}
+	ev.Progress, _ = fields["progress"].(float64)
+	ev.Total, _ = fields["total"].(float64)
+	ev.Message, _ = fields["message"].(string)
+	sink.fn(ev)
+}
+
+// trackedMCPClient 为每次 tools/call 带上唯一的 progressToken（见 mcpext）：进度通知转给 ctx 中的接收方；
+// 调用方 ctx 取消时以标准的 notifications/cancelled 通知 server 停止执行。取消通知需要 tools/call 的
+// JSON-RPC 请求 ID，而 client.Client 不对外暴露自己分配的 ID，因此请求经传输层直接发送，ID 与 progressToken 相同
+type trackedMCPClient struct {
+	*client.Client
+}
+
+func (c *trackedMCPClient) CallTool(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	token := newProgressToken()
+	meta := &mcp.Meta{ProgressToken: token}
+	if req.Params.Meta != nil {
+		meta.AdditionalFields = req.Params.Meta.AdditionalFields
+	}
+	req.Params.Meta = meta
+	id := mcp.NewRequestId(token)
+
+	if sink, ok := ctx.Value(toolProgressKey{}).(func(toolProgress)); ok {
+		mcpProgress.add(token, req.Params.Name, sink)
+		defer mcpProgress.remove(token)
+	}
+
+	stop := context.AfterFunc(ctx, func() {
+		c.cancelToolCall(id, req.Params.Name, context.Cause(ctx))
+	})
+	defer stop()
+
+	resp, err := c.GetTransport().SendRequest(ctx, transport.JSONRPCRequest{
+		JSONRPC: mcp.JSONRPC_VERSION,
+		ID:      id,
+		Method:  string(mcp.MethodToolsCall),
+		Params:  req.Params,
+		Header:  req.Header,
+	})
+	if err != nil {
+		return nil, transport.NewError(err)
+	}
+	if resp.Error != nil {
+		return nil, resp.Error.AsError()
+	}
+	return mcp.ParseCallToolResult(&resp.Result)
+}
+
+// cancelToolCall 以 notifications/cancelled 通知 server 取消请求 id；server 不处理取消时忽略通知，失败也只记录日志
+func (c *trackedMCPClient) cancelToolCall(id mcp.RequestId, toolName string, cause error) {
+	ctx, cancel := context.WithTimeout(context.Background(), mcpCancelTimeout)
+	defer cancel()
+	reason := "cancelled"
+	if cause != nil {
+		reason = cause.Error()
+	}
+	err := c.GetTransport().SendNotification(ctx, mcp.JSONRPCNotification{
+		JSONRPC: mcp.JSONRPC_VERSION,
+		Notification: mcp.Notification{
+			Method: mcpext.CancelledNotification,
+			Params: mcp.NotificationParams{AdditionalFields: mcpext.CancelledParams(id, reason)},
+		},
+	})
+	if err != nil {
+		log.Printf("send cancel for tool %s error: %v", toolName, err)
+	}
+}
+
+func newProgressToken() string {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		log.Printf("generate progress token error: %v", err)
+	}
+	return "call-" + hex.EncodeToString(b)
+}
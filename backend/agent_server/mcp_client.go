@@ -1,27 +1,36 @@
 package main
 
 import (
+	"bufio"
 	"context"
-	"os"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"strings"
 
+	"github.com/aistudiolabx/eino-demo/backend/config"
+	mcpTool "github.com/cloudwego/eino-ext/components/tool/mcp"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
 	"github.com/mark3labs/mcp-go/client"
 	"github.com/mark3labs/mcp-go/mcp"
 )
 
-func newMCPClient(ctx context.Context) (client.MCPClient, error) {
-	// 默认连本地的 MCP Weather server
-	endpoint := os.Getenv("MCP_WEATHER_ENDPOINT")
-	if endpoint == "" {
-		endpoint = "http://localhost:3333/sse"
-	}
-
-	mcpClient, err := client.NewSSEMCPClient(endpoint)
+// newMCPClient 连接 endpoint 指定的 MCP Server 并完成初始化，传输方式由地址决定：
+//
+//	http://host:3333/sse                              SSE（路径以 /sse 结尾）
+//	http://host:3333/mcp                              Streamable HTTP（其余 http(s) 地址）
+//	stdio:go run ./backend/mcp_server --transport stdio  以子进程启动 server，按空白切分命令与参数
+func newMCPClient(ctx context.Context, endpoint string) (*client.Client, error) {
+	mcpClient, err := newMCPTransportClient(endpoint)
 	if err != nil {
 		return nil, err
 	}
 
-	// 启动 SSE 客户端
+	// 启动传输层（stdio 客户端创建时已启动子进程，这里是幂等的）
 	if err := mcpClient.Start(ctx); err != nil {
+		_ = mcpClient.Close()
 		return nil, err
 	}
 
@@ -34,8 +43,116 @@ func newMCPClient(ctx context.Context) (client.MCPClient, error) {
 	initReq.Params.Capabilities = mcp.ClientCapabilities{}
 
 	if _, err := mcpClient.Initialize(ctx, initReq); err != nil {
+		_ = mcpClient.Close()
 		return nil, err
 	}
 
 	return mcpClient, nil
 }
+
+// newMCPTransportClient 按 endpoint 的 scheme 创建对应传输方式的客户端
+func newMCPTransportClient(endpoint string) (*client.Client, error) {
+	if command, ok := strings.CutPrefix(endpoint, "stdio:"); ok {
+		fields := strings.Fields(command)
+		if len(fields) == 0 {
+			return nil, fmt.Errorf("stdio endpoint 缺少命令: %q", endpoint)
+		}
+		cli, err := client.NewStdioMCPClient(fields[0], nil, fields[1:]...)
+		if err != nil {
+			return nil, err
+		}
+		// 子进程的 stderr 是管道，不读取的话日志写满缓冲区后子进程会阻塞
+		if stderr, ok := client.GetStderr(cli); ok {
+			go forwardStderr(fields[0], stderr)
+		}
+		return cli, nil
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("不支持的 MCP endpoint %q，需要 http(s) 地址或 stdio:<命令>", endpoint)
+	}
+	if strings.HasSuffix(u.Path, "/sse") {
+		return client.NewSSEMCPClient(endpoint)
+	}
+	return client.NewStreamableHttpClient(endpoint)
+}
+
+// forwardStderr 把 stdio 子进程的 stderr 逐行转到本进程日志
+func forwardStderr(name string, stderr io.Reader) {
+	scanner := bufio.NewScanner(stderr)
+	for scanner.Scan() {
+		log.Printf("[%s] %s", name, scanner.Text())
+	}
+}
+
+// serverTools 某个 MCP Server 当前提供的工具
+type serverTools struct {
+	server config.MCPServerConfig
+	tools  []tool.BaseTool
+}
+
+// mergeTools 按配置顺序合并各 server 的工具：
+// 配置了 tool_prefix 的 server 其工具名统一加前缀；其余工具与已合并的工具重名时改名为 "<server>_<工具名>"。
+func mergeTools(ctx context.Context, sets []serverTools) ([]tool.BaseTool, error) {
+	var all []tool.BaseTool
+	seen := make(map[string]string) // 工具名 → 所属 server
+	for _, set := range sets {
+		srv := set.server
+		for _, t := range set.tools {
+			info, err := t.Info(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("MCP server %s: tool.Info: %w", srv.Name, err)
+			}
+			name := srv.ToolPrefix + info.Name
+			if owner, dup := seen[name]; dup {
+				renamed := srv.Name + "_" + info.Name
+				if _, dupAgain := seen[renamed]; dupAgain {
+					log.Printf("WARN: tool %s from MCP server %s conflicts with server %s, skipped", name, srv.Name, owner)
+					continue
+				}
+				log.Printf("WARN: tool %s from MCP server %s conflicts with server %s, renamed to %s", name, srv.Name, owner, renamed)
+				name = renamed
+			}
+			seen[name] = srv.Name
+			if name != info.Name {
+				t = &renamedTool{BaseTool: t, name: name}
+			}
+			all = append(all, t)
+		}
+	}
+	return all, nil
+}
+
+// fetchServerTools 用已初始化的客户端取回工具列表
+func fetchServerTools(ctx context.Context, cli client.MCPClient) ([]tool.BaseTool, error) {
+	tools, err := mcpTool.GetTools(ctx, &mcpTool.Config{Cli: cli})
+	if err != nil {
+		return nil, fmt.Errorf("GetTools: %w", err)
+	}
+	return tools, nil
+}
+
+// renamedTool 以新的名字对模型暴露 MCP 工具；实际调用 MCP Server 时仍使用原名
+type renamedTool struct {
+	tool.BaseTool
+	name string
+}
+
+func (t *renamedTool) Info(ctx context.Context) (*schema.ToolInfo, error) {
+	info, err := t.BaseTool.Info(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cp := *info
+	cp.Name = t.name
+	return &cp, nil
+}
+
+func (t *renamedTool) InvokableRun(ctx context.Context, argumentsInJSON string, opts ...tool.Option) (string, error) {
+	invokable, ok := t.BaseTool.(tool.InvokableTool)
+	if !ok {
+		return "", fmt.Errorf("tool %s is not invokable", t.name)
+	}
+	return invokable.InvokableRun(ctx, argumentsInJSON, opts...)
+}
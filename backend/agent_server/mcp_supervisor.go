@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/aistudiolabx/eino-demo/backend/config"
+	"github.com/aistudiolabx/eino-demo/backend/mcpext"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// MCP 连接的重连与健康检查参数
+const (
+	mcpRetryMinBackoff = time.Second
+	mcpRetryMaxBackoff = 30 * time.Second
+	mcpPingInterval    = 10 * time.Second
+	mcpPingTimeout     = 5 * time.Second
+)
+
+// errMCPConnectionLost 传输层报告连接断开
+var errMCPConnectionLost = errors.New("MCP 连接已断开")
+
+// mcpServerConn 维护与单个 MCP Server 的连接：
+// 后台连接（失败按指数退避重试）、定期 ping 检测断线并重新初始化，
+// 收到 notifications/tools/list_changed 时重新拉取工具，notifications/progress 转给发起调用的一方。
+type mcpServerConn struct {
+	cfg config.MCPServerConfig
+	// onChange 工具列表变化（连上、断开、list_changed）时调用
+	onChange func()
+
+	mu    sync.RWMutex
+	tools []tool.BaseTool
+}
+
+// currentTools 返回当前可用的工具，未连接时为空
+func (c *mcpServerConn) currentTools() []tool.BaseTool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.tools
+}
+
+func (c *mcpServerConn) setTools(tools []tool.BaseTool) {
+	c.mu.Lock()
+	c.tools = tools
+	c.mu.Unlock()
+	c.onChange()
+}
+
+// run 保持连接直到 ctx 结束
+func (c *mcpServerConn) run(ctx context.Context) {
+	backoff := mcpRetryMinBackoff
+	for ctx.Err() == nil {
+		err := c.session(ctx, func() { backoff = mcpRetryMinBackoff })
+		if ctx.Err() != nil {
+			return
+		}
+		if c.cfg.Optional {
+			log.Printf("WARN: optional MCP server %s (%s) unavailable, retry in %s: %v", c.cfg.Name, c.cfg.Endpoint, backoff, err)
+		} else {
+			log.Printf("ERROR: MCP server %s (%s) unavailable, retry in %s: %v", c.cfg.Name, c.cfg.Endpoint, backoff, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, mcpRetryMaxBackoff)
+	}
+}
+
+// session 建立一次连接并保持到断线，返回断线原因；连接成功后调用 connected
+func (c *mcpServerConn) session(ctx context.Context, connected func()) error {
+	cli, err := newMCPClient(ctx, c.cfg.Endpoint)
+	if err != nil {
+		return err
+	}
+	defer cli.Close()
+
+	lost := make(chan error, 1)
+	cli.OnConnectionLost(func(err error) {
+		select {
+		case lost <- err:
+		default:
+		}
+	})
+	listChanged := make(chan struct{}, 1)
+	cli.OnNotification(func(n mcp.JSONRPCNotification) {
+		switch n.Method {
+		case mcp.MethodNotificationToolsListChanged:
+			select {
+			case listChanged <- struct{}{}:
+			default:
+			}
+		case mcpext.ProgressNotification:
+			mcpProgress.dispatch(n)
+		}
+	})
+
+	// 工具通过 trackedMCPClient 调用，支持进度回报与取消
+	tracked := &trackedMCPClient{Client: cli}
+	tools, err := fetchServerTools(ctx, tracked)
+	if err != nil {
+		return err
+	}
+	connected()
+	log.Printf("loaded %d tools from MCP server %s", len(tools), c.cfg.Name)
+	c.setTools(tools)
+	// 断线后撤下该 server 的工具，避免模型调用注定失败的工具
+	defer c.setTools(nil)
+
+	ticker := time.NewTicker(mcpPingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-lost:
+			return fmt.Errorf("%w: %w", errMCPConnectionLost, err)
+		case <-listChanged:
+			tools, err := fetchServerTools(ctx, tracked)
+			if err != nil {
+				return err
+			}
+			log.Printf("tools changed on MCP server %s, reloaded %d tools", c.cfg.Name, len(tools))
+			c.setTools(tools)
+		case <-ticker.C:
+			pingCtx, cancel := context.WithTimeout(ctx, mcpPingTimeout)
+			err := cli.Ping(pingCtx)
+			cancel()
+			if err != nil {
+				return fmt.Errorf("%w: %w", errMCPConnectionLost, err)
+			}
+		}
+	}
+}
+
+// mcpToolset 汇总多个 MCP Server 的连接，任一 server 的工具变化时把合并后的工具交给 onChange
+type mcpToolset struct {
+	conns    []*mcpServerConn
+	onChange func(tools []tool.BaseTool)
+
+	// mu 串行化合并与 onChange，保证回调看到的工具列表按变化顺序到达
+	mu sync.Mutex
+}
+
+// startMCPToolset 在后台连接所有 server 并立即返回；server 尚未连上时 agent 以已有的工具运行
+func startMCPToolset(ctx context.Context, servers []config.MCPServerConfig, onChange func(tools []tool.BaseTool)) *mcpToolset {
+	ts := &mcpToolset{onChange: onChange}
+	for _, srv := range servers {
+		conn := &mcpServerConn{cfg: srv, onChange: func() { ts.refresh(ctx) }}
+		ts.conns = append(ts.conns, conn)
+	}
+	for _, conn := range ts.conns {
+		go conn.run(ctx)
+	}
+	return ts
+}
+
+// refresh 重新合并各 server 当前的工具
+func (ts *mcpToolset) refresh(ctx context.Context) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	sets := make([]serverTools, 0, len(ts.conns))
+	for _, conn := range ts.conns {
+		sets = append(sets, serverTools{server: conn.cfg, tools: conn.currentTools()})
+	}
+	tools, err := mergeTools(ctx, sets)
+	if err != nil {
+		log.Printf("merge MCP tools error: %v", err)
+		return
+	}
+	ts.onChange(tools)
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/aistudiolabx/eino-demo/backend/metrics"
+	"github.com/cloudwego/eino/callbacks"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+	callbackutils "github.com/cloudwego/eino/utils/callbacks"
+)
+
+// 模型与工具调用指标，model 标签为模型实现类型（如 Ollama、OpenAI），tool 标签为工具名；
+// 工具名来自模型输出，不在已连接工具中的名字（模型臆造的）统一记为 unknownToolLabel，避免序列数无限增长
+var (
+	modelCalls = metrics.NewCounter("agent_model_calls_total",
+		"模型调用次数", "model", "status")
+	modelCallDuration = metrics.NewHistogram("agent_model_call_duration_seconds",
+		"模型调用耗时（秒），流式调用为整个流的持续时间", nil, "model")
+	modelTokens = metrics.NewCounter("agent_model_tokens_total",
+		"模型消耗的 token 数（以模型返回的用量为准）", "model", "type")
+	toolCalls = metrics.NewCounter("agent_tool_calls_total",
+		"工具调用次数", "tool", "status")
+	toolCallDuration = metrics.NewHistogram("agent_tool_call_duration_seconds",
+		"工具调用耗时（秒）", nil, "tool")
+)
+
+// unknownToolLabel 未知工具的 tool 标签值
+const unknownToolLabel = "unknown"
+
+type metricsStartKey struct{}
+
+// newMetricsCallbacks 全局回调：统计每次模型调用与工具调用，known 判断工具名是否属于已连接的工具
+func newMetricsCallbacks(known func(name string) bool) callbacks.Handler {
+	start := func(ctx context.Context) context.Context {
+		return context.WithValue(ctx, metricsStartKey{}, time.Now())
+	}
+	startedAt := func(ctx context.Context) time.Time {
+		t, ok := ctx.Value(metricsStartKey{}).(time.Time)
+		if !ok {
+			return time.Now()
+		}
+		return t
+	}
+
+	modelDone := func(ctx context.Context, info *callbacks.RunInfo, usage *model.TokenUsage, err error) {
+		status := "ok"
+		if err != nil {
+			status = "error"
+		}
+		modelCalls.Inc(info.Type, status)
+		modelCallDuration.ObserveSince(startedAt(ctx), info.Type)
+		if usage != nil {
+			modelTokens.Add(float64(usage.PromptTokens), info.Type, "prompt")
+			modelTokens.Add(float64(usage.CompletionTokens), info.Type, "completion")
+		}
+	}
+	toolDone := func(ctx context.Context, info *callbacks.RunInfo, err error) {
+		status := "ok"
+		if err != nil {
+			status = "error"
+		}
+		name := info.Name
+		if !known(name) {
+			name = unknownToolLabel
+		}
+		toolCalls.Inc(name, status)
+		toolCallDuration.ObserveSince(startedAt(ctx), name)
+	}
+
+	modelHandler := &callbackutils.ModelCallbackHandler{
+		OnStart: func(ctx context.Context, _ *callbacks.RunInfo, _ *model.CallbackInput) context.Context {
+			return start(ctx)
+		},
+		OnEnd: func(ctx context.Context, info *callbacks.RunInfo, output *model.CallbackOutput) context.Context {
+			modelDone(ctx, info, callbackUsage(output), nil)
+			return ctx
+		},
+		OnEndWithStreamOutput: func(ctx context.Context, info *callbacks.RunInfo, output *schema.StreamReader[*model.CallbackOutput]) context.Context {
+			go func() {
+				defer output.Close()
+				var usage *model.TokenUsage
+				for {
+					chunk, err := output.Recv()
+					if errors.Is(err, io.EOF) {
+						break
+					}
+					if err != nil {
+						modelDone(ctx, info, usage, err)
+						return
+					}
+					if u := callbackUsage(chunk); u != nil {
+						usage = u
+					}
+				}
+				modelDone(ctx, info, usage, nil)
+			}()
+			return ctx
+		},
+		OnError: func(ctx context.Context, info *callbacks.RunInfo, err error) context.Context {
+			modelDone(ctx, info, nil, err)
+			return ctx
+		},
+	}
+
+	toolHandler := &callbackutils.ToolCallbackHandler{
+		OnStart: func(ctx context.Context, _ *callbacks.RunInfo, _ *tool.CallbackInput) context.Context {
+			return start(ctx)
+		},
+		OnEnd: func(ctx context.Context, info *callbacks.RunInfo, _ *tool.CallbackOutput) context.Context {
+			toolDone(ctx, info, nil)
+			return ctx
+		},
+		OnEndWithStreamOutput: func(ctx context.Context, info *callbacks.RunInfo, output *schema.StreamReader[*tool.CallbackOutput]) context.Context {
+			go func() {
+				defer output.Close()
+				for {
+					_, err := output.Recv()
+					if errors.Is(err, io.EOF) {
+						toolDone(ctx, info, nil)
+						return
+					}
+					if err != nil {
+						toolDone(ctx, info, err)
+						return
+					}
+				}
+			}()
+			return ctx
+		},
+		OnError: func(ctx context.Context, info *callbacks.RunInfo, err error) context.Context {
+			toolDone(ctx, info, err)
+			return ctx
+		},
+	}
+
+	return callbackutils.NewHandlerHelper().ChatModel(modelHandler).Tool(toolHandler).Handler()
+}
+
+// callbackUsage 取模型回调中的 token 用量，部分实现只把用量放在消息的 ResponseMeta 中
+func callbackUsage(out *model.CallbackOutput) *model.TokenUsage {
+	if out.TokenUsage != nil {
+		return out.TokenUsage
+	}
+	if out.Message != nil && out.Message.ResponseMeta != nil && out.Message.ResponseMeta.Usage != nil {
+		u := out.Message.ResponseMeta.Usage
+		return &model.TokenUsage{PromptTokens: u.PromptTokens, CompletionTokens: u.CompletionTokens, TotalTokens: u.TotalTokens}
+	}
+	return nil
+}
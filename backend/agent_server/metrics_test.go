@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aistudiolabx/eino-demo/backend/metrics"
+	"github.com/cloudwego/eino/callbacks"
+	"github.com/cloudwego/eino/components"
+	"github.com/cloudwego/eino/components/tool"
+)
+
+func TestMetricsCallbacksUnknownTool(t *testing.T) {
+	h := newMetricsCallbacks(func(name string) bool { return name == "metrics_test_weather" })
+	for _, name := range []string{"metrics_test_weather", "metrics_test_hallucinated"} {
+		ctx := callbacks.InitCallbacks(context.Background(), &callbacks.RunInfo{Name: name, Component: components.ComponentOfTool}, h)
+		ctx = callbacks.OnStart(ctx, &tool.CallbackInput{ArgumentsInJSON: "{}"})
+		callbacks.OnEnd(ctx, &tool.CallbackOutput{Response: "ok"})
+	}
+
+	rec := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	out := rec.Body.String()
+	if !strings.Contains(out, `agent_tool_calls_total{tool="metrics_test_weather",status="ok"}`) {
+		t.Errorf("known tool series missing:\n%s", out)
+	}
+	if !strings.Contains(out, `agent_tool_calls_total{tool="unknown",status="ok"}`) {
+		t.Errorf("unknown tool not counted as tool=\"unknown\":\n%s", out)
+	}
+	if strings.Contains(out, "metrics_test_hallucinated") {
+		t.Errorf("hallucinated tool name exported as a label:\n%s", out)
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aistudiolabx/eino-demo/backend/config"
+	"github.com/aistudiolabx/eino-demo/backend/modelreplay"
+	ollama "github.com/cloudwego/eino-ext/components/model/ollama"
+	openai "github.com/cloudwego/eino-ext/components/model/openai"
+	"github.com/cloudwego/eino/components/model"
+)
+
+// 各 provider 的默认值
+const (
+	defaultModelProvider = "ollama"
+	defaultOllamaBaseURL = "http://localhost:11434"
+	defaultOllamaModel   = "qwen2.5:7b"
+	defaultOpenAIBaseURL = "https://api.openai.com/v1"
+	defaultModelTimeout  = 2 * time.Minute
+)
+
+// chatModelFactory 按配置构造一个支持工具调用的聊天模型
+type chatModelFactory func(ctx context.Context, cfg config.ModelConfig) (model.ToolCallingChatModel, error)
+
+// chatModelProviders 已注册的模型 provider；接入其他 eino ToolCallingChatModel 时用 registerChatModelProvider 注册
+var chatModelProviders = map[string]chatModelFactory{
+	"ollama": newOllamaChatModel,
+	"openai": newOpenAIChatModel,
+	"replay": newReplayChatModel,
+}
+
+// registerChatModelProvider 注册（或覆盖）一个模型 provider
+func registerChatModelProvider(name string, factory chatModelFactory) {
+	chatModelProviders[name] = factory
+}
+
+// newChatModel 按 cfg.Provider 选择 provider 构造模型；cfg.Record 非空时包装为录制模型
+func newChatModel(ctx context.Context, cfg config.ModelConfig) (model.ToolCallingChatModel, error) {
+	provider := cfg.Provider
+	if provider == "" {
+		provider = defaultModelProvider
+	}
+	factory, ok := chatModelProviders[provider]
+	if !ok {
+		names := make([]string, 0, len(chatModelProviders))
+		for name := range chatModelProviders {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("未知的模型 provider %q，可选：%s", provider, strings.Join(names, ", "))
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultModelTimeout
+	}
+	cm, err := factory(ctx, cfg)
+	if err != nil || cfg.Record == "" {
+		return cm, err
+	}
+	return modelreplay.NewRecorder(cm, cfg.Record), nil
+}
+
+// newOllamaChatModel 使用本地或远程 Ollama 服务
+func newOllamaChatModel(ctx context.Context, cfg config.ModelConfig) (model.ToolCallingChatModel, error) {
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = defaultOllamaBaseURL
+	}
+	if cfg.Model == "" {
+		cfg.Model = defaultOllamaModel
+	}
+	conf := &ollama.ChatModelConfig{
+		BaseURL: cfg.BaseURL,
+		Model:   cfg.Model,
+		Timeout: cfg.Timeout,
+	}
+	if cfg.Temperature != nil {
+		conf.Options = &ollama.Options{Temperature: *cfg.Temperature}
+	}
+	cm, err := ollama.NewChatModel(ctx, conf)
+	if err != nil {
+		return nil, fmt.Errorf("NewChatModel(ollama): %w", err)
+	}
+	return cm, nil
+}
+
+// newOpenAIChatModel 使用 OpenAI Chat Completions 协议的服务
+func newOpenAIChatModel(ctx context.Context, cfg config.ModelConfig) (model.ToolCallingChatModel, error) {
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = defaultOpenAIBaseURL
+	}
+	if cfg.Model == "" {
+		return nil, fmt.Errorf("openai provider 需要指定模型名")
+	}
+	cm, err := openai.NewChatModel(ctx, &openai.ChatModelConfig{
+		BaseURL:     cfg.BaseURL,
+		APIKey:      cfg.APIKey,
+		Model:       cfg.Model,
+		Temperature: cfg.Temperature,
+		Timeout:     cfg.Timeout,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("NewChatModel(openai): %w", err)
+	}
+	return cm, nil
+}
+
+// newReplayChatModel 回放 cfg.Replay 录制的模型调用，用于离线演示与回归测试
+func newReplayChatModel(_ context.Context, cfg config.ModelConfig) (model.ToolCallingChatModel, error) {
+	if cfg.Replay == "" {
+		return nil, fmt.Errorf("replay provider 需要指定 golden 文件")
+	}
+	return modelreplay.LoadReplayer(cfg.Replay)
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aistudiolabx/eino-demo/backend/agenttest"
+	"github.com/aistudiolabx/eino-demo/backend/config"
+	"github.com/aistudiolabx/eino-demo/backend/modelreplay"
+	"github.com/cloudwego/eino/schema"
+)
+
+// updateGolden 为 true 时先用 FakeChatModel 的脚本重新录制 golden 文件：go test ./agent_server -run Replay -update
+var updateGolden = flag.Bool("update", false, "重新录制 testdata/golden 下的 golden 文件")
+
+// fakeModelServer 记录收到的请求体，path 上的请求用 reply 应答；delay 非零时等待后再应答（或直到客户端断开）
+func fakeModelServer(t *testing.T, path string, delay time.Duration, reply func(w http.ResponseWriter, body map[string]any)) (*httptest.Server, chan map[string]any) {
+	t.Helper()
+	bodies := make(chan map[string]any, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != path {
+			http.NotFound(w, r)
+			return
+		}
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		select {
+		case bodies <- body:
+		default:
+		}
+		if delay > 0 {
+			select {
+			case <-time.After(delay):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		reply(w, body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, bodies
+}
+
+func ollamaReply(w http.ResponseWriter, body map[string]any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"model":      body["model"],
+		"created_at": time.Now().Format(time.RFC3339),
+		"message":    map[string]any{"role": "assistant", "content": "pong"},
+		"done":       true,
+	})
+}
+
+func openAIReply(w http.ResponseWriter, body map[string]any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"id":      "chatcmpl-test",
+		"object":  "chat.completion",
+		"created": time.Now().Unix(),
+		"model":   body["model"],
+		"choices": []map[string]any{{
+			"index":         0,
+			"message":       map[string]any{"role": "assistant", "content": "pong"},
+			"finish_reason": "stop",
+		}},
+	})
+}
+
+func ptr[T any](v T) *T { return &v }
+
+func TestNewChatModelProviders(t *testing.T) {
+	for _, tc := range []struct {
+		provider string
+		path     string
+		baseURL  func(srv *httptest.Server) string
+		reply    func(http.ResponseWriter, map[string]any)
+		// temperature 从请求体中取出 temperature
+		temperature func(body map[string]any) any
+	}{
+		{
+			provider:    "ollama",
+			path:        "/api/chat",
+			baseURL:     func(srv *httptest.Server) string { return srv.URL },
+			reply:       ollamaReply,
+			temperature: func(body map[string]any) any { opts, _ := body["options"].(map[string]any); return opts["temperature"] },
+		},
+		{
+			provider:    "openai",
+			path:        "/v1/chat/completions",
+			baseURL:     func(srv *httptest.Server) string { return srv.URL + "/v1" },
+			reply:       openAIReply,
+			temperature: func(body map[string]any) any { return body["temperature"] },
+		},
+	} {
+		t.Run(tc.provider, func(t *testing.T) {
+			ctx := context.Background()
+			srv, bodies := fakeModelServer(t, tc.path, 0, tc.reply)
+			cm, err := newChatModel(ctx, config.ModelConfig{
+				Provider:    tc.provider,
+				BaseURL:     tc.baseURL(srv),
+				Model:       "test-model",
+				Temperature: ptr(float32(0.25)),
+			})
+			if err != nil {
+				t.Fatalf("newChatModel: %v", err)
+			}
+			msg, err := cm.Generate(ctx, []*schema.Message{schema.UserMessage("ping")})
+			if err != nil {
+				t.Fatalf("Generate: %v", err)
+			}
+			if msg.Content != "pong" {
+				t.Errorf("content = %q, want pong", msg.Content)
+			}
+			body := <-bodies
+			if body["model"] != "test-model" {
+				t.Errorf("model = %v, want test-model", body["model"])
+			}
+			if got := tc.temperature(body); got != 0.25 {
+				t.Errorf("temperature = %v, want 0.25", got)
+			}
+		})
+
+		t.Run(tc.provider+"/timeout", func(t *testing.T) {
+			ctx := context.Background()
+			srv, _ := fakeModelServer(t, tc.path, 5*time.Second, tc.reply)
+			cm, err := newChatModel(ctx, config.ModelConfig{
+				Provider: tc.provider,
+				BaseURL:  tc.baseURL(srv),
+				Model:    "test-model",
+				Timeout:  200 * time.Millisecond,
+			})
+			if err != nil {
+				t.Fatalf("newChatModel: %v", err)
+			}
+			start := time.Now()
+			if _, err := cm.Generate(ctx, []*schema.Message{schema.UserMessage("ping")}); err == nil {
+				t.Fatal("Generate succeeded, want timeout error")
+			}
+			if elapsed := time.Since(start); elapsed > 2*time.Second {
+				t.Errorf("Generate returned after %v, timeout not honored", elapsed)
+			}
+		})
+	}
+}
+
+func TestNewChatModelUnknownProvider(t *testing.T) {
+	_, err := newChatModel(context.Background(), config.ModelConfig{Provider: "nope"})
+	if err == nil {
+		t.Fatal("newChatModel succeeded for unknown provider")
+	}
+	if !strings.Contains(err.Error(), `"nope"`) || !strings.Contains(err.Error(), "ollama") {
+		t.Errorf("error = %v, want provider name and available providers", err)
+	}
+}
+
+func TestNewOpenAIChatModelRequiresModel(t *testing.T) {
+	if _, err := newChatModel(context.Background(), config.ModelConfig{Provider: "openai"}); err == nil {
+		t.Fatal("newChatModel succeeded without model name")
+	}
+}
+
+func TestReplayProviderGolden(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join("testdata", "golden", "weather.json")
+	req := agentRequest{Input: "北京和上海今天天气怎么样"}
+	const answer = "北京晴，21.5°C；上海阴，24.1°C。"
+
+	if *updateGolden {
+		rec := modelreplay.NewRecorder(agenttest.NewFakeChatModel(
+			agenttest.CallTools(
+				agenttest.ToolCall("call_1", "weather", `{"city":"Beijing"}`),
+				agenttest.ToolCall("call_2", "weather", `{"city":"Shanghai"}`),
+			),
+			agenttest.Reply(answer),
+		), path)
+		s := newTestServer(t, rec, nil)
+		if status := s.do(t, http.MethodPost, "/agent", req, nil); status != http.StatusOK {
+			t.Fatalf("record status = %d", status)
+		}
+	}
+
+	cm, err := newChatModel(ctx, config.ModelConfig{Provider: "replay", Replay: path})
+	if err != nil {
+		t.Fatalf("newChatModel(replay): %v", err)
+	}
+	s := newTestServer(t, cm, nil)
+	var resp agentResponse
+	if status := s.do(t, http.MethodPost, "/agent", req, &resp); status != http.StatusOK {
+		t.Fatalf("status = %d, error = %s", status, resp.Error)
+	}
+	if resp.Output != answer {
+		t.Errorf("output = %q, want %q", resp.Output, answer)
+	}
+
+	// 输入与录制时不同，回放报错而不是给出错误的回答
+	if status := s.do(t, http.MethodPost, "/agent", agentRequest{Input: "深圳呢"}, &resp); status != http.StatusInternalServerError {
+		t.Errorf("unrecorded input status = %d, want 500", status)
+	}
+}
@@ -0,0 +1,538 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/compose"
+	"github.com/cloudwego/eino/schema"
+)
+
+// OpenAI Chat Completions 兼容接口：/v1/models 列出可用的 agent，/v1/chat/completions 运行指定的 agent。
+// 请求与响应遵循 OpenAI 的格式，content 只包含最终回答：流式响应中回答的增量文本实时转发，
+// 拼接后与非流式的 message.content 相同（工具调用轮次中模型写的文字不属于回答，不转发）。
+// tool_agent 的回答是工具结果而不是模型输出，流式响应中在运行结束后作为一个 content delta 发送。
+//
+// 扩展字段：工具在服务端执行，标准的 tool_calls 表示“请客户端执行这些工具”，用它描述服务端的调用会让
+// 客户端尝试执行或要求回传结果，因此工具调用过程与审批放在下面两个 OpenAI 格式之外的字段中，
+// 标准客户端与 SDK 会忽略它们：
+//
+//	tool_activity  非流式：choices[0].message.tool_activity = [{"id","name","arguments","result"|"error"}...]
+//	               流式：  choices[0].delta.tool_activity = {"event":"tool_start|tool_end|tool_error","id","name",...}，工具事件实时发送
+//	approval       调用需要审批的工具前运行暂停，message 或 delta 中的 approval 为待审批记录（见 approval.go），
+//	               content 为审批提示；审批后的最终回答在 POST /approvals/{id} 的响应中返回
+
+// openAIModelOwner /v1/models 中的 owned_by
+const openAIModelOwner = "eino-demo"
+
+// openAIModel 以模型名对外暴露的一个 agent
+type openAIModel struct {
+	ID string
+	// Profile 处理请求的 agent；请求中没有 system 消息时使用其默认系统提示模板
+	Profile *agentProfile
+}
+
+// chatCompletionRequest 请求体，只解析本服务支持的字段，其余字段忽略
+type chatCompletionRequest struct {
+	Model         string        `json:"model"`
+	Messages      []chatMessage `json:"messages"`
+	Stream        bool          `json:"stream"`
+	StreamOptions *struct {
+		IncludeUsage bool `json:"include_usage"`
+	} `json:"stream_options,omitempty"`
+	Temperature *float32 `json:"temperature,omitempty"`
+	TopP        *float32 `json:"top_p,omitempty"`
+	MaxTokens   *int     `json:"max_tokens,omitempty"`
+}
+
+type chatMessage struct {
+	Role       string         `json:"role"`
+	Content    chatContent    `json:"content"`
+	Name       string         `json:"name,omitempty"`
+	ToolCalls  []chatToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string         `json:"tool_call_id,omitempty"`
+	// ToolActivity 扩展字段，本次运行中服务端执行的工具调用
+	ToolActivity []chatToolActivity `json:"tool_activity,omitempty"`
+	// Approval 扩展字段，运行暂停等待审批时的待审批记录
+	Approval *pendingApproval `json:"approval,omitempty"`
+}
+
+// chatContent 消息内容：兼容字符串、null 与只含 text 片段的数组三种写法
+type chatContent string
+
+func (c *chatContent) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		*c = ""
+		return nil
+	}
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		*c = chatContent(s)
+		return nil
+	}
+	var parts []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(data, &parts); err != nil {
+		return fmt.Errorf("content 需要字符串或片段数组: %w", err)
+	}
+	var b strings.Builder
+	for _, p := range parts {
+		if p.Type != "text" {
+			return fmt.Errorf("暂不支持 %q 类型的 content 片段", p.Type)
+		}
+		b.WriteString(p.Text)
+	}
+	*c = chatContent(b.String())
+	return nil
+}
+
+type chatToolCall struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+// chatToolActivity 一次服务端工具调用；Event 只在流式响应中出现
+type chatToolActivity struct {
+	Event string `json:"event,omitempty"`
+	streamToolEvent
+}
+
+type chatCompletionResponse struct {
+	ID      string       `json:"id"`
+	Object  string       `json:"object"`
+	Created int64        `json:"created"`
+	Model   string       `json:"model"`
+	Choices []chatChoice `json:"choices"`
+	Usage   *chatUsage   `json:"usage,omitempty"`
+}
+
+type chatChoice struct {
+	Index        int          `json:"index"`
+	Message      *chatMessage `json:"message,omitempty"`
+	Delta        *chatDelta   `json:"delta,omitempty"`
+	FinishReason *string      `json:"finish_reason"`
+}
+
+type chatDelta struct {
+	Role         string            `json:"role,omitempty"`
+	Content      string            `json:"content,omitempty"`
+	ToolActivity *chatToolActivity `json:"tool_activity,omitempty"`
+	Approval     *pendingApproval  `json:"approval,omitempty"`
+}
+
+type chatUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// openAIError OpenAI 风格的错误响应
+type openAIError struct {
+	Error struct {
+		Message string `json:"message"`
+		Type    string `json:"type"`
+		Code    string `json:"code,omitempty"`
+	} `json:"error"`
+}
+
+func writeOpenAIError(w http.ResponseWriter, status int, errType, code, message string) {
+	var body openAIError
+	body.Error.Message = message
+	body.Error.Type = errType
+	body.Error.Code = code
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// modelsHandler GET /v1/models
+func modelsHandler(models []openAIModel) http.HandlerFunc {
+	created := time.Now().Unix()
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeOpenAIError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method_not_allowed", "method not allowed")
+			return
+		}
+
+		type modelEntry struct {
+			ID      string `json:"id"`
+			Object  string `json:"object"`
+			Created int64  `json:"created"`
+			OwnedBy string `json:"owned_by"`
+		}
+		data := make([]modelEntry, 0, len(models))
+		for _, m := range models {
+			data = append(data, modelEntry{ID: m.ID, Object: "model", Created: created, OwnedBy: openAIModelOwner})
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_ = json.NewEncoder(w).Encode(map[string]any{"object": "list", "data": data})
+	}
+}
+
+// chatCompletionsHandler POST /v1/chat/completions；model 为空时使用第一个模型
+func chatCompletionsHandler(models []openAIModel, prompts *promptStore, approvals *approvalManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		if r.Method != http.MethodPost {
+			writeOpenAIError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method_not_allowed", "method not allowed")
+			return
+		}
+
+		var req chatCompletionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", "invalid_json", err.Error())
+			return
+		}
+		if len(req.Messages) == 0 {
+			writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", "missing_messages", "messages is required")
+			return
+		}
+
+		m, ok := findOpenAIModel(models, req.Model)
+		if !ok {
+			writeOpenAIError(w, http.StatusNotFound, "invalid_request_error", "model_not_found", fmt.Sprintf("model %q does not exist", req.Model))
+			return
+		}
+
+		msgs, err := toSchemaMessages(req.Messages)
+		if err != nil {
+			writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", "invalid_messages", err.Error())
+			return
+		}
+		// 客户端没有给 system 消息时使用该模型的默认系统提示
+		if msgs[0].Role != schema.System {
+			systemPrompt, err := prompts.render(ctx, m.Profile.Prompt, acceptLanguage(r), m.Profile.Tools)
+			if err != nil {
+				writeOpenAIError(w, http.StatusInternalServerError, "server_error", "", err.Error())
+				return
+			}
+			msgs = append([]*schema.Message{schema.SystemMessage(systemPrompt)}, msgs...)
+		}
+
+		opts := chatModelOptions(req)
+		completion := &chatCompletionResponse{
+			ID:      newCompletionID(),
+			Created: time.Now().Unix(),
+			Model:   m.ID,
+		}
+
+		if req.Stream {
+			streamChatCompletion(w, r, m, msgs, opts, completion, req.StreamOptions != nil && req.StreamOptions.IncludeUsage, approvals)
+			return
+		}
+
+		// OpenAI 接口没有会话，审批后的结果不写入会话
+		approvalID := newApprovalID()
+		respMsgs, err := m.Profile.Agent.Invoke(ctx, msgs, append(opts, approvals.runOptions(approvalID)...)...)
+		a := &pendingApproval{ID: approvalID, Input: lastUserInput(msgs)}
+		paused, saveErr := approvals.pause(ctx, err, m.Profile, a)
+		if saveErr != nil {
+			writeOpenAIError(w, http.StatusInternalServerError, "server_error", "", saveErr.Error())
+			return
+		}
+		if err != nil && !paused {
+			writeOpenAIError(w, http.StatusInternalServerError, "server_error", "", err.Error())
+			return
+		}
+
+		stop := "stop"
+		completion.Object = "chat.completion"
+		message := &chatMessage{
+			Role:         string(schema.Assistant),
+			Content:      chatContent(m.Profile.Output(respMsgs)),
+			ToolActivity: toolActivity(respMsgs),
+		}
+		if paused {
+			message.Content = chatContent(approvalNotice(a))
+			message.Approval = a
+		}
+		completion.Choices = []chatChoice{{Message: message, FinishReason: &stop}}
+		completion.Usage = usageOf(msgs, respMsgs)
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_ = json.NewEncoder(w).Encode(completion)
+	}
+}
+
+// streamChatCompletion 以 chat.completion.chunk 流返回，最后发送 data: [DONE]
+func streamChatCompletion(w http.ResponseWriter, r *http.Request, m openAIModel, msgs []*schema.Message, opts []compose.Option, completion *chatCompletionResponse, includeUsage bool, approvals *approvalManager) {
+	ctx := r.Context()
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeOpenAIError(w, http.StatusInternalServerError, "server_error", "", "streaming unsupported")
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	completion.Object = "chat.completion.chunk"
+	var mu sync.Mutex
+	send := func(data any) {
+		payload, err := json.Marshal(data)
+		if err != nil {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		_, _ = fmt.Fprintf(w, "data: %s\n\n", payload)
+		flusher.Flush()
+	}
+	chunk := func(delta *chatDelta, finishReason *string) chatCompletionResponse {
+		c := *completion
+		c.Choices = []chatChoice{{Delta: delta, FinishReason: finishReason}}
+		return c
+	}
+	fail := func(err error) {
+		var body openAIError
+		body.Error.Message = err.Error()
+		body.Error.Type = "server_error"
+		send(body)
+	}
+	stop := "stop"
+	done := func() {
+		mu.Lock()
+		_, _ = io.WriteString(w, "data: [DONE]\n\n")
+		flusher.Flush()
+		mu.Unlock()
+	}
+	approvalID := newApprovalID()
+	// interrupted 运行因等待审批而中断时发送审批提示并结束流
+	interrupted := func(err error) bool {
+		a := &pendingApproval{ID: approvalID, Input: lastUserInput(msgs)}
+		paused, saveErr := approvals.pause(ctx, err, m.Profile, a)
+		if !paused {
+			return false
+		}
+		if saveErr != nil {
+			fail(saveErr)
+			return true
+		}
+		send(chunk(&chatDelta{Content: approvalNotice(a), Approval: a}, nil))
+		send(chunk(&chatDelta{}, &stop))
+		done()
+		return true
+	}
+
+	send(chunk(&chatDelta{Role: string(schema.Assistant)}, nil))
+
+	// 工具调用事件实时转成 chunk；模型的增量文本只转发生成回答的那个节点的输出，见 newAnswerCallbacks
+	toolHandler, waitTools := newStreamCallbacks(func(event string, data any) {
+		if d, ok := data.(streamToolEvent); ok {
+			send(chunk(&chatDelta{ToolActivity: &chatToolActivity{Event: event, streamToolEvent: d}}, nil))
+		}
+	})
+	opts = append(opts, compose.WithCallbacks(toolHandler))
+	wait := waitTools
+	if m.Profile.Answer != nil {
+		answerHandler, waitAnswer := newAnswerCallbacks(m.Profile.Answer, func(content string) {
+			send(chunk(&chatDelta{Content: content}, nil))
+		})
+		opts = append(opts, compose.WithCallbacks(answerHandler))
+		wait = func() {
+			waitTools()
+			waitAnswer()
+		}
+	}
+	opts = append(opts, approvals.runOptions(approvalID)...)
+
+	sr, err := m.Profile.Agent.Stream(ctx, msgs, opts...)
+	if err != nil {
+		wait()
+		if !interrupted(err) {
+			fail(err)
+		}
+		return
+	}
+	defer sr.Close()
+
+	var respMsgs []*schema.Message
+	for {
+		c, err := sr.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			wait()
+			if !interrupted(err) {
+				fail(err)
+			}
+			return
+		}
+		respMsgs = append(respMsgs, c...)
+	}
+	wait()
+
+	// tool_agent：回答取自工具结果，运行结束后一次发送
+	if m.Profile.Answer == nil {
+		if out := m.Profile.Output(respMsgs); out != "" {
+			send(chunk(&chatDelta{Content: out}, nil))
+		}
+	}
+	send(chunk(&chatDelta{}, &stop))
+	if includeUsage {
+		c := *completion
+		c.Choices = []chatChoice{}
+		c.Usage = usageOf(msgs, respMsgs)
+		send(c)
+	}
+	done()
+}
+
+// approvalNotice 运行暂停时回复给 OpenAI 客户端的提示
+func approvalNotice(a *pendingApproval) string {
+	var names []string
+	for _, tc := range a.ToolCalls {
+		if tc.RequiresApproval {
+			names = append(names, tc.Name)
+		}
+	}
+	return fmt.Sprintf("调用工具 %s 需要人工审批，请通过 POST /approvals/%s 批准、修改参数或拒绝后获取结果。", strings.Join(names, "、"), a.ID)
+}
+
+// lastUserInput 取最后一条用户消息，作为待审批记录中的输入
+func lastUserInput(msgs []*schema.Message) string {
+	for i := len(msgs) - 1; i >= 0; i-- {
+		if msgs[i].Role == schema.User {
+			return msgs[i].Content
+		}
+	}
+	return ""
+}
+
+func findOpenAIModel(models []openAIModel, id string) (openAIModel, bool) {
+	if id == "" && len(models) > 0 {
+		return models[0], true
+	}
+	for _, m := range models {
+		if m.ID == id {
+			return m, true
+		}
+	}
+	return openAIModel{}, false
+}
+
+// toSchemaMessages 把 OpenAI 消息转为 eino 消息
+func toSchemaMessages(in []chatMessage) ([]*schema.Message, error) {
+	out := make([]*schema.Message, 0, len(in))
+	for i, m := range in {
+		msg := &schema.Message{Content: string(m.Content), Name: m.Name}
+		switch m.Role {
+		case "system", "developer":
+			msg.Role = schema.System
+		case "user":
+			msg.Role = schema.User
+		case "assistant":
+			msg.Role = schema.Assistant
+			for _, tc := range m.ToolCalls {
+				msg.ToolCalls = append(msg.ToolCalls, schema.ToolCall{
+					ID:       tc.ID,
+					Type:     tc.Type,
+					Function: schema.FunctionCall{Name: tc.Function.Name, Arguments: tc.Function.Arguments},
+				})
+			}
+		case "tool":
+			msg.Role = schema.Tool
+			msg.ToolCallID = m.ToolCallID
+		default:
+			return nil, fmt.Errorf("messages[%d]: 不支持的 role %q", i, m.Role)
+		}
+		out = append(out, msg)
+	}
+	return out, nil
+}
+
+// chatModelOptions 把请求中的采样参数转为模型调用选项
+func chatModelOptions(req chatCompletionRequest) []compose.Option {
+	var opts []model.Option
+	if req.Temperature != nil {
+		opts = append(opts, model.WithTemperature(*req.Temperature))
+	}
+	if req.TopP != nil {
+		opts = append(opts, model.WithTopP(*req.TopP))
+	}
+	if req.MaxTokens != nil {
+		opts = append(opts, model.WithMaxTokens(*req.MaxTokens))
+	}
+	if len(opts) == 0 {
+		return nil
+	}
+	return []compose.Option{compose.WithChatModelOption(opts...)}
+}
+
+// toolActivity 从 agent 产生的消息中配对工具调用与工具结果
+func toolActivity(msgs []*schema.Message) []chatToolActivity {
+	results := make(map[string]string)
+	for _, m := range msgs {
+		if m.Role == schema.Tool {
+			results[m.ToolCallID] = extractContentFromJSON(m.Content)
+		}
+	}
+	var out []chatToolActivity
+	for _, m := range msgs {
+		for _, tc := range m.ToolCalls {
+			out = append(out, chatToolActivity{streamToolEvent: streamToolEvent{
+				ID:        tc.ID,
+				Name:      tc.Function.Name,
+				Arguments: tc.Function.Arguments,
+				Result:    results[tc.ID],
+			}})
+		}
+	}
+	return out
+}
+
+// usageOf 汇总各次模型调用返回的 token 用量；模型未返回用量时按字符数估算
+func usageOf(input, output []*schema.Message) *chatUsage {
+	u := &chatUsage{}
+	for _, m := range output {
+		if m.ResponseMeta != nil && m.ResponseMeta.Usage != nil {
+			u.PromptTokens += m.ResponseMeta.Usage.PromptTokens
+			u.CompletionTokens += m.ResponseMeta.Usage.CompletionTokens
+		}
+	}
+	if u.PromptTokens == 0 && u.CompletionTokens == 0 {
+		for _, m := range input {
+			u.PromptTokens += estimateTokens(m)
+		}
+		for _, m := range output {
+			if m.Role == schema.Assistant {
+				u.CompletionTokens += estimateTokens(m)
+			}
+		}
+	}
+	u.TotalTokens = u.PromptTokens + u.CompletionTokens
+	return u
+}
+
+func newCompletionID() string {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		log.Printf("generate completion id error: %v", err)
+	}
+	return "chatcmpl-" + hex.EncodeToString(b)
+}
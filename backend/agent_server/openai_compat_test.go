@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aistudiolabx/eino-demo/backend/agenttest"
+	"github.com/aistudiolabx/eino-demo/backend/config"
+	"github.com/aistudiolabx/eino-demo/backend/mcp_server/mcptest"
+	"github.com/cloudwego/eino/schema"
+)
+
+// streamCompletion 以 stream=true 调用 /v1/chat/completions，返回拼接的 content、content delta 数、工具事件数与最后的 finish_reason
+func streamCompletion(t *testing.T, s *testServer, model, input string) (content string, deltas, toolEvents int, finish string) {
+	t.Helper()
+	body, _ := json.Marshal(map[string]any{
+		"model":    model,
+		"stream":   true,
+		"messages": []map[string]string{{"role": "user", "content": input}},
+	})
+	resp, err := s.Client().Post(s.URL+"/v1/chat/completions", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("stream request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var sb strings.Builder
+	sc := bufio.NewScanner(resp.Body)
+	for sc.Scan() {
+		data, ok := strings.CutPrefix(sc.Text(), "data: ")
+		if !ok || data == "[DONE]" {
+			continue
+		}
+		var c chatCompletionResponse
+		if err := json.Unmarshal([]byte(data), &c); err != nil {
+			t.Fatalf("decode chunk %s: %v", data, err)
+		}
+		if len(c.Choices) == 0 {
+			t.Fatalf("chunk without choices: %s", data)
+		}
+		ch := c.Choices[0]
+		if ch.Delta != nil {
+			if ch.Delta.Content != "" {
+				sb.WriteString(ch.Delta.Content)
+				deltas++
+			}
+			if ch.Delta.ToolActivity != nil {
+				toolEvents++
+			}
+		}
+		if ch.FinishReason != nil {
+			finish = *ch.FinishReason
+		}
+	}
+	return sb.String(), deltas, toolEvents, finish
+}
+
+func TestChatCompletionsStreamMatchesNonStream(t *testing.T) {
+	for _, tc := range []struct {
+		model string
+		turns []agenttest.Turn
+		want  string
+		// live 回答按模型的增量输出分多个 delta 发送
+		live bool
+	}{
+		{
+			// 工具调用轮次中模型写的文字不属于回答
+			model: "eino-agent",
+			turns: []agenttest.Turn{
+				{Message: schema.AssistantMessage("让我查一下。", []schema.ToolCall{agenttest.ToolCall("call_1", "weather", `{"city":"Beijing"}`)})},
+				agenttest.Reply("北京晴，21.5°C。"),
+			},
+			want: "北京晴，21.5°C。",
+			live: true,
+		},
+		{
+			// 规划与步骤执行的输出不属于回答，只转发 respond 节点
+			model: "eino-plan-agent",
+			turns: []agenttest.Turn{
+				agenttest.Reply(`{"steps": ["查询北京天气"]}`),
+				agenttest.CallTools(agenttest.ToolCall("call_1", "weather", `{"city":"Beijing"}`)),
+				agenttest.Reply("北京晴，21.5°C"),
+				agenttest.Reply("北京今天晴，21.5°C，适合出门。"),
+			},
+			want: "北京今天晴，21.5°C，适合出门。",
+			live: true,
+		},
+		{
+			// tool_agent 的回答是工具结果，运行结束后一次发送
+			model: "eino-tool-agent",
+			turns: []agenttest.Turn{agenttest.CallTools(agenttest.ToolCall("call_1", "novel_to_script", `{"text":"从前有座山"}`))},
+			want:  "第一幕：山。",
+		},
+	} {
+		t.Run(tc.model, func(t *testing.T) {
+			up := &mcptest.Upstream{Script: "第一幕：山。"}
+			noApproval := func(cfg *config.AgentConfig) { cfg.Approval.Tools = nil }
+
+			s := newTestServer(t, agenttest.NewFakeChatModel(tc.turns...), up, noApproval)
+			var resp chatCompletionResponse
+			req := map[string]any{"model": tc.model, "messages": []map[string]string{{"role": "user", "content": "hi"}}}
+			if status := s.do(t, http.MethodPost, "/v1/chat/completions", req, &resp); status != http.StatusOK {
+				t.Fatalf("status = %d", status)
+			}
+			if got := string(resp.Choices[0].Message.Content); got != tc.want {
+				t.Errorf("non-stream content = %q, want %q", got, tc.want)
+			}
+
+			s = newTestServer(t, agenttest.NewFakeChatModel(tc.turns...), up, noApproval)
+			content, deltas, toolEvents, finish := streamCompletion(t, s, tc.model, "hi")
+			if content != tc.want {
+				t.Errorf("stream content = %q, want %q", content, tc.want)
+			}
+			if tc.live && deltas < 2 {
+				t.Errorf("stream content deltas = %d, want the answer forwarded incrementally", deltas)
+			}
+			if toolEvents != 2 {
+				t.Errorf("stream tool events = %d, want tool_start and tool_end", toolEvents)
+			}
+			if finish != "stop" {
+				t.Errorf("finish_reason = %q, want stop", finish)
+			}
+		})
+	}
+}
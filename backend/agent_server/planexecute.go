@@ -0,0 +1,412 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/compose"
+	"github.com/cloudwego/eino/schema"
+)
+
+// plan_agent：先规划再执行。适合需要多个工具、且后一步依赖前一步结果的复合请求，例如
+// “查一下杭州的天气，然后以这种天气为背景写一段短剧本”。
+//
+//	planner ─┬─> next_step → executor ─┬─> next_step（还有步骤）
+//	         │                          ├─> replanner（步骤失败）─┬─> next_step
+//	         │                          │                        └─> summarize
+//	         │                          └─> summarize（全部完成）
+//	         └─> summarize（无需步骤） → respond → finish
+//
+// executor 是嵌入的 ReAct 子图（与 /agent 相同），每个步骤运行一次；模型调用出错或达到迭代上限时
+// 这一步记为失败并进入重新规划。步骤中需要审批的工具调用会让整个图暂停，审批后从子图内部继续。执行计划附在最终回复的 Extra 中，由 planFromMessages 取出。
+
+// plan_agent 图中各节点的 key
+const (
+	nodeKeyPlanner   = "planner"
+	nodeKeyNextStep  = "next_step"
+	nodeKeyExecutor  = "executor"
+	nodeKeyReplanner = "replanner"
+	nodeKeySummarize = "summarize"
+	nodeKeyRespond   = "respond"
+	nodeKeyFinish    = "finish"
+)
+
+// planExtraKey 最终回复 Message.Extra 中保存执行计划的键
+const planExtraKey = "eino_demo_plan"
+
+// 步骤状态
+const (
+	stepPending = "pending"
+	stepDone    = "done"
+	stepFailed  = "failed"
+)
+
+// stepFailedMarker 执行者无法完成步骤时回复的前缀
+const stepFailedMarker = "[FAILED]"
+
+// plannerPrompt 规划时的系统提示，参数依次为可用工具与最多步骤数
+const plannerPrompt = `你是一个任务规划助手。请把用户最新的请求拆成按顺序执行的步骤，每一步交给一个可以调用工具的执行者完成，执行者能看到前面各步骤的结果。
+
+可用工具：
+%s
+
+要求：
+- 每一步是一句明确的指令，只做一件事；后面的步骤可以引用前面步骤的结果。
+- 最多 %d 步；闲聊或不需要工具就能直接回答的请求，返回空列表。
+- 只输出 JSON，格式为 {"steps": ["第一步", "第二步"]}，不要输出其他内容。`
+
+// replannerPrompt 步骤失败后重新规划时的系统提示，参数依次为可用工具与最多步骤数
+const replannerPrompt = `你是一个任务规划助手。执行计划时有一步失败了，请根据已执行步骤的结果与失败原因，重新规划剩余的步骤。
+
+可用工具：
+%s
+
+要求：
+- 只列出还需要执行的步骤，不要重复已完成的步骤；可以换一种做法重试失败的步骤，也可以跳过它。
+- 最多 %d 步；无法继续时返回空列表，系统会根据已有结果回答用户。
+- 只输出 JSON，格式为 {"steps": ["下一步", "再下一步"]}，不要输出其他内容。`
+
+// planStep 执行计划中的一步
+type planStep struct {
+	// Task 这一步要完成的指令
+	Task string `json:"task"`
+	// Status pending、done 或 failed
+	Status string `json:"status"`
+	// Result 完成时为执行结果，失败时为失败原因
+	Result string `json:"result,omitempty"`
+}
+
+// executionPlan 一次运行的执行计划，随响应返回；重新规划时失败的步骤保留，未执行的步骤被新步骤替换
+type executionPlan struct {
+	Steps []*planStep `json:"steps"`
+	// Replans 已重新规划的次数
+	Replans int `json:"replans"`
+}
+
+// current 第一个未执行的步骤，没有时返回 nil
+func (p *executionPlan) current() (int, *planStep) {
+	for i, s := range p.Steps {
+		if s.Status == stepPending {
+			return i, s
+		}
+	}
+	return -1, nil
+}
+
+// progress 已执行步骤的说明，提供给执行者、重新规划与最终回答
+func (p *executionPlan) progress() string {
+	var b strings.Builder
+	for i, s := range p.Steps {
+		if s.Status == stepPending {
+			continue
+		}
+		fmt.Fprintf(&b, "%d. [%s] %s\n", i+1, s.Status, s.Task)
+		if s.Status == stepFailed {
+			fmt.Fprintf(&b, "失败原因：%s\n", s.Result)
+		} else {
+			fmt.Fprintf(&b, "结果：%s\n", s.Result)
+		}
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// planState plan_agent 单次运行的图状态
+type planState struct {
+	// Input 图的输入（系统提示、会话历史与本轮用户输入）
+	Input []*schema.Message
+	// Plan 当前的执行计划
+	Plan *executionPlan
+}
+
+func init() {
+	schema.RegisterName[*planState]("eino_demo_plan_state")
+	schema.RegisterName[*executionPlan]("eino_demo_execution_plan")
+}
+
+// planFromMessages 取出 plan_agent 附在最终回复上的执行计划，其他 agent 的输出返回 nil
+func planFromMessages(msgs []*schema.Message) *executionPlan {
+	if len(msgs) == 0 {
+		return nil
+	}
+	plan, _ := msgs[len(msgs)-1].Extra[planExtraKey].(*executionPlan)
+	return plan
+}
+
+// buildPlanExecuteAgent 编译 plan_agent 图，签名与 buildAgent 相同，可作为 agentProfile.Build
+func buildPlanExecuteAgent(ctx context.Context, chatModel model.ToolCallingChatModel, tools []tool.BaseTool, cfg agentConfig) (compose.Runnable[[]*schema.Message, []*schema.Message], error) {
+	maxSteps := cfg.MaxPlanSteps
+	if maxSteps <= 0 {
+		maxSteps = defaultMaxPlanSteps
+	}
+	catalog := &toolCatalog{}
+	if err := catalog.set(ctx, tools); err != nil {
+		return nil, err
+	}
+	// 工具出错时交给执行者判断步骤是否失败；模型调用出错或达到迭代上限时以 [FAILED] 回复结束这一步，
+	// 进入重新规划，而不是让整次运行失败
+	executorCfg := cfg
+	executorCfg.ToolErrorsAsResults = true
+	executorCfg.FailureAnswer = stepFailedMarker
+	executor, err := newAgentGraph(ctx, chatModel, tools, executorCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	g := compose.NewGraph[[]*schema.Message, []*schema.Message](
+		compose.WithGenLocalState(func(ctx context.Context) *planState {
+			return &planState{}
+		}),
+	)
+
+	// 规划：记录输入，让模型给出步骤列表
+	plannerPreHandle := func(ctx context.Context, in []*schema.Message, state *planState) ([]*schema.Message, error) {
+		state.Input = in
+		msgs := []*schema.Message{schema.SystemMessage(fmt.Sprintf(plannerPrompt, catalog.describe(), maxSteps))}
+		return append(msgs, conversation(in)...), nil
+	}
+	plannerPostHandle := func(ctx context.Context, out *schema.Message, state *planState) (*schema.Message, error) {
+		steps, ok := parseSteps(out.Content, maxSteps)
+		if !ok {
+			// 模型没有按格式输出时退化为单步计划，由执行者直接处理整个请求
+			steps = []*planStep{{Task: lastUserInput(state.Input), Status: stepPending}}
+		}
+		state.Plan = &executionPlan{Steps: steps}
+		return out, nil
+	}
+	if err := g.AddChatModelNode(nodeKeyPlanner, chatModel,
+		compose.WithStatePreHandler(plannerPreHandle),
+		compose.WithStatePostHandler(plannerPostHandle),
+		compose.WithNodeName(nodeKeyPlanner),
+	); err != nil {
+		return nil, err
+	}
+
+	// 为当前步骤构造执行者的输入：系统提示 + 用户请求、已完成步骤的结果与当前步骤
+	if err := g.AddLambdaNode(nodeKeyNextStep, compose.InvokableLambda(func(ctx context.Context, _ any) ([]*schema.Message, error) {
+		var msgs []*schema.Message
+		err := compose.ProcessState(ctx, func(_ context.Context, state *planState) error {
+			i, step := state.Plan.current()
+			if step == nil {
+				return fmt.Errorf("plan has no pending step")
+			}
+			var b strings.Builder
+			fmt.Fprintf(&b, "用户的请求：%s\n\n", lastUserInput(state.Input))
+			if done := state.Plan.progress(); done != "" {
+				fmt.Fprintf(&b, "已执行的步骤：\n%s\n\n", done)
+			}
+			fmt.Fprintf(&b, "当前步骤（第 %d 步）：%s\n\n", i+1, step.Task)
+			fmt.Fprintf(&b, "只完成当前步骤，需要时调用工具，然后简要给出这一步的结果。如果无法完成（例如工具返回错误或缺少必要信息），回复以 %s 开头并说明原因。", stepFailedMarker)
+			msgs = append(systemMessages(state.Input), schema.UserMessage(b.String()))
+			return nil
+		})
+		return msgs, err
+	}), compose.WithNodeName(nodeKeyNextStep)); err != nil {
+		return nil, err
+	}
+
+	// 执行：ReAct 子图完成当前步骤，最终回答即为步骤结果
+	executorPostHandle := func(ctx context.Context, out []*schema.Message, state *planState) ([]*schema.Message, error) {
+		_, step := state.Plan.current()
+		if step == nil {
+			return out, nil
+		}
+		result := strings.TrimSpace(finalAnswer(out))
+		if reason, failed := strings.CutPrefix(result, stepFailedMarker); failed {
+			step.Status, step.Result = stepFailed, strings.TrimSpace(reason)
+		} else {
+			step.Status, step.Result = stepDone, result
+		}
+		return out, nil
+	}
+	if err := g.AddGraphNode(nodeKeyExecutor, executor,
+		compose.WithGraphCompileOptions(agentCompileOptions(cfg)...),
+		compose.WithStatePostHandler(executorPostHandle),
+		compose.WithNodeName(nodeKeyExecutor),
+	); err != nil {
+		return nil, err
+	}
+
+	// 重新规划：用新步骤替换未执行的步骤
+	replannerPreHandle := func(ctx context.Context, _ []*schema.Message, state *planState) ([]*schema.Message, error) {
+		user := fmt.Sprintf("用户的请求：%s\n\n已执行的步骤：\n%s", lastUserInput(state.Input), state.Plan.progress())
+		return []*schema.Message{
+			schema.SystemMessage(fmt.Sprintf(replannerPrompt, catalog.describe(), maxSteps)),
+			schema.UserMessage(user),
+		}, nil
+	}
+	replannerPostHandle := func(ctx context.Context, out *schema.Message, state *planState) (*schema.Message, error) {
+		steps, _ := parseSteps(out.Content, maxSteps)
+		kept := state.Plan.Steps[:0]
+		for _, s := range state.Plan.Steps {
+			if s.Status != stepPending {
+				kept = append(kept, s)
+			}
+		}
+		state.Plan.Steps = append(kept, steps...)
+		state.Plan.Replans++
+		return out, nil
+	}
+	if err := g.AddChatModelNode(nodeKeyReplanner, chatModel,
+		compose.WithStatePreHandler(replannerPreHandle),
+		compose.WithStatePostHandler(replannerPostHandle),
+		compose.WithNodeName(nodeKeyReplanner),
+	); err != nil {
+		return nil, err
+	}
+
+	// 汇总：把各步骤的结果交给模型，回答用户最初的请求
+	if err := g.AddLambdaNode(nodeKeySummarize, compose.InvokableLambda(func(ctx context.Context, _ any) ([]*schema.Message, error) {
+		var msgs []*schema.Message
+		err := compose.ProcessState(ctx, func(_ context.Context, state *planState) error {
+			msgs = append(systemMessages(state.Input), conversation(state.Input)...)
+			if done := state.Plan.progress(); done != "" {
+				msgs = append(msgs, schema.UserMessage("为完成上面的请求，已执行以下步骤：\n"+done+"\n\n请根据这些结果直接回答我最初的请求，不要提及内部的执行步骤编号。"))
+			}
+			return nil
+		})
+		return msgs, err
+	}), compose.WithNodeName(nodeKeySummarize)); err != nil {
+		return nil, err
+	}
+	if err := g.AddChatModelNode(nodeKeyRespond, chatModel, compose.WithNodeName(nodeKeyRespond)); err != nil {
+		return nil, err
+	}
+	// 把执行计划附在最终回复上，作为图的输出
+	if err := g.AddLambdaNode(nodeKeyFinish, compose.InvokableLambda(func(ctx context.Context, msg *schema.Message) ([]*schema.Message, error) {
+		err := compose.ProcessState(ctx, func(_ context.Context, state *planState) error {
+			if msg.Extra == nil {
+				msg.Extra = make(map[string]any)
+			}
+			msg.Extra[planExtraKey] = state.Plan
+			return nil
+		})
+		return []*schema.Message{msg}, err
+	}), compose.WithNodeName(nodeKeyFinish)); err != nil {
+		return nil, err
+	}
+
+	// 还有未执行的步骤则继续执行，否则汇总
+	nextOrSummarize := func(ctx context.Context, _ *schema.Message) (string, error) {
+		next := nodeKeySummarize
+		err := compose.ProcessState(ctx, func(_ context.Context, state *planState) error {
+			if _, step := state.Plan.current(); step != nil {
+				next = nodeKeyNextStep
+			}
+			return nil
+		})
+		return next, err
+	}
+	nextEnds := map[string]bool{nodeKeyNextStep: true, nodeKeySummarize: true}
+	// 步骤失败时重新规划，次数用完则直接汇总已有结果；步骤成功时同上
+	afterStep := func(ctx context.Context, _ []*schema.Message) (string, error) {
+		next := nodeKeySummarize
+		err := compose.ProcessState(ctx, func(_ context.Context, state *planState) error {
+			plan := state.Plan
+			i, step := plan.current()
+			if i < 0 {
+				i = len(plan.Steps)
+			}
+			if i > 0 && plan.Steps[i-1].Status == stepFailed {
+				if plan.Replans < cfg.MaxReplans {
+					next = nodeKeyReplanner
+				}
+				return nil
+			}
+			if step != nil {
+				next = nodeKeyNextStep
+			}
+			return nil
+		})
+		return next, err
+	}
+
+	if err := g.AddEdge(compose.START, nodeKeyPlanner); err != nil {
+		return nil, err
+	}
+	if err := g.AddBranch(nodeKeyPlanner, compose.NewGraphBranch(nextOrSummarize, nextEnds)); err != nil {
+		return nil, err
+	}
+	if err := g.AddEdge(nodeKeyNextStep, nodeKeyExecutor); err != nil {
+		return nil, err
+	}
+	if err := g.AddBranch(nodeKeyExecutor, compose.NewGraphBranch(afterStep,
+		map[string]bool{nodeKeyNextStep: true, nodeKeyReplanner: true, nodeKeySummarize: true})); err != nil {
+		return nil, err
+	}
+	if err := g.AddBranch(nodeKeyReplanner, compose.NewGraphBranch(nextOrSummarize, nextEnds)); err != nil {
+		return nil, err
+	}
+	if err := g.AddEdge(nodeKeySummarize, nodeKeyRespond); err != nil {
+		return nil, err
+	}
+	if err := g.AddEdge(nodeKeyRespond, nodeKeyFinish); err != nil {
+		return nil, err
+	}
+	if err := g.AddEdge(nodeKeyFinish, compose.END); err != nil {
+		return nil, err
+	}
+
+	// 每个步骤经过 next_step、executor 两个节点，每次重新规划最多带来 maxSteps 个新步骤；
+	// 另有 planner、各次 replanner 与 summarize、respond、finish
+	maxRunSteps := 2*maxSteps*(cfg.MaxReplans+1) + cfg.MaxReplans + 4
+	opts := []compose.GraphCompileOption{
+		compose.WithNodeTriggerMode(compose.AnyPredecessor),
+		compose.WithMaxRunSteps(maxRunSteps),
+	}
+	if cfg.CheckPointStore != nil {
+		opts = append(opts, compose.WithCheckPointStore(cfg.CheckPointStore))
+	}
+	return g.Compile(ctx, opts...)
+}
+
+// parseSteps 解析模型输出的 {"steps": [...]}，允许前后有多余文字或代码块标记；最多保留 maxSteps 步
+func parseSteps(content string, maxSteps int) ([]*planStep, bool) {
+	start, end := strings.Index(content, "{"), strings.LastIndex(content, "}")
+	if start < 0 || end < start {
+		return nil, false
+	}
+	var out struct {
+		Steps []string `json:"steps"`
+	}
+	if err := json.Unmarshal([]byte(content[start:end+1]), &out); err != nil {
+		return nil, false
+	}
+	steps := make([]*planStep, 0, len(out.Steps))
+	for _, task := range out.Steps {
+		if task = strings.TrimSpace(task); task != "" && len(steps) < maxSteps {
+			steps = append(steps, &planStep{Task: task, Status: stepPending})
+		}
+	}
+	return steps, true
+}
+
+// systemMessages 取输入开头的系统提示
+func systemMessages(msgs []*schema.Message) []*schema.Message {
+	var out []*schema.Message
+	for _, m := range msgs {
+		if m.Role != schema.System {
+			break
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+// conversation 取会话中的用户与 assistant 文字消息，去掉系统提示、工具调用与工具结果，
+// 供不绑定工具的规划与汇总调用使用
+func conversation(msgs []*schema.Message) []*schema.Message {
+	var out []*schema.Message
+	for _, m := range msgs {
+		switch {
+		case m.Role == schema.User:
+			out = append(out, m)
+		case m.Role == schema.Assistant && len(m.ToolCalls) == 0 && m.Content != "":
+			out = append(out, schema.AssistantMessage(m.Content, nil))
+		}
+	}
+	return out
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aistudiolabx/eino-demo/backend/agenttest"
+	"github.com/cloudwego/eino/schema"
+)
+
+// planSummary 执行计划的简要形式：每步为 “状态:任务=结果”，以 “; ” 连接
+func planSummary(p *executionPlan) string {
+	if p == nil {
+		return "<nil>"
+	}
+	var steps []string
+	for _, s := range p.Steps {
+		steps = append(steps, s.Status+":"+s.Task+"="+s.Result)
+	}
+	return strings.Join(steps, "; ")
+}
+
+// lastContent 模型第 i 次调用输入中最后一条消息的内容
+func lastContent(cm *agenttest.FakeChatModel, i int) string {
+	calls := cm.Calls()
+	if i >= len(calls) || len(calls[i]) == 0 {
+		return ""
+	}
+	return calls[i][len(calls[i])-1].Content
+}
+
+func TestPlanAgentEndpoint(t *testing.T) {
+	cm := agenttest.NewFakeChatModel(
+		// 规划结果带代码块标记
+		agenttest.Reply("```json\n{\"steps\": [\"查询北京的天气\", \"根据天气给出穿衣建议\"]}\n```"),
+		agenttest.CallTools(agenttest.ToolCall("call_1", "weather", `{"city":"Beijing"}`)),
+		agenttest.Reply("北京晴，21.5°C"),
+		agenttest.Reply("穿薄外套"),
+		agenttest.Reply("北京今天晴，21.5°C，建议穿薄外套。"),
+	)
+	s := newTestServer(t, cm, nil)
+
+	var resp agentResponse
+	if status := s.do(t, http.MethodPost, "/plan_agent", agentRequest{Input: "北京天气怎么样，该穿什么"}, &resp); status != http.StatusOK {
+		t.Fatalf("status = %d, error = %s", status, resp.Error)
+	}
+	if resp.Output != "北京今天晴，21.5°C，建议穿薄外套。" {
+		t.Errorf("output = %q", resp.Output)
+	}
+	want := "done:查询北京的天气=北京晴，21.5°C; done:根据天气给出穿衣建议=穿薄外套"
+	if got := planSummary(resp.Plan); got != want || resp.Plan.Replans != 0 {
+		t.Errorf("plan = %s (replans %d), want %s", got, resp.Plan.Replans, want)
+	}
+	if cm.Remaining() != 0 {
+		t.Errorf("%d scripted turns left", cm.Remaining())
+	}
+	// 第二步的执行者能看到第一步的结果；最终回答基于全部步骤的结果
+	if in := lastContent(cm, 3); !strings.Contains(in, "1. [done] 查询北京的天气\n结果：北京晴，21.5°C") || !strings.Contains(in, "当前步骤（第 2 步）：根据天气给出穿衣建议") {
+		t.Errorf("second step input = %q", in)
+	}
+	if in := lastContent(cm, 4); !strings.Contains(in, "2. [done] 根据天气给出穿衣建议\n结果：穿薄外套") {
+		t.Errorf("respond input = %q", in)
+	}
+}
+
+func TestPlanExecuteReplan(t *testing.T) {
+	ctx := context.Background()
+	cm := agenttest.NewFakeChatModel(
+		agenttest.Reply(`{"steps": ["查询天气", "写一段剧本"]}`),
+		agenttest.Reply("[FAILED] 不知道要查哪个城市"),
+		// 未执行的“写一段剧本”被新步骤替换
+		agenttest.Reply(`好的，新的计划：{"steps": ["查询北京的天气"]}`),
+		agenttest.Reply("北京晴"),
+		agenttest.Reply("北京今天晴。"),
+	)
+	r, err := buildPlanExecuteAgent(ctx, cm, nil, agentConfig{MaxReplans: 1})
+	if err != nil {
+		t.Fatalf("buildPlanExecuteAgent: %v", err)
+	}
+
+	out, err := r.Invoke(ctx, []*schema.Message{schema.UserMessage("今天天气怎么样")})
+	if err != nil {
+		t.Fatalf("Invoke: %v", err)
+	}
+	plan := planFromMessages(out)
+	want := "failed:查询天气=不知道要查哪个城市; done:查询北京的天气=北京晴"
+	if got := planSummary(plan); got != want || plan.Replans != 1 {
+		t.Errorf("plan = %s (replans %d), want %s", got, plan.Replans, want)
+	}
+	if got := finalAnswer(out); got != "北京今天晴。" {
+		t.Errorf("finalAnswer = %q", got)
+	}
+	if in := lastContent(cm, 2); !strings.Contains(in, "1. [failed] 查询天气\n失败原因：不知道要查哪个城市") {
+		t.Errorf("replanner input = %q", in)
+	}
+}
+
+func TestPlanExecuteReplanLimit(t *testing.T) {
+	ctx := context.Background()
+	for _, tc := range []struct {
+		name       string
+		maxReplans int
+		turns      []agenttest.Turn
+		want       string
+	}{
+		{
+			name: "no replans",
+			turns: []agenttest.Turn{
+				agenttest.Reply(`{"steps": ["查询天气", "写一段剧本"]}`),
+				agenttest.Reply("[FAILED] 天气服务不可用"),
+				agenttest.Reply("抱歉，暂时查不到天气。"),
+			},
+			// 次数用完直接汇总，剩余步骤不再执行
+			want: "failed:查询天气=天气服务不可用; pending:写一段剧本=",
+		},
+		{
+			name:       "limit reached",
+			maxReplans: 1,
+			turns: []agenttest.Turn{
+				agenttest.Reply(`{"steps": ["查询天气"]}`),
+				agenttest.Reply("[FAILED] 天气服务不可用"),
+				agenttest.Reply(`{"steps": ["换一种方式查询天气"]}`),
+				agenttest.Reply("[FAILED] 仍然不可用"),
+				agenttest.Reply("抱歉，暂时查不到天气。"),
+			},
+			want: "failed:查询天气=天气服务不可用; failed:换一种方式查询天气=仍然不可用",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cm := agenttest.NewFakeChatModel(tc.turns...)
+			r, err := buildPlanExecuteAgent(ctx, cm, nil, agentConfig{MaxReplans: tc.maxReplans})
+			if err != nil {
+				t.Fatalf("buildPlanExecuteAgent: %v", err)
+			}
+			out, err := r.Invoke(ctx, []*schema.Message{schema.UserMessage("北京天气，再写一段剧本")})
+			if err != nil {
+				t.Fatalf("Invoke: %v", err)
+			}
+			plan := planFromMessages(out)
+			if got := planSummary(plan); got != tc.want || plan.Replans != tc.maxReplans {
+				t.Errorf("plan = %s (replans %d), want %s", got, plan.Replans, tc.want)
+			}
+			if got := finalAnswer(out); got != "抱歉，暂时查不到天气。" {
+				t.Errorf("finalAnswer = %q", got)
+			}
+			if cm.Remaining() != 0 {
+				t.Errorf("%d scripted turns left", cm.Remaining())
+			}
+		})
+	}
+}
+
+// 执行者的模型调用出错或达到迭代上限时，这一步记为失败并重新规划，而不是让整次运行失败
+func TestPlanExecuteExecutorErrors(t *testing.T) {
+	ctx := context.Background()
+	_, tools := mcpTools(t, nil)
+	for _, tc := range []struct {
+		name   string
+		step   []agenttest.Turn
+		reason string
+	}{
+		{
+			name:   "model error",
+			step:   []agenttest.Turn{{Err: errors.New("connection refused")}},
+			reason: "模型调用失败：connection refused",
+		},
+		{
+			name:   "max iterations",
+			step:   []agenttest.Turn{agenttest.CallTools(agenttest.ToolCall("call_1", "weather", `{"city":"Beijing"}`))},
+			reason: errMaxIterations.Error() + "（上限 1 次）",
+		},
+	} {
+		for _, stream := range []bool{false, true} {
+			name := tc.name
+			if stream {
+				name += " stream"
+			}
+			t.Run(name, func(t *testing.T) {
+				turns := append([]agenttest.Turn{agenttest.Reply(`{"steps": ["查询北京的天气"]}`)}, tc.step...)
+				turns = append(turns, agenttest.Reply(`{"steps": []}`), agenttest.Reply("抱歉，暂时查不到天气。"))
+				cm := agenttest.NewFakeChatModel(turns...)
+				r, err := buildPlanExecuteAgent(ctx, cm, tools, agentConfig{MaxIterations: 1, MaxReplans: 1})
+				if err != nil {
+					t.Fatalf("buildPlanExecuteAgent: %v", err)
+				}
+
+				in := []*schema.Message{schema.UserMessage("北京天气")}
+				var out []*schema.Message
+				if stream {
+					sr, err := r.Stream(ctx, in)
+					if err != nil {
+						t.Fatalf("Stream: %v", err)
+					}
+					defer sr.Close()
+					for {
+						chunk, err := sr.Recv()
+						if errors.Is(err, io.EOF) {
+							break
+						}
+						if err != nil {
+							t.Fatalf("Recv: %v", err)
+						}
+						out = append(out, chunk...)
+					}
+				} else if out, err = r.Invoke(ctx, in); err != nil {
+					t.Fatalf("Invoke: %v", err)
+				}
+
+				plan := planFromMessages(out)
+				if want := "failed:查询北京的天气=" + tc.reason; planSummary(plan) != want || plan.Replans != 1 {
+					t.Errorf("plan = %s (replans %d), want %s", planSummary(plan), plan.Replans, want)
+				}
+				if got := finalAnswer(out); got != "抱歉，暂时查不到天气。" {
+					t.Errorf("finalAnswer = %q", got)
+				}
+				if cm.Remaining() != 0 {
+					t.Errorf("%d scripted turns left", cm.Remaining())
+				}
+			})
+		}
+	}
+}
+
+func TestPlanExecutePlannerFallback(t *testing.T) {
+	ctx := context.Background()
+	for _, tc := range []struct {
+		name    string
+		planner string
+		turns   []agenttest.Turn
+		want    string
+	}{
+		// 没有按格式输出时整个请求作为唯一的步骤
+		{name: "not json", planner: "我来帮你查一下", turns: []agenttest.Turn{agenttest.Reply("晴"), agenttest.Reply("今天晴。")}, want: "done:今天天气怎么样=晴"},
+		// 空列表表示无需工具，直接回答
+		{name: "no steps", planner: `{"steps": []}`, turns: []agenttest.Turn{agenttest.Reply("你好！")}, want: ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cm := agenttest.NewFakeChatModel(append([]agenttest.Turn{agenttest.Reply(tc.planner)}, tc.turns...)...)
+			r, err := buildPlanExecuteAgent(ctx, cm, nil, agentConfig{})
+			if err != nil {
+				t.Fatalf("buildPlanExecuteAgent: %v", err)
+			}
+			out, err := r.Invoke(ctx, []*schema.Message{schema.SystemMessage("系统提示"), schema.UserMessage("今天天气怎么样")})
+			if err != nil {
+				t.Fatalf("Invoke: %v", err)
+			}
+			if got := planSummary(planFromMessages(out)); got != tc.want {
+				t.Errorf("plan = %s, want %s", got, tc.want)
+			}
+			if cm.Remaining() != 0 {
+				t.Errorf("%d scripted turns left", cm.Remaining())
+			}
+		})
+	}
+}
+
+func TestParseSteps(t *testing.T) {
+	for _, tc := range []struct {
+		content string
+		ok      bool
+		want    []string
+	}{
+		{`{"steps": ["a", "b"]}`, true, []string{"a", "b"}},
+		{"```json\n{\"steps\": [\" a \", \"\", \"b\"]}\n```", true, []string{"a", "b"}},
+		{`计划如下：{"steps": ["a", "b", "c", "d"]} 完毕`, true, []string{"a", "b", "c"}},
+		{`{"steps": []}`, true, nil},
+		{`{"steps": "a"}`, false, nil},
+		{"没有 JSON", false, nil},
+		{`} {`, false, nil},
+	} {
+		steps, ok := parseSteps(tc.content, 3)
+		var got []string
+		for _, s := range steps {
+			if s.Status != stepPending {
+				t.Errorf("parseSteps(%q) step %q status = %s, want pending", tc.content, s.Task, s.Status)
+			}
+			got = append(got, s.Task)
+		}
+		if ok != tc.ok || strings.Join(got, ",") != strings.Join(tc.want, ",") {
+			t.Errorf("parseSteps(%q) = %v, %v, want %v, %v", tc.content, got, ok, tc.want, tc.ok)
+		}
+	}
+}
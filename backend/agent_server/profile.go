@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+
+	"github.com/aistudiolabx/eino-demo/backend/config"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/compose"
+	"github.com/cloudwego/eino/schema"
+)
+
+// agentProfile 一个对外 agent 的配置：允许绑定哪些工具、默认使用哪个提示模板，以及当前编译好的图
+type agentProfile struct {
+	// Name profile 名称，与配置中 agent.tools、agent.prompts 下的键一致
+	Name string
+	// Endpoints 使用该 profile 的接口，仅用于 /tools 展示
+	Endpoints []string
+	// Prompt 默认系统提示模板名
+	Prompt string
+	// Allow 允许绑定的工具名或 glob（path.Match 语法），为空则不绑定任何工具
+	Allow []string
+	// Config 构建图的配置
+	Config agentConfig
+	// Output 从 agent 产生的消息中取最终回复，默认为 finalAnswer
+	Output func(msgs []*schema.Message) string
+	// Answer 生成最终回复的模型节点，流式接口据此实时转发回复的增量文本，默认为 ReAct 循环的 chat_model；
+	// 为 nil 时回复不来自模型输出（tool_agent 取工具结果），只能在运行结束后由 Output 取出
+	Answer *answerNode
+	// Build 编译图的方式，默认为 buildAgent（ReAct 循环）
+	Build agentBuilder
+
+	// Agent 当前编译好的图，工具变化时整体替换
+	Agent *agentHolder
+	// Tools 当前实际绑定的工具，供提示模板与 /tools 使用
+	Tools *toolCatalog
+}
+
+func newAgentProfile(name, prompt string, allow []string, cfg agentConfig, endpoints ...string) *agentProfile {
+	return &agentProfile{
+		Name:      name,
+		Endpoints: endpoints,
+		Prompt:    prompt,
+		Allow:     allow,
+		Config:    cfg,
+		Output:    finalAnswer,
+		Answer:    &answerNode{Name: nodeKeyChatModel, Tools: true},
+		Build:     buildAgent,
+		Agent:     &agentHolder{},
+		Tools:     &toolCatalog{},
+	}
+}
+
+// newProfiles 按配置创建对外的三个 profile：agent（ReAct 循环）、tool_agent（直接返回工具结果）与 plan_agent（先计划后执行），
+// 配置中 approval.tools 列出的工具调用前暂停等待审批，checkpoint 保存在 store 中
+func newProfiles(cfg config.AgentConfig, store ApprovalStore) (agent, toolAgent, planAgent *agentProfile) {
+	requiresApproval := func(name string) bool {
+		return toolAllowed(name, cfg.Approval.Tools)
+	}
+	agent = newAgentProfile("agent", cfg.Prompts.Agent, cfg.Tools.Agent, agentConfig{
+		MaxIterations:    cfg.MaxIterations,
+		RequiresApproval: requiresApproval,
+		CheckPointStore:  store,
+	}, "/agent", "/agent/stream", "/v1/chat/completions (eino-agent)")
+	toolAgent = newAgentProfile("tool_agent", cfg.Prompts.ToolAgent, cfg.Tools.ToolAgent, agentConfig{
+		MaxIterations:    cfg.MaxIterations,
+		ReturnToolResult: true,
+		RequiresApproval: requiresApproval,
+		CheckPointStore:  store,
+	}, "/tool_agent", "/v1/chat/completions (eino-tool-agent)")
+	toolAgent.Output = toolAgentOutput
+	toolAgent.Answer = nil
+	planAgent = newAgentProfile("plan_agent", cfg.Prompts.PlanAgent, cfg.Tools.PlanAgent, agentConfig{
+		MaxIterations:    cfg.MaxIterations,
+		RequiresApproval: requiresApproval,
+		CheckPointStore:  store,
+		MaxPlanSteps:     cfg.Plan.MaxSteps,
+		MaxReplans:       cfg.Plan.MaxReplans,
+	}, "/plan_agent", "/v1/chat/completions (eino-plan-agent)")
+	planAgent.Build = buildPlanExecuteAgent
+	planAgent.Answer = &answerNode{Name: nodeKeyRespond}
+	return agent, toolAgent, planAgent
+}
+
+// compiledProfile 已编译但尚未生效的 profile，所有 profile 都编译成功后再一起替换
+type compiledProfile struct {
+	profile *agentProfile
+	agent   compose.Runnable[[]*schema.Message, []*schema.Message]
+	tools   []tool.BaseTool
+}
+
+// build 从全部工具中筛出允许的工具并编译图；BindTools 与 ToolsNode 使用同一份筛选结果，
+// 模型即使臆造了未绑定的工具名，也只会走 ToolsNode 的未知工具分支
+func (p *agentProfile) build(ctx context.Context, chatModel model.ToolCallingChatModel, all []tool.BaseTool) (*compiledProfile, error) {
+	selected, err := selectTools(ctx, all, p.Allow)
+	if err != nil {
+		return nil, err
+	}
+	r, err := p.Build(ctx, chatModel, selected, p.Config)
+	if err != nil {
+		return nil, fmt.Errorf("build %s agent: %w", p.Name, err)
+	}
+	return &compiledProfile{profile: p, agent: r, tools: selected}, nil
+}
+
+// apply 让编译结果生效
+func (c *compiledProfile) apply(ctx context.Context) error {
+	if err := c.profile.Tools.set(ctx, c.tools); err != nil {
+		return err
+	}
+	c.profile.Agent.set(c.agent)
+	return nil
+}
+
+// rebuildProfiles 用新的工具集重新编译全部 profile，任一失败时都保留原来的图
+func rebuildProfiles(ctx context.Context, chatModel model.ToolCallingChatModel, profiles []*agentProfile, all []tool.BaseTool) error {
+	compiled := make([]*compiledProfile, 0, len(profiles))
+	for _, p := range profiles {
+		c, err := p.build(ctx, chatModel, all)
+		if err != nil {
+			return err
+		}
+		compiled = append(compiled, c)
+	}
+	for _, c := range compiled {
+		if err := c.apply(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// selectTools 按 allow 中的工具名或 glob 筛选工具，保持原有顺序
+func selectTools(ctx context.Context, tools []tool.BaseTool, allow []string) ([]tool.BaseTool, error) {
+	var selected []tool.BaseTool
+	for _, t := range tools {
+		info, err := t.Info(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("tool.Info: %w", err)
+		}
+		if toolAllowed(info.Name, allow) {
+			selected = append(selected, t)
+		}
+	}
+	return selected, nil
+}
+
+// toolAllowed 工具名是否匹配 allow 中的任一项；非法 glob 已在配置校验时排除，这里按不匹配处理
+func toolAllowed(name string, allow []string) bool {
+	for _, pattern := range allow {
+		if ok, err := path.Match(pattern, name); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
+
+// toolsResponse /tools 的响应
+type toolsResponse struct {
+	// Available 已连接的 MCP Server 提供的全部工具
+	Available []toolEntry `json:"available"`
+	// Profiles 各 profile 实际绑定的工具
+	Profiles []profileTools `json:"profiles"`
+}
+
+type profileTools struct {
+	Name      string      `json:"name"`
+	Endpoints []string    `json:"endpoints"`
+	Allow     []string    `json:"allow"`
+	Tools     []toolEntry `json:"tools"`
+}
+
+type toolEntry struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// toolsHandler /tools：列出全部可用工具以及每个接口实际绑定的工具
+func toolsHandler(available *toolCatalog, profiles []*agentProfile) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		resp := toolsResponse{Available: available.entries(), Profiles: make([]profileTools, 0, len(profiles))}
+		for _, p := range profiles {
+			allow := p.Allow
+			if allow == nil {
+				allow = []string{}
+			}
+			resp.Profiles = append(resp.Profiles, profileTools{
+				Name:      p.Name,
+				Endpoints: p.Endpoints,
+				Allow:     allow,
+				Tools:     p.Tools.entries(),
+			})
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_ = json.NewEncoder(w).Encode(resp)
+	}
+}
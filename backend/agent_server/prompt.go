@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aistudiolabx/eino-demo/backend/config"
+	"github.com/cloudwego/eino/components/prompt"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+)
+
+var (
+	errPromptNotFound    = errors.New("prompt template not found")
+	errInvalidPromptName = errors.New("invalid prompt name")
+)
+
+// promptNamePattern 模板名只允许简单字符，避免请求中的名字逃出模板目录
+var promptNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// promptFormats 模板文件扩展名与模板格式的对应关系，按顺序查找
+var promptFormats = []struct {
+	ext    string
+	format schema.FormatType
+}{
+	{".txt", schema.FString},
+	{".jinja", schema.Jinja2},
+}
+
+// 模板可用的变量：
+//
+//	date        当前日期，如 2025-01-02
+//	time        当前时间，如 15:04
+//	weekday     星期，如 Monday
+//	locale      用户语言，如 zh-CN
+//	tools       可用工具列表，每行 "- 名称: 描述"
+//	tool_names  可用工具名，逗号分隔
+type promptVars map[string]any
+
+// cachedPrompt 已解析的模板及其文件修改时间
+type cachedPrompt struct {
+	path     string
+	modTime  time.Time
+	template prompt.ChatTemplate
+}
+
+// promptStore 从目录加载系统提示模板；每次渲染前检查文件修改时间，模板改动无需重启即可生效
+type promptStore struct {
+	dir    string
+	locale string
+
+	mu    sync.Mutex
+	cache map[string]*cachedPrompt
+}
+
+func newPromptStore(cfg config.PromptConfig) *promptStore {
+	return &promptStore{
+		dir:    cfg.Dir,
+		locale: cfg.Locale,
+		cache:  make(map[string]*cachedPrompt),
+	}
+}
+
+// load 返回名为 name 的模板，文件有变化时重新解析
+func (s *promptStore) load(name string) (prompt.ChatTemplate, error) {
+	if !promptNamePattern.MatchString(name) {
+		return nil, fmt.Errorf("%w: %q", errInvalidPromptName, name)
+	}
+
+	for _, f := range promptFormats {
+		path := filepath.Join(s.dir, name+f.ext)
+		st, err := os.Stat(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if c, ok := s.cache[name]; ok && c.path == path && c.modTime.Equal(st.ModTime()) {
+			return c.template, nil
+		}
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		tpl := prompt.FromMessages(f.format, schema.SystemMessage(strings.TrimSpace(string(raw))))
+		s.cache[name] = &cachedPrompt{path: path, modTime: st.ModTime(), template: tpl}
+		return tpl, nil
+	}
+	return nil, fmt.Errorf("%w: %q（在 %s 中查找 %s.txt / %s.jinja）", errPromptNotFound, name, s.dir, name, name)
+}
+
+// render 渲染模板为系统提示文本，tools 为调用方 agent 实际绑定的工具
+func (s *promptStore) render(ctx context.Context, name, locale string, tools *toolCatalog) (string, error) {
+	tpl, err := s.load(name)
+	if err != nil {
+		return "", err
+	}
+	if locale == "" {
+		locale = s.locale
+	}
+	now := time.Now()
+	msgs, err := tpl.Format(ctx, promptVars{
+		"date":       now.Format("2006-01-02"),
+		"time":       now.Format("15:04"),
+		"weekday":    now.Weekday().String(),
+		"locale":     locale,
+		"tools":      tools.describe(),
+		"tool_names": strings.Join(tools.names(), ", "),
+	})
+	if err != nil {
+		return "", fmt.Errorf("render prompt %s: %w", name, err)
+	}
+	parts := make([]string, 0, len(msgs))
+	for _, m := range msgs {
+		parts = append(parts, m.Content)
+	}
+	return strings.Join(parts, "\n"), nil
+}
+
+// systemPrompt 按请求选择模板并渲染：请求体的 prompt 优先于 profile 的默认模板，
+// locale 依次取请求体、Accept-Language 首选语言、配置默认值
+func (s *promptStore) systemPrompt(r *http.Request, req agentRequest, profile *agentProfile) (string, error) {
+	name := req.Prompt
+	if name == "" {
+		name = profile.Prompt
+	}
+	locale := req.Locale
+	if locale == "" {
+		locale = acceptLanguage(r)
+	}
+	return s.render(r.Context(), name, locale, profile.Tools)
+}
+
+// acceptLanguage 取 Accept-Language 中的第一个语言标签
+func acceptLanguage(r *http.Request) string {
+	v := r.Header.Get("Accept-Language")
+	if v == "" {
+		return ""
+	}
+	tag, _, _ := strings.Cut(v, ",")
+	tag, _, _ = strings.Cut(tag, ";")
+	return strings.TrimSpace(tag)
+}
+
+// promptErrorStatus 模板错误对应的 HTTP 状态码：请求指定了不存在或非法的模板名为 400，其余为 500
+func promptErrorStatus(err error) int {
+	if errors.Is(err, errPromptNotFound) || errors.Is(err, errInvalidPromptName) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// toolCatalog 一组工具的信息快照（已连接的全部工具，或某个 profile 实际绑定的工具），供提示模板与 /tools 列出
+type toolCatalog struct {
+	mu    sync.RWMutex
+	infos []*schema.ToolInfo
+}
+
+// set 在工具变化时更新目录
+func (c *toolCatalog) set(ctx context.Context, tools []tool.BaseTool) error {
+	infos := make([]*schema.ToolInfo, 0, len(tools))
+	for _, t := range tools {
+		info, err := t.Info(ctx)
+		if err != nil {
+			return fmt.Errorf("tool.Info: %w", err)
+		}
+		infos = append(infos, info)
+	}
+	c.mu.Lock()
+	c.infos = infos
+	c.mu.Unlock()
+	return nil
+}
+
+func (c *toolCatalog) names() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	names := make([]string, 0, len(c.infos))
+	for _, info := range c.infos {
+		names = append(names, info.Name)
+	}
+	return names
+}
+
+// has 目录中是否有名为 name 的工具
+func (c *toolCatalog) has(name string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for _, info := range c.infos {
+		if info.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *toolCatalog) entries() []toolEntry {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	out := make([]toolEntry, 0, len(c.infos))
+	for _, info := range c.infos {
+		out = append(out, toolEntry{Name: info.Name, Description: info.Desc})
+	}
+	return out
+}
+
+// describe 每个工具一行 "- 名称: 描述"；没有可用工具时给出说明，方便模板直接引用
+func (c *toolCatalog) describe() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if len(c.infos) == 0 {
+		return "（当前没有可用工具）"
+	}
+	var b strings.Builder
+	for i, info := range c.infos {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		fmt.Fprintf(&b, "- %s: %s", info.Name, info.Desc)
+	}
+	return b.String()
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/aistudiolabx/eino-demo/backend/config"
+	"github.com/aistudiolabx/eino-demo/backend/metrics"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+// /chat 统一入口：agentRouter 先判断用户意图，再把请求交给对应子 agent 的 profile，
+// 提示模板、工具与输出处理（profile.Output）都沿用该 profile 的配置。
+// 新的子 agent 只需创建 profile 并 register，无需修改接口代码。
+
+// 路由方式，出现在响应的 route.by 与指标中
+const (
+	routeByRequest = "request" // 请求体指定了 agent
+	routeByRules   = "rules"   // 关键词命中
+	routeByLLM     = "llm"     // 由模型选择
+	routeByDefault = "default" // 无法判断，使用默认子 agent
+)
+
+// routeDecisions 按子 agent 与路由方式统计 /chat 的路由结果
+var routeDecisions = metrics.NewCounter("agent_route_decisions_total",
+	"/chat 路由到各子 agent 的次数", "agent", "by")
+
+// routerPrompt 模型判断意图时的系统提示，%s 为子 agent 列表
+const routerPrompt = `你是一个意图分类器，负责把用户的请求分派给最合适的子 agent。可选的子 agent：
+%s
+只回复一个子 agent 的名字，不要解释，也不要回答用户的问题。`
+
+// subAgent 可被路由到的子 agent
+type subAgent struct {
+	// Profile 处理请求的 profile
+	Profile *agentProfile
+	// Description 能处理什么请求，提供给模型判断意图
+	Description string
+	// Keywords 关键词，不区分大小写
+	Keywords []string
+}
+
+// routeDecision 一次路由的结果，随响应返回
+type routeDecision struct {
+	// Agent 处理请求的子 agent
+	Agent string `json:"agent"`
+	// By 路由方式：request、rules、llm 或 default
+	By string `json:"by"`
+}
+
+// agentRouter 子 agent 注册表与意图分类；子 agent 在 newAgentRouter 中注册，之后只读
+type agentRouter struct {
+	mode      string
+	fallback  string
+	chatModel model.BaseChatModel
+	agents    []*subAgent
+}
+
+// newAgentRouter 创建路由并注册 agents；cfg.Default 必须是其中之一，否则无法判断意图时没有子 agent 可用
+func newAgentRouter(cfg config.RouterConfig, chatModel model.BaseChatModel, agents ...*subAgent) (*agentRouter, error) {
+	r := &agentRouter{mode: cfg.Mode, fallback: cfg.Default, chatModel: chatModel}
+	for _, a := range agents {
+		r.register(a)
+	}
+	if r.find(r.fallback) == nil {
+		return nil, fmt.Errorf("default agent %q is not registered", r.fallback)
+	}
+	return r, nil
+}
+
+// register 注册子 agent，同名时替换原有的注册（保持原来的顺序）
+func (r *agentRouter) register(a *subAgent) {
+	for i, cur := range r.agents {
+		if cur.Profile.Name == a.Profile.Name {
+			r.agents[i] = a
+			return
+		}
+	}
+	r.agents = append(r.agents, a)
+}
+
+// find 按名字查找子 agent
+func (r *agentRouter) find(name string) *subAgent {
+	for _, a := range r.agents {
+		if a.Profile.Name == name {
+			return a
+		}
+	}
+	return nil
+}
+
+// route 为输入选择子 agent；模型判断失败时记录日志并使用默认子 agent，不影响请求本身
+func (r *agentRouter) route(ctx context.Context, input string) (*subAgent, routeDecision) {
+	decide := func(a *subAgent, by string) (*subAgent, routeDecision) {
+		routeDecisions.Inc(a.Profile.Name, by)
+		return a, routeDecision{Agent: a.Profile.Name, By: by}
+	}
+	if r.mode != routeByLLM {
+		if a := r.matchKeywords(input); a != nil {
+			return decide(a, routeByRules)
+		}
+	}
+	if r.mode != routeByRules {
+		a, err := r.classify(ctx, input)
+		if err != nil {
+			log.Printf("route by llm error, use %s: %v", r.fallback, err)
+		}
+		if a != nil {
+			return decide(a, routeByLLM)
+		}
+	}
+	return decide(r.find(r.fallback), routeByDefault)
+}
+
+// matchKeywords 取关键词命中数最多的子 agent，命中数相同时靠前的优先；都未命中时返回 nil
+func (r *agentRouter) matchKeywords(input string) *subAgent {
+	input = strings.ToLower(input)
+	var best *subAgent
+	bestHits := 0
+	for _, a := range r.agents {
+		hits := 0
+		for _, kw := range a.Keywords {
+			if kw != "" && strings.Contains(input, strings.ToLower(kw)) {
+				hits++
+			}
+		}
+		if hits > bestHits {
+			best, bestHits = a, hits
+		}
+	}
+	return best
+}
+
+// classify 让模型从已注册的子 agent 中选择一个；回复中找不到子 agent 名字时返回 nil
+func (r *agentRouter) classify(ctx context.Context, input string) (*subAgent, error) {
+	var sb strings.Builder
+	for _, a := range r.agents {
+		fmt.Fprintf(&sb, "- %s: %s\n", a.Profile.Name, a.Description)
+	}
+	msg, err := r.chatModel.Generate(ctx, []*schema.Message{
+		schema.SystemMessage(fmt.Sprintf(routerPrompt, strings.TrimSuffix(sb.String(), "\n"))),
+		schema.UserMessage(input),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r.parseChoice(msg.Content), nil
+}
+
+// parseChoice 从模型回复中找出子 agent 名字：先按整段回复精确匹配，
+// 再按名字从长到短查找子串，避免 tool_agent 被误认为 agent
+func (r *agentRouter) parseChoice(reply string) *subAgent {
+	reply = strings.ToLower(strings.Trim(strings.TrimSpace(reply), "`\"'。."))
+	if a := r.find(reply); a != nil {
+		return a
+	}
+	agents := append([]*subAgent(nil), r.agents...)
+	sort.SliceStable(agents, func(i, j int) bool {
+		return len(agents[i].Profile.Name) > len(agents[j].Profile.Name)
+	})
+	for _, a := range agents {
+		if strings.Contains(reply, strings.ToLower(a.Profile.Name)) {
+			return a
+		}
+	}
+	return nil
+}
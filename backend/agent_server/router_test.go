@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aistudiolabx/eino-demo/backend/agenttest"
+	"github.com/aistudiolabx/eino-demo/backend/config"
+)
+
+// testRoutes 路由测试用的三个子 agent，只有名字与关键词
+func testRoutes() []*subAgent {
+	return []*subAgent{
+		{Profile: &agentProfile{Name: "agent"}, Description: "天气", Keywords: []string{"天气", "Weather"}},
+		{Profile: &agentProfile{Name: "tool_agent"}, Description: "改写", Keywords: []string{"小说", "剧本"}},
+		{Profile: &agentProfile{Name: "plan_agent"}, Description: "多步任务", Keywords: []string{"天气", "对比"}},
+	}
+}
+
+func TestNewAgentRouterRequiresFallback(t *testing.T) {
+	_, err := newAgentRouter(config.RouterConfig{Mode: routeByRules, Default: "nope"}, agenttest.NewFakeChatModel(), testRoutes()...)
+	if err == nil {
+		t.Fatal("newAgentRouter accepted a default agent that is not registered")
+	}
+}
+
+// 默认子 agent 为 tool_agent
+func TestAgentRouterRoute(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		mode  string
+		input string
+		turns []agenttest.Turn
+		agent string
+		by    string
+	}{
+		{name: "keyword", mode: "auto", input: "把这段小说改成剧本", agent: "tool_agent", by: routeByRules},
+		{name: "keyword case insensitive", mode: "rules", input: "WEATHER in Paris", agent: "agent", by: routeByRules},
+		{name: "most hits wins", mode: "rules", input: "对比北京和上海的天气", agent: "plan_agent", by: routeByRules},
+		{name: "tie prefers earlier", mode: "rules", input: "明天天气", agent: "agent", by: routeByRules},
+		{name: "rules miss", mode: "rules", input: "你好", agent: "tool_agent", by: routeByDefault},
+		{name: "llm exact", mode: "auto", input: "你好", turns: []agenttest.Turn{agenttest.Reply("`plan_agent`")}, agent: "plan_agent", by: routeByLLM},
+		// tool_agent 包含 agent，按名字从长到短匹配
+		{name: "llm substring", mode: "llm", input: "天气", turns: []agenttest.Turn{agenttest.Reply("应该交给 tool_agent 处理")}, agent: "tool_agent", by: routeByLLM},
+		{name: "llm unknown agent", mode: "llm", input: "你好", turns: []agenttest.Turn{agenttest.Reply("闲聊助手")}, agent: "tool_agent", by: routeByDefault},
+		{name: "llm error", mode: "auto", input: "你好", turns: []agenttest.Turn{{Err: errors.New("model down")}}, agent: "tool_agent", by: routeByDefault},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cm := agenttest.NewFakeChatModel(tc.turns...)
+			r, err := newAgentRouter(config.RouterConfig{Mode: tc.mode, Default: "tool_agent"}, cm, testRoutes()...)
+			if err != nil {
+				t.Fatalf("newAgentRouter: %v", err)
+			}
+			a, d := r.route(context.Background(), tc.input)
+			if a.Profile.Name != tc.agent || d != (routeDecision{Agent: tc.agent, By: tc.by}) {
+				t.Errorf("route = %s %+v, want %s by %s", a.Profile.Name, d, tc.agent, tc.by)
+			}
+			if cm.Remaining() != 0 {
+				t.Errorf("%d scripted turns left", cm.Remaining())
+			}
+		})
+	}
+}
+
+// 同名注册替换原有的子 agent，保持原来的顺序
+func TestAgentRouterRegisterReplaces(t *testing.T) {
+	r, err := newAgentRouter(config.RouterConfig{Mode: routeByRules, Default: "agent"}, agenttest.NewFakeChatModel(), testRoutes()...)
+	if err != nil {
+		t.Fatalf("newAgentRouter: %v", err)
+	}
+	r.register(&subAgent{Profile: &agentProfile{Name: "agent"}, Keywords: []string{"温度"}})
+	if len(r.agents) != 3 || r.agents[0].Keywords[0] != "温度" {
+		t.Fatalf("agents after re-register = %d, first keywords %v", len(r.agents), r.agents[0].Keywords)
+	}
+	if a, _ := r.route(context.Background(), "明天天气"); a.Profile.Name != "plan_agent" {
+		t.Errorf("route = %s, want plan_agent after agent's keywords were replaced", a.Profile.Name)
+	}
+}
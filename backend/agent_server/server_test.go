@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aistudiolabx/eino-demo/backend/config"
+	"github.com/aistudiolabx/eino-demo/backend/mcp_server/mcptest"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/components/tool"
+)
+
+// testServer 测试用的 agent server：模型由调用方给出（通常是 agenttest.FakeChatModel），
+// 工具来自 mcptest 的进程内 MCP Server，profile、路由与各接口的组装方式与 main 相同
+type testServer struct {
+	*httptest.Server
+	MCP      *mcptest.Server
+	Sessions *sessionManager
+	Jobs     *jobManager
+}
+
+// mcpTools 启动 mcptest 的 MCP Server 并经 trackedMCPClient 取回全部工具，测试结束时关闭
+func mcpTools(t *testing.T, up *mcptest.Upstream) (*mcptest.Server, []tool.BaseTool) {
+	t.Helper()
+	ctx := context.Background()
+	srv := mcptest.NewServer(up)
+	t.Cleanup(srv.Close)
+	cli, err := srv.Client(ctx)
+	if err != nil {
+		t.Fatalf("mcptest client: %v", err)
+	}
+	t.Cleanup(func() { _ = cli.Close() })
+	tools, err := fetchServerTools(ctx, &trackedMCPClient{Client: cli})
+	if err != nil {
+		t.Fatalf("fetchServerTools: %v", err)
+	}
+	return srv, tools
+}
+
+// newTestServer 以默认配置启动测试 server，configure 可在组装前修改配置
+func newTestServer(t *testing.T, chatModel model.ToolCallingChatModel, up *mcptest.Upstream, configure ...func(cfg *config.AgentConfig)) *testServer {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	cfg := config.Default().Agent
+	cfg.Prompts.Dir = filepath.Join("..", "..", "prompts")
+	for _, fn := range configure {
+		fn(&cfg)
+	}
+	mcpSrv, tools := mcpTools(t, up)
+
+	approvalStore := newMemoryApprovalStore()
+	agent, toolAgent, planAgent := newProfiles(cfg, approvalStore)
+	profiles := []*agentProfile{agent, toolAgent, planAgent}
+	if err := rebuildProfiles(ctx, chatModel, profiles, tools); err != nil {
+		t.Fatalf("rebuildProfiles: %v", err)
+	}
+
+	sessions := &sessionManager{
+		store:  newMemorySessionStore(),
+		budget: historyBudget{MaxMessages: cfg.Session.MaxMessages, MaxTokens: cfg.Session.MaxTokens},
+	}
+	prompts := newPromptStore(cfg.Prompts)
+	approvals := newApprovalManager(approvalStore, sessions, profiles)
+	var routes []*subAgent
+	for _, rc := range cfg.Router.Routes {
+		routes = append(routes, &subAgent{Profile: findProfile(profiles, rc.Agent), Description: rc.Description, Keywords: rc.Keywords})
+	}
+	router, err := newAgentRouter(cfg.Router, chatModel, routes...)
+	if err != nil {
+		t.Fatalf("newAgentRouter: %v", err)
+	}
+	jobs := newJobManager(cfg.Jobs, sessions, approvals)
+	jobs.start(ctx)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/agent", agentHandler(agent, sessions, prompts, approvals))
+	mux.HandleFunc("/agent/stream", agentStreamHandler(agent, sessions, prompts, approvals))
+	mux.HandleFunc("/tool_agent", agentHandler(toolAgent, sessions, prompts, approvals))
+	mux.HandleFunc("/plan_agent", agentHandler(planAgent, sessions, prompts, approvals))
+	mux.HandleFunc("/chat", chatHandler(router, sessions, prompts, approvals))
+	mux.HandleFunc("/v1/chat/completions", chatCompletionsHandler([]openAIModel{
+		{ID: "eino-agent", Profile: agent},
+		{ID: "eino-tool-agent", Profile: toolAgent},
+		{ID: "eino-plan-agent", Profile: planAgent},
+	}, prompts, approvals))
+	mux.HandleFunc("/jobs", jobsHandler(jobs, profiles, prompts))
+	mux.HandleFunc("/jobs/{id}", jobHandler(jobs))
+	mux.HandleFunc("/approvals/{id}", approvalHandler(approvals, jobs))
+	mux.HandleFunc("/sessions/{id}", sessionHandler(sessions.store))
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return &testServer{Server: srv, MCP: mcpSrv, Sessions: sessions, Jobs: jobs}
+}
+
+// do 发送请求（body 非 nil 时编码为 JSON），out 非 nil 时把响应体解码到 out，返回状态码
+func (s *testServer) do(t *testing.T, method, path string, body, out any) int {
+	t.Helper()
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			t.Fatalf("encode request: %v", err)
+		}
+	}
+	req, err := http.NewRequest(method, s.URL+path, &buf)
+	if err != nil {
+		t.Fatalf("new request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.Client().Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("decode %s %s response (status %d): %v", method, path, resp.StatusCode, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+// waitJob 轮询任务直到状态满足 done，超时则测试失败
+func (s *testServer) waitJob(t *testing.T, id string, done func(jobView) bool) jobView {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var v jobView
+		s.do(t, http.MethodGet, "/jobs/"+id, nil, &v)
+		if done(v) {
+			return v
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job %s still %s", id, v.Status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,434 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// errSessionNotFound 会话不存在
+var errSessionNotFound = errors.New("session not found")
+
+// errInvalidSessionID 会话 ID 格式不合法
+var errInvalidSessionID = errors.New("invalid session_id")
+
+// errSessionBusy 会话上已有一轮对话在进行（含排队与运行中的任务）
+var errSessionBusy = errors.New("session is busy")
+
+// sessionIDPattern 会话 ID 只允许字母、数字、下划线与连字符（文件存储以其作为文件名）
+var sessionIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// Session 一个多轮对话会话，Messages 为完整历史（含 tool call 与 tool 消息，不含系统提示）
+type Session struct {
+	ID        string            `json:"id"`
+	Messages  []*schema.Message `json:"messages"`
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
+
+// sessionSummary 会话列表项
+type sessionSummary struct {
+	ID           string    `json:"id"`
+	MessageCount int       `json:"message_count"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// SessionStore 会话历史存储
+type SessionStore interface {
+	// Get 读取会话，不存在时返回 errSessionNotFound
+	Get(ctx context.Context, id string) (*Session, error)
+	// Save 创建或覆盖会话
+	Save(ctx context.Context, s *Session) error
+	// Delete 删除会话，不存在时返回 errSessionNotFound
+	Delete(ctx context.Context, id string) error
+	// List 按更新时间倒序列出所有会话
+	List(ctx context.Context) ([]sessionSummary, error)
+}
+
+// memorySessionStore 进程内会话存储，重启后丢失
+type memorySessionStore struct {
+	mu       sync.RWMutex
+	sessions map[string]*Session
+}
+
+func newMemorySessionStore() *memorySessionStore {
+	return &memorySessionStore{sessions: make(map[string]*Session)}
+}
+
+func (s *memorySessionStore) Get(_ context.Context, id string) (*Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	sess, ok := s.sessions[id]
+	if !ok {
+		return nil, errSessionNotFound
+	}
+	cp := *sess
+	cp.Messages = append([]*schema.Message(nil), sess.Messages...)
+	return &cp, nil
+}
+
+func (s *memorySessionStore) Save(_ context.Context, sess *Session) error {
+	cp := *sess
+	cp.Messages = append([]*schema.Message(nil), sess.Messages...)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[sess.ID] = &cp
+	return nil
+}
+
+func (s *memorySessionStore) Delete(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.sessions[id]; !ok {
+		return errSessionNotFound
+	}
+	delete(s.sessions, id)
+	return nil
+}
+
+func (s *memorySessionStore) List(_ context.Context) ([]sessionSummary, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]sessionSummary, 0, len(s.sessions))
+	for _, sess := range s.sessions {
+		out = append(out, summarizeSession(sess))
+	}
+	sortSummaries(out)
+	return out, nil
+}
+
+// fileSessionStore 以目录下每个会话一个 JSON 文件（<id>.json）持久化
+type fileSessionStore struct {
+	mu  sync.Mutex
+	dir string
+}
+
+func newFileSessionStore(dir string) (*fileSessionStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("创建会话目录失败: %w", err)
+	}
+	return &fileSessionStore{dir: dir}, nil
+}
+
+func (s *fileSessionStore) path(id string) string {
+	return filepath.Join(s.dir, id+".json")
+}
+
+func (s *fileSessionStore) Get(_ context.Context, id string) (*Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.read(s.path(id))
+}
+
+func (s *fileSessionStore) read(path string) (*Session, error) {
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, errSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var sess Session
+	if err := json.Unmarshal(raw, &sess); err != nil {
+		return nil, fmt.Errorf("解析会话文件 %s 失败: %w", path, err)
+	}
+	return &sess, nil
+}
+
+// Save 先写临时文件再重命名，避免进程中断留下半截文件
+func (s *fileSessionStore) Save(_ context.Context, sess *Session) error {
+	raw, err := json.Marshal(sess)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tmp := s.path(sess.ID) + ".tmp"
+	if err := os.WriteFile(tmp, raw, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path(sess.ID))
+}
+
+func (s *fileSessionStore) Delete(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := os.Remove(s.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return errSessionNotFound
+	}
+	return err
+}
+
+func (s *fileSessionStore) List(_ context.Context) ([]sessionSummary, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]sessionSummary, 0, len(entries))
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		sess, err := s.read(filepath.Join(s.dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, summarizeSession(sess))
+	}
+	sortSummaries(out)
+	return out, nil
+}
+
+func summarizeSession(s *Session) sessionSummary {
+	return sessionSummary{
+		ID:           s.ID,
+		MessageCount: len(s.Messages),
+		CreatedAt:    s.CreatedAt,
+		UpdatedAt:    s.UpdatedAt,
+	}
+}
+
+func sortSummaries(out []sessionSummary) {
+	sort.Slice(out, func(i, j int) bool { return out[i].UpdatedAt.After(out[j].UpdatedAt) })
+}
+
+// historyBudget 发送给模型的历史长度上限，字段 <=0 表示不限制
+type historyBudget struct {
+	MaxMessages int
+	MaxTokens   int
+}
+
+// trimHistory 从最新的消息往前保留，直到超出消息数或 token 预算；
+// 截断点会后移到最近的一条 user 消息，避免留下缺少 tool call 的 tool 消息
+func trimHistory(msgs []*schema.Message, budget historyBudget) []*schema.Message {
+	start := 0
+	tokens := 0
+	for i := len(msgs) - 1; i >= 0; i-- {
+		tokens += estimateTokens(msgs[i])
+		count := len(msgs) - i
+		if (budget.MaxMessages > 0 && count > budget.MaxMessages) ||
+			(budget.MaxTokens > 0 && tokens > budget.MaxTokens) {
+			start = i + 1
+			break
+		}
+	}
+	for start < len(msgs) && msgs[start].Role != schema.User {
+		start++
+	}
+	return msgs[start:]
+}
+
+// estimateTokens 粗略估算消息的 token 数：ASCII 约 4 字节一个 token，其余字符（如中文）约一字一个 token
+func estimateTokens(m *schema.Message) int {
+	text := m.Content
+	for _, tc := range m.ToolCalls {
+		text += tc.Function.Name + tc.Function.Arguments
+	}
+	ascii, other := 0, 0
+	for _, r := range text {
+		if r < utf8.RuneSelf {
+			ascii++
+		} else {
+			other++
+		}
+	}
+	return ascii/4 + other + 4
+}
+
+// sessionManager 负责在一次 agent 调用前后读取、截断与保存会话历史。
+// 同一会话同时只允许一轮对话（load → 运行 → saveTurn），避免并发的两轮各自基于旧历史写回而丢失其中一轮；
+// 暂停等待审批的一轮在审批继续或放弃前同样占用会话
+type sessionManager struct {
+	store  SessionStore
+	budget historyBudget
+
+	mu sync.Mutex
+	// busy 会话 ID 上的占用数：进行中的运行与待审批记录各占一次，为 0 时删除
+	busy map[string]int
+}
+
+// load 读取会话并把会话标记为进行中，调用方在本轮结束（保存、失败或暂停等待审批）后调用 release；
+// sessionID 为空表示无状态调用，返回 nil；会话不存在时新建；已有一轮对话在进行或等待审批时返回 errSessionBusy
+func (m *sessionManager) load(ctx context.Context, sessionID string) (*Session, error) {
+	if sessionID == "" {
+		return nil, nil
+	}
+	if !sessionIDPattern.MatchString(sessionID) {
+		return nil, fmt.Errorf("%w: %q", errInvalidSessionID, sessionID)
+	}
+	if err := m.acquire(sessionID); err != nil {
+		return nil, err
+	}
+	sess, err := m.get(ctx, sessionID)
+	if err != nil {
+		m.release(sessionID)
+		return nil, err
+	}
+	return sess, nil
+}
+
+// reload 读取已被占用的会话的最新历史，不改变占用状态；用于审批后继续运行，
+// 此时会话由待审批记录占用（见 hold）
+func (m *sessionManager) reload(ctx context.Context, sessionID string) (*Session, error) {
+	if sessionID == "" {
+		return nil, nil
+	}
+	return m.get(ctx, sessionID)
+}
+
+func (m *sessionManager) get(ctx context.Context, sessionID string) (*Session, error) {
+	sess, err := m.store.Get(ctx, sessionID)
+	if errors.Is(err, errSessionNotFound) {
+		now := time.Now()
+		return &Session{ID: sessionID, CreatedAt: now, UpdatedAt: now}, nil
+	}
+	return sess, err
+}
+
+func (m *sessionManager) acquire(sessionID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.busy[sessionID] > 0 {
+		return fmt.Errorf("%w: %q", errSessionBusy, sessionID)
+	}
+	m.hold(sessionID)
+	return nil
+}
+
+// hold 在会话上追加一次占用，调用方须持有 m.mu
+func (m *sessionManager) hold(sessionID string) {
+	if m.busy == nil {
+		m.busy = make(map[string]int)
+	}
+	m.busy[sessionID]++
+}
+
+// keep 暂停等待审批时由待审批记录占用会话，审批继续的运行结束或记录被放弃时 release；
+// sessionID 为空时不做任何事
+func (m *sessionManager) keep(sessionID string) {
+	if sessionID == "" {
+		return
+	}
+	m.mu.Lock()
+	m.hold(sessionID)
+	m.mu.Unlock()
+}
+
+// release 释放会话上的一次占用；sessionID 为空时不做任何事
+func (m *sessionManager) release(sessionID string) {
+	if sessionID == "" {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.busy[sessionID]--; m.busy[sessionID] <= 0 {
+		delete(m.busy, sessionID)
+	}
+}
+
+// sessionErrorStatus load 错误对应的 HTTP 状态码
+func sessionErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, errInvalidSessionID):
+		return http.StatusBadRequest
+	case errors.Is(err, errSessionBusy):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+// buildMessages 组装发送给 agent 的消息：系统提示（可为空）+ 截断后的会话历史 + 本轮用户输入
+func (m *sessionManager) buildMessages(systemPrompt string, sess *Session, input string) []*schema.Message {
+	var msgs []*schema.Message
+	if systemPrompt != "" {
+		msgs = append(msgs, schema.SystemMessage(systemPrompt))
+	}
+	if sess != nil {
+		msgs = append(msgs, trimHistory(sess.Messages, m.budget)...)
+	}
+	return append(msgs, schema.UserMessage(input))
+}
+
+// saveTurn 把本轮用户输入与 agent 产生的消息追加到会话并保存；sess 为 nil 时不做任何事
+func (m *sessionManager) saveTurn(ctx context.Context, sess *Session, input string, respMsgs []*schema.Message) error {
+	if sess == nil {
+		return nil
+	}
+	sess.Messages = append(sess.Messages, schema.UserMessage(input))
+	sess.Messages = append(sess.Messages, respMsgs...)
+	sess.UpdatedAt = time.Now()
+	return m.store.Save(ctx, sess)
+}
+
+// sessionsHandler GET /sessions 列出会话
+func sessionsHandler(store SessionStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		list, err := store.List(r.Context())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_ = json.NewEncoder(w).Encode(map[string]any{"sessions": list})
+	}
+}
+
+// sessionHandler GET /sessions/{id} 获取会话历史，DELETE /sessions/{id} 删除会话
+func sessionHandler(store SessionStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		if !sessionIDPattern.MatchString(id) {
+			http.Error(w, "invalid session id", http.StatusBadRequest)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			sess, err := store.Get(r.Context(), id)
+			if errors.Is(err, errSessionNotFound) {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			_ = json.NewEncoder(w).Encode(sess)
+		case http.MethodDelete:
+			err := store.Delete(r.Context(), id)
+			if errors.Is(err, errSessionNotFound) {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
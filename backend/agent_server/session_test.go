@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+func TestSessionLoadRejectsConcurrentTurn(t *testing.T) {
+	ctx := context.Background()
+	m := &sessionManager{store: newMemorySessionStore()}
+
+	sess, err := m.load(ctx, "s1")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if _, err := m.load(ctx, "s1"); !errors.Is(err, errSessionBusy) {
+		t.Fatalf("second load error = %v, want errSessionBusy", err)
+	}
+	// 其他会话与无状态调用不受影响
+	if _, err := m.load(ctx, "s2"); err != nil {
+		t.Fatalf("load other session: %v", err)
+	}
+	if s, err := m.load(ctx, ""); s != nil || err != nil {
+		t.Fatalf("stateless load = %v, %v", s, err)
+	}
+
+	if err := m.saveTurn(ctx, sess, "hi", []*schema.Message{schema.AssistantMessage("hello", nil)}); err != nil {
+		t.Fatalf("saveTurn: %v", err)
+	}
+	m.release("s1")
+
+	sess, err = m.load(ctx, "s1")
+	if err != nil {
+		t.Fatalf("load after release: %v", err)
+	}
+	defer m.release("s1")
+	if len(sess.Messages) != 2 {
+		t.Fatalf("history has %d messages, want 2", len(sess.Messages))
+	}
+}
+
+func TestSessionErrorStatus(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		want int
+	}{
+		{errInvalidSessionID, 400},
+		{errSessionBusy, 409},
+		{errors.New("disk full"), 500},
+	} {
+		if got := sessionErrorStatus(tc.err); got != tc.want {
+			t.Errorf("sessionErrorStatus(%v) = %d, want %d", tc.err, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+
+	"github.com/cloudwego/eino/callbacks"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/compose"
+	"github.com/cloudwego/eino/schema"
+	callbackutils "github.com/cloudwego/eino/utils/callbacks"
+)
+
+// /agent/stream 以 Server-Sent Events 推送 agent 的运行过程，请求体与 /agent 相同。
+// 每个事件形如 "event: <类型>\ndata: <JSON>\n\n"，事件类型与 data 结构如下：
+//
+//	delta       {"content": "..."}                                  模型输出的增量文本
+//	tool_start  {"id": "...", "name": "weather", "arguments": "{...}"}  开始调用工具，arguments 为 JSON 字符串
+//	tool_end    {"id": "...", "name": "weather", "result": "..."}      工具调用完成
+//	tool_error  {"id": "...", "name": "weather", "error": "..."}       工具调用失败
+//	done        {"output": "...", "session_id": "...", "trace": {...}}  最终回答（trace 仅在请求 verbose 时返回），之后连接关闭
+//	error       {"error": "..."}                                    运行失败，之后连接关闭
+//	approval_required  {"id": "apr-...", "tool_calls": [...], ...}     调用需要审批的工具前暂停（见 approval.go），之后连接关闭；
+//	                                                                    审批结果通过 POST /approvals/{id} 提交，最终回答在该请求的响应中返回
+//
+// 同一次工具调用的 tool_start 与 tool_end/tool_error 通过 id（即模型给出的 tool call ID）关联。
+const (
+	sseEventDelta     = "delta"
+	sseEventToolStart = "tool_start"
+	sseEventToolEnd   = "tool_end"
+	sseEventToolError = "tool_error"
+	sseEventDone      = "done"
+	sseEventError     = "error"
+
+	sseEventApprovalRequired = "approval_required"
+)
+
+// streamDelta delta 事件数据
+type streamDelta struct {
+	Content string `json:"content"`
+}
+
+// streamToolEvent tool_start / tool_end / tool_error 事件数据
+type streamToolEvent struct {
+	ID        string `json:"id,omitempty"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments,omitempty"`
+	Result    string `json:"result,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// streamDone done 事件数据
+type streamDone struct {
+	Output    string          `json:"output"`
+	SessionID string          `json:"session_id,omitempty"`
+	Trace     *executionTrace `json:"trace,omitempty"`
+}
+
+// streamError error 事件数据
+type streamError struct {
+	Error string `json:"error"`
+}
+
+// sseWriter 串行写出 SSE 事件；回调可能在多个 goroutine 中并发触发（例如并行执行的工具）
+type sseWriter struct {
+	mu      sync.Mutex
+	w       io.Writer
+	flusher http.Flusher
+}
+
+func newSSEWriter(w http.ResponseWriter) (*sseWriter, bool) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return nil, false
+	}
+	w.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	return &sseWriter{w: w, flusher: flusher}, true
+}
+
+// send 写出一个事件；客户端断开后的写入错误直接忽略，运行会随请求 ctx 取消而结束
+func (s *sseWriter) send(event string, data any) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, _ = fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", event, payload)
+	s.flusher.Flush()
+}
+
+// newStreamCallbacks 构造把模型增量输出与工具调用转为事件（delta、tool_start、tool_end、tool_error）的回调，
+// 事件通过 emit 送出，emit 需可并发调用；返回的 wait 用于在发送 done 之前等待所有异步读取的流结束
+func newStreamCallbacks(emit func(event string, data any)) (handler callbacks.Handler, wait func()) {
+	var wg sync.WaitGroup
+
+	modelHandler := &callbackutils.ModelCallbackHandler{
+		OnEndWithStreamOutput: func(ctx context.Context, _ *callbacks.RunInfo, output *schema.StreamReader[*model.CallbackOutput]) context.Context {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer output.Close()
+				for {
+					chunk, err := output.Recv()
+					if err != nil {
+						return
+					}
+					if chunk.Message != nil && chunk.Message.Content != "" {
+						emit(sseEventDelta, streamDelta{Content: chunk.Message.Content})
+					}
+				}
+			}()
+			return ctx
+		},
+	}
+
+	toolHandler := &callbackutils.ToolCallbackHandler{
+		OnStart: func(ctx context.Context, info *callbacks.RunInfo, input *tool.CallbackInput) context.Context {
+			emit(sseEventToolStart, streamToolEvent{
+				ID:        compose.GetToolCallID(ctx),
+				Name:      info.Name,
+				Arguments: input.ArgumentsInJSON,
+			})
+			return ctx
+		},
+		OnEnd: func(ctx context.Context, info *callbacks.RunInfo, output *tool.CallbackOutput) context.Context {
+			emit(sseEventToolEnd, streamToolEvent{
+				ID:     compose.GetToolCallID(ctx),
+				Name:   info.Name,
+				Result: extractContentFromJSON(output.Response),
+			})
+			return ctx
+		},
+		OnEndWithStreamOutput: func(ctx context.Context, info *callbacks.RunInfo, output *schema.StreamReader[*tool.CallbackOutput]) context.Context {
+			id := compose.GetToolCallID(ctx)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer output.Close()
+				var result string
+				for {
+					chunk, err := output.Recv()
+					if errors.Is(err, io.EOF) {
+						break
+					}
+					if err != nil {
+						emit(sseEventToolError, streamToolEvent{ID: id, Name: info.Name, Error: err.Error()})
+						return
+					}
+					result += chunk.Response
+				}
+				emit(sseEventToolEnd, streamToolEvent{ID: id, Name: info.Name, Result: extractContentFromJSON(result)})
+			}()
+			return ctx
+		},
+		OnError: func(ctx context.Context, info *callbacks.RunInfo, err error) context.Context {
+			emit(sseEventToolError, streamToolEvent{
+				ID:    compose.GetToolCallID(ctx),
+				Name:  info.Name,
+				Error: err.Error(),
+			})
+			return ctx
+		},
+	}
+
+	handler = callbackutils.NewHandlerHelper().ChatModel(modelHandler).Tool(toolHandler).Handler()
+	return handler, wg.Wait
+}
+
+// answerNode 生成最终回复的模型节点
+type answerNode struct {
+	// Name 节点名（compose.WithNodeName），即模型回调中 RunInfo.Name
+	Name string
+	// Tools 节点绑定了工具：一轮输出中含工具调用时这一轮不是回复，要等这一轮结束后才能确定是否转发
+	Tools bool
+}
+
+// newAnswerCallbacks 构造只转发最终回复增量文本的回调：node 之外的模型调用（规划、步骤执行等）被忽略，
+// 绑定了工具的节点按轮缓冲，这一轮没有工具调用时才依次 emit；返回的 wait 与 newStreamCallbacks 相同
+func newAnswerCallbacks(node *answerNode, emit func(content string)) (handler callbacks.Handler, wait func()) {
+	var wg sync.WaitGroup
+
+	modelHandler := &callbackutils.ModelCallbackHandler{
+		OnEndWithStreamOutput: func(ctx context.Context, info *callbacks.RunInfo, output *schema.StreamReader[*model.CallbackOutput]) context.Context {
+			if info.Name != node.Name {
+				output.Close()
+				return ctx
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer output.Close()
+				var pending []string
+				for {
+					chunk, err := output.Recv()
+					if errors.Is(err, io.EOF) {
+						break
+					}
+					if err != nil {
+						return
+					}
+					if chunk.Message == nil {
+						continue
+					}
+					if len(chunk.Message.ToolCalls) > 0 {
+						return
+					}
+					switch content := chunk.Message.Content; {
+					case content == "":
+					case node.Tools:
+						pending = append(pending, content)
+					default:
+						emit(content)
+					}
+				}
+				for _, content := range pending {
+					emit(content)
+				}
+			}()
+			return ctx
+		},
+	}
+
+	handler = callbackutils.NewHandlerHelper().ChatModel(modelHandler).Handler()
+	return handler, wg.Wait
+}
+
+// agentStreamHandler 以 SSE 流式返回 /agent 的运行过程与最终回答
+func agentStreamHandler(profile *agentProfile, sessions *sessionManager, prompts *promptStore, approvals *approvalManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req agentRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+
+		if req.Input == "" {
+			http.Error(w, "input is required", http.StatusBadRequest)
+			return
+		}
+
+		sess, err := sessions.load(ctx, req.SessionID)
+		if err != nil {
+			http.Error(w, err.Error(), sessionErrorStatus(err))
+			return
+		}
+		defer sessions.release(req.SessionID)
+
+		systemPrompt, err := prompts.systemPrompt(r, req, profile)
+		if err != nil {
+			http.Error(w, err.Error(), promptErrorStatus(err))
+			return
+		}
+
+		sse, ok := newSSEWriter(w)
+		if !ok {
+			http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+			return
+		}
+
+		msgs := sessions.buildMessages(systemPrompt, sess, req.Input)
+
+		handler, wait := newStreamCallbacks(sse.send)
+		var rec *traceRecorder
+		if req.Verbose {
+			rec = newTraceRecorder()
+		}
+		approvalID := newApprovalID()
+		// fail 结束事件流：等待审批的中断发送 approval_required，其余错误发送 error
+		fail := func(err error) {
+			wait()
+			a := &pendingApproval{ID: approvalID, SessionID: req.SessionID, Input: req.Input}
+			paused, saveErr := approvals.pause(ctx, err, profile, a)
+			switch {
+			case paused && saveErr == nil:
+				sse.send(sseEventApprovalRequired, a)
+			case paused:
+				sse.send(sseEventError, streamError{Error: saveErr.Error()})
+			default:
+				sse.send(sseEventError, streamError{Error: err.Error()})
+			}
+		}
+		opts := append(rec.options(), compose.WithCallbacks(handler))
+		sr, err := profile.Agent.Stream(ctx, msgs, append(opts, approvals.runOptions(approvalID)...)...)
+		if err != nil {
+			fail(err)
+			return
+		}
+		defer sr.Close()
+
+		// 图的输出是本次运行产生的消息列表，按块拼接
+		var respMsgs []*schema.Message
+		for {
+			chunk, err := sr.Recv()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				fail(err)
+				return
+			}
+			respMsgs = append(respMsgs, chunk...)
+		}
+
+		wait()
+		if err := sessions.saveTurn(ctx, sess, req.Input, respMsgs); err != nil {
+			log.Printf("save session %s error: %v", req.SessionID, err)
+		}
+		sse.send(sseEventDone, streamDone{Output: finalAnswer(respMsgs), SessionID: req.SessionID, Trace: rec.trace(msgs, respMsgs)})
+	}
+}
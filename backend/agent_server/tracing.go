@@ -0,0 +1,421 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aistudiolabx/eino-demo/backend/config"
+	"github.com/cloudwego/eino/callbacks"
+	"github.com/cloudwego/eino/components"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/compose"
+	"github.com/cloudwego/eino/schema"
+)
+
+// traceIDHeader 响应头中返回本次请求的 trace ID
+const traceIDHeader = "X-Trace-Id"
+
+// traceOutputLimit span 中记录的模型/工具输出最多保留的字符数
+const traceOutputLimit = 1024
+
+type traceIDKey struct{}
+type traceSpanKey struct{}
+
+// traceSpan 一个节点、组件或工具调用的执行记录
+type traceSpan struct {
+	TraceID      string         `json:"trace_id"`
+	SpanID       string         `json:"span_id"`
+	ParentSpanID string         `json:"parent_span_id,omitempty"`
+	Name         string         `json:"name"`
+	Component    string         `json:"component"`
+	Type         string         `json:"type,omitempty"`
+	Start        time.Time      `json:"start"`
+	End          time.Time      `json:"end"`
+	DurationMS   float64        `json:"duration_ms"`
+	Error        string         `json:"error,omitempty"`
+	Attributes   map[string]any `json:"attributes,omitempty"`
+}
+
+// spanExporter 导出已结束的 span，需可并发调用
+type spanExporter interface {
+	export(span *traceSpan)
+}
+
+// newSpanExporter 按配置创建导出器；exporter 为 none 时返回 nil
+func newSpanExporter(cfg config.TracingConfig) (spanExporter, error) {
+	switch cfg.Exporter {
+	case "jsonl":
+		if err := os.MkdirAll(filepath.Dir(cfg.File), 0o755); err != nil {
+			return nil, err
+		}
+		f, err := os.OpenFile(cfg.File, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		return &jsonlExporter{w: f}, nil
+	case "otlp-stdout":
+		return &otlpJSONExporter{w: os.Stdout, service: "agent_server"}, nil
+	case "none", "":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q", cfg.Exporter)
+	}
+}
+
+// jsonlExporter 每个 span 写一行 JSON
+type jsonlExporter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (e *jsonlExporter) export(span *traceSpan) {
+	line, err := json.Marshal(span)
+	if err != nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	_, _ = e.w.Write(append(line, '\n'))
+}
+
+// otlpJSONExporter 每个 span 输出一行 OTLP/JSON（ExportTraceServiceRequest），可直接交给 OpenTelemetry Collector 的 otlpjsonfile receiver
+type otlpJSONExporter struct {
+	mu      sync.Mutex
+	w       io.Writer
+	service string
+}
+
+type otlpAttribute struct {
+	Key   string         `json:"key"`
+	Value map[string]any `json:"value"`
+}
+
+func otlpAttributes(attrs map[string]any) []otlpAttribute {
+	out := make([]otlpAttribute, 0, len(attrs))
+	for k, v := range attrs {
+		var value map[string]any
+		switch v := v.(type) {
+		case int:
+			// OTLP/JSON 中 int64 以字符串表示
+			value = map[string]any{"intValue": strconv.Itoa(v)}
+		case float64:
+			value = map[string]any{"doubleValue": v}
+		case bool:
+			value = map[string]any{"boolValue": v}
+		default:
+			value = map[string]any{"stringValue": fmt.Sprint(v)}
+		}
+		out = append(out, otlpAttribute{Key: k, Value: value})
+	}
+	return out
+}
+
+func (e *otlpJSONExporter) export(span *traceSpan) {
+	attrs := map[string]any{"eino.component": span.Component}
+	if span.Type != "" {
+		attrs["eino.type"] = span.Type
+	}
+	for k, v := range span.Attributes {
+		attrs[k] = v
+	}
+	status := map[string]any{"code": 1} // STATUS_CODE_OK
+	if span.Error != "" {
+		status = map[string]any{"code": 2, "message": span.Error} // STATUS_CODE_ERROR
+	}
+	otlpSpan := map[string]any{
+		"traceId":           span.TraceID,
+		"spanId":            span.SpanID,
+		"name":              span.Name,
+		"kind":              1, // SPAN_KIND_INTERNAL
+		"startTimeUnixNano": strconv.FormatInt(span.Start.UnixNano(), 10),
+		"endTimeUnixNano":   strconv.FormatInt(span.End.UnixNano(), 10),
+		"attributes":        otlpAttributes(attrs),
+		"status":            status,
+	}
+	if span.ParentSpanID != "" {
+		otlpSpan["parentSpanId"] = span.ParentSpanID
+	}
+	line, err := json.Marshal(map[string]any{
+		"resourceSpans": []any{map[string]any{
+			"resource": map[string]any{
+				"attributes": otlpAttributes(map[string]any{"service.name": e.service}),
+			},
+			"scopeSpans": []any{map[string]any{
+				"scope": map[string]any{"name": "github.com/cloudwego/eino"},
+				"spans": []any{otlpSpan},
+			}},
+		}},
+	})
+	if err != nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	_, _ = e.w.Write(append(line, '\n'))
+}
+
+// tracingHandler 全局 eino 回调：为每个图、节点、组件与工具调用记录 span，父子关系沿回调的 ctx 传递
+type tracingHandler struct {
+	exporter spanExporter
+}
+
+func newTracingHandler(exporter spanExporter) callbacks.Handler {
+	return &tracingHandler{exporter: exporter}
+}
+
+func (h *tracingHandler) startSpan(ctx context.Context, info *callbacks.RunInfo) (context.Context, *traceSpan) {
+	span := &traceSpan{
+		SpanID:     newTraceHexID(8),
+		Component:  string(info.Component),
+		Type:       info.Type,
+		Name:       info.Name,
+		Start:      time.Now(),
+		Attributes: make(map[string]any),
+	}
+	if span.Name == "" {
+		span.Name = span.Type + span.Component
+	}
+	if parent, ok := ctx.Value(traceSpanKey{}).(*traceSpan); ok {
+		span.TraceID = parent.TraceID
+		span.ParentSpanID = parent.SpanID
+	} else {
+		span.TraceID = traceIDFromContext(ctx)
+	}
+	if id := compose.GetToolCallID(ctx); id != "" && info.Component == components.ComponentOfTool {
+		span.Attributes["tool.call_id"] = id
+	}
+	return context.WithValue(ctx, traceSpanKey{}, span), span
+}
+
+func (h *tracingHandler) endSpan(span *traceSpan, err error) {
+	span.End = time.Now()
+	span.DurationMS = float64(span.End.Sub(span.Start).Microseconds()) / 1000
+	if err != nil {
+		span.Error = err.Error()
+	}
+	h.exporter.export(span)
+}
+
+func spanFromContext(ctx context.Context) *traceSpan {
+	span, _ := ctx.Value(traceSpanKey{}).(*traceSpan)
+	return span
+}
+
+func (h *tracingHandler) OnStart(ctx context.Context, info *callbacks.RunInfo, input callbacks.CallbackInput) context.Context {
+	ctx, span := h.startSpan(ctx, info)
+	recordInput(span, info, input)
+	return ctx
+}
+
+func (h *tracingHandler) OnEnd(ctx context.Context, info *callbacks.RunInfo, output callbacks.CallbackOutput) context.Context {
+	if span := spanFromContext(ctx); span != nil {
+		recordOutput(span, info, output)
+		h.endSpan(span, nil)
+	}
+	return ctx
+}
+
+func (h *tracingHandler) OnError(ctx context.Context, info *callbacks.RunInfo, err error) context.Context {
+	if span := spanFromContext(ctx); span != nil {
+		h.endSpan(span, err)
+	}
+	return ctx
+}
+
+func (h *tracingHandler) OnStartWithStreamInput(ctx context.Context, info *callbacks.RunInfo, input *schema.StreamReader[callbacks.CallbackInput]) context.Context {
+	// 流式输入不做记录，但必须关闭拷贝出来的流
+	input.Close()
+	ctx, span := h.startSpan(ctx, info)
+	span.Attributes["input.stream"] = true
+	return ctx
+}
+
+func (h *tracingHandler) OnEndWithStreamOutput(ctx context.Context, info *callbacks.RunInfo, output *schema.StreamReader[callbacks.CallbackOutput]) context.Context {
+	span := spanFromContext(ctx)
+	if span == nil {
+		output.Close()
+		return ctx
+	}
+	// 流读完时才算节点结束，span 的耗时包含整个流
+	go func() {
+		defer output.Close()
+		var chunks []callbacks.CallbackOutput
+		for {
+			chunk, err := output.Recv()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				h.endSpan(span, err)
+				return
+			}
+			chunks = append(chunks, chunk)
+		}
+		span.Attributes["output.chunks"] = len(chunks)
+		recordStreamOutput(span, info, chunks)
+		h.endSpan(span, nil)
+	}()
+	return ctx
+}
+
+// recordInput 记录输入规模：模型为消息条数与字符数，工具为参数长度，其余组件为 JSON 序列化后的字节数
+func recordInput(span *traceSpan, info *callbacks.RunInfo, input callbacks.CallbackInput) {
+	switch info.Component {
+	case components.ComponentOfChatModel:
+		in := model.ConvCallbackInput(input)
+		if in == nil {
+			return
+		}
+		chars := 0
+		for _, m := range in.Messages {
+			chars += len([]rune(m.Content))
+		}
+		span.Attributes["input.messages"] = len(in.Messages)
+		span.Attributes["input.chars"] = chars
+		span.Attributes["input.tools"] = len(in.Tools)
+	case components.ComponentOfTool:
+		if in := tool.ConvCallbackInput(input); in != nil {
+			span.Attributes["input.size"] = len(in.ArgumentsInJSON)
+			span.Attributes["tool.arguments"] = truncate(in.ArgumentsInJSON, traceOutputLimit)
+		}
+	default:
+		if raw, err := json.Marshal(input); err == nil {
+			span.Attributes["input.size"] = len(raw)
+		}
+	}
+}
+
+// recordOutput 记录输出：模型为回复内容、tool call 数与 token 用量，工具为返回结果
+func recordOutput(span *traceSpan, info *callbacks.RunInfo, output callbacks.CallbackOutput) {
+	switch info.Component {
+	case components.ComponentOfChatModel:
+		if out := model.ConvCallbackOutput(output); out != nil {
+			recordModelOutput(span, out.Message, out.TokenUsage)
+		}
+	case components.ComponentOfTool:
+		if out := tool.ConvCallbackOutput(output); out != nil {
+			span.Attributes["output.size"] = len(out.Response)
+			span.Attributes["tool.result"] = truncate(out.Response, traceOutputLimit)
+		}
+	default:
+		if raw, err := json.Marshal(output); err == nil {
+			span.Attributes["output.size"] = len(raw)
+		}
+	}
+}
+
+// recordStreamOutput 拼接模型/工具的流式输出后记录，与非流式一致
+func recordStreamOutput(span *traceSpan, info *callbacks.RunInfo, chunks []callbacks.CallbackOutput) {
+	switch info.Component {
+	case components.ComponentOfChatModel:
+		var msgs []*schema.Message
+		var usage *model.TokenUsage
+		for _, c := range chunks {
+			out := model.ConvCallbackOutput(c)
+			if out == nil {
+				continue
+			}
+			if out.Message != nil {
+				msgs = append(msgs, out.Message)
+			}
+			if out.TokenUsage != nil {
+				usage = out.TokenUsage
+			}
+		}
+		if len(msgs) == 0 {
+			return
+		}
+		msg, err := schema.ConcatMessages(msgs)
+		if err != nil {
+			return
+		}
+		recordModelOutput(span, msg, usage)
+	case components.ComponentOfTool:
+		var b strings.Builder
+		for _, c := range chunks {
+			if out := tool.ConvCallbackOutput(c); out != nil {
+				b.WriteString(out.Response)
+			}
+		}
+		span.Attributes["output.size"] = b.Len()
+		span.Attributes["tool.result"] = truncate(b.String(), traceOutputLimit)
+	}
+}
+
+func recordModelOutput(span *traceSpan, msg *schema.Message, usage *model.TokenUsage) {
+	if msg != nil {
+		span.Attributes["output.content"] = truncate(msg.Content, traceOutputLimit)
+		span.Attributes["output.tool_calls"] = len(msg.ToolCalls)
+		if usage == nil && msg.ResponseMeta != nil && msg.ResponseMeta.Usage != nil {
+			u := msg.ResponseMeta.Usage
+			usage = &model.TokenUsage{PromptTokens: u.PromptTokens, CompletionTokens: u.CompletionTokens, TotalTokens: u.TotalTokens}
+		}
+	}
+	if usage != nil {
+		span.Attributes["llm.usage.prompt_tokens"] = usage.PromptTokens
+		span.Attributes["llm.usage.completion_tokens"] = usage.CompletionTokens
+		span.Attributes["llm.usage.total_tokens"] = usage.TotalTokens
+	}
+}
+
+func truncate(s string, limit int) string {
+	r := []rune(s)
+	if len(r) <= limit {
+		return s
+	}
+	return string(r[:limit]) + "…"
+}
+
+// withTraceID 为每个请求分配 trace ID（沿用 W3C traceparent 请求头中的 ID），写入响应头与请求 ctx
+func withTraceID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceID := traceIDFromTraceparent(r.Header.Get("traceparent"))
+		if traceID == "" {
+			traceID = newTraceHexID(16)
+		}
+		w.Header().Set(traceIDHeader, traceID)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), traceIDKey{}, traceID)))
+	})
+}
+
+// traceIDFromContext 取请求的 trace ID；不在请求中运行时（例如后台任务）生成新的
+func traceIDFromContext(ctx context.Context) string {
+	if id, ok := ctx.Value(traceIDKey{}).(string); ok {
+		return id
+	}
+	return newTraceHexID(16)
+}
+
+// traceIDFromTraceparent 解析 "00-<32 位 trace-id>-<16 位 parent-id>-<flags>"，格式不对时返回空
+func traceIDFromTraceparent(v string) string {
+	parts := strings.Split(v, "-")
+	if len(parts) != 4 || len(parts[1]) != 32 {
+		return ""
+	}
+	if _, err := hex.DecodeString(parts[1]); err != nil || parts[1] == strings.Repeat("0", 32) {
+		return ""
+	}
+	return strings.ToLower(parts[1])
+}
+
+func newTraceHexID(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		log.Printf("generate trace id error: %v", err)
+	}
+	return hex.EncodeToString(b)
+}
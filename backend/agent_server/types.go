@@ -2,9 +2,26 @@ package main
 
 type agentRequest struct {
 	Input string `json:"input"`
+	// SessionID 可选，指定后在该会话的历史上继续对话，并把本轮消息写回会话
+	SessionID string `json:"session_id,omitempty"`
+	// Prompt 可选，使用指定名字的系统提示模板代替接口默认模板
+	Prompt string `json:"prompt,omitempty"`
+	// Locale 可选，渲染提示模板时的用户语言，如 zh-CN、en-US
+	Locale string `json:"locale,omitempty"`
+	// Verbose 可选，为 true 时响应附带完整的执行过程（见 executionTrace）
+	Verbose bool `json:"verbose,omitempty"`
 }
 
 type agentResponse struct {
-	Output string `json:"output"`
-	Error  string `json:"error,omitempty"`
+	Output    string `json:"output"`
+	SessionID string `json:"session_id,omitempty"`
+	Error     string `json:"error,omitempty"`
+	// Trace 仅在请求 verbose 时返回
+	Trace *executionTrace `json:"trace,omitempty"`
+	// Approval 运行因等待审批而暂停时返回（HTTP 202），见 approval.go
+	Approval *pendingApproval `json:"approval,omitempty"`
+	// Route 经 /chat 调用时返回，说明由哪个子 agent 处理以及如何选出
+	Route *routeDecision `json:"route,omitempty"`
+	// Plan plan_agent 的执行计划：各步骤及其结果
+	Plan *executionPlan `json:"plan,omitempty"`
 }
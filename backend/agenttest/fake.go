@@ -0,0 +1,146 @@
+// Package agenttest agent 测试用的确定性模型：按脚本回复的 FakeChatModel，只供测试引用。
+// 配合 mcp_server/mcptest 的进程内 MCP Server，可以在不访问 Ollama 与外部 API 的情况下
+// 跑通 ReAct 循环、工具调用与审批流程；录制与回放真实模型调用见 modelreplay。
+package agenttest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+// ErrScriptExhausted 脚本中的回复已用完，模型仍被调用
+var ErrScriptExhausted = errors.New("agenttest: fake chat model script exhausted")
+
+// Turn 脚本中的一次模型回复，按顺序消费：
+// Respond 非 nil 时根据本次输入生成回复，否则 Err 非 nil 时返回错误，否则返回 Message
+type Turn struct {
+	Message *schema.Message
+	Respond func(in []*schema.Message) (*schema.Message, error)
+	Err     error
+}
+
+// Reply 返回纯文本回复的 Turn（ReAct 循环的最终回答）
+func Reply(content string) Turn {
+	return Turn{Message: schema.AssistantMessage(content, nil)}
+}
+
+// CallTools 返回调用工具的 Turn
+func CallTools(calls ...schema.ToolCall) Turn {
+	return Turn{Message: schema.AssistantMessage("", calls)}
+}
+
+// ToolCall 构造一个工具调用，args 为 JSON 字符串
+func ToolCall(id, name, args string) schema.ToolCall {
+	return schema.ToolCall{ID: id, Type: "function", Function: schema.FunctionCall{Name: name, Arguments: args}}
+}
+
+// fakeScript WithTools 得到的各个实例共享同一份脚本与调用记录
+type fakeScript struct {
+	mu    sync.Mutex
+	turns []Turn
+	calls [][]*schema.Message
+	tools []*schema.ToolInfo
+}
+
+// FakeChatModel 按脚本依次回复的 ToolCallingChatModel，可并发调用
+type FakeChatModel struct {
+	script *fakeScript
+	tools  []*schema.ToolInfo
+}
+
+var _ model.ToolCallingChatModel = (*FakeChatModel)(nil)
+
+// NewFakeChatModel 创建按 turns 顺序回复的模型
+func NewFakeChatModel(turns ...Turn) *FakeChatModel {
+	return &FakeChatModel{script: &fakeScript{turns: turns}}
+}
+
+// Generate 消费脚本中的下一个 Turn
+func (m *FakeChatModel) Generate(ctx context.Context, in []*schema.Message, _ ...model.Option) (*schema.Message, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s := m.script
+	s.mu.Lock()
+	s.calls = append(s.calls, append([]*schema.Message(nil), in...))
+	if len(s.turns) == 0 {
+		s.mu.Unlock()
+		return nil, fmt.Errorf("%w (call %d)", ErrScriptExhausted, len(s.calls))
+	}
+	turn := s.turns[0]
+	s.turns = s.turns[1:]
+	s.mu.Unlock()
+
+	switch {
+	case turn.Respond != nil:
+		return turn.Respond(in)
+	case turn.Err != nil:
+		return nil, turn.Err
+	case turn.Message == nil:
+		return schema.AssistantMessage("", nil), nil
+	}
+	// 返回副本，避免调用方修改脚本中的消息
+	msg := *turn.Message
+	return &msg, nil
+}
+
+// Stream 与 Generate 消费同一份脚本；文本内容按字符拆成多个分片，工具调用放在首个分片
+func (m *FakeChatModel) Stream(ctx context.Context, in []*schema.Message, opts ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	msg, err := m.Generate(ctx, in, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return schema.StreamReaderFromArray(splitMessage(msg)), nil
+}
+
+// WithTools 记录绑定的工具，返回共享脚本的新实例
+func (m *FakeChatModel) WithTools(tools []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	m.script.mu.Lock()
+	m.script.tools = tools
+	m.script.mu.Unlock()
+	return &FakeChatModel{script: m.script, tools: tools}, nil
+}
+
+// Calls 每次调用收到的输入消息，按调用顺序
+func (m *FakeChatModel) Calls() [][]*schema.Message {
+	m.script.mu.Lock()
+	defer m.script.mu.Unlock()
+	return append([][]*schema.Message(nil), m.script.calls...)
+}
+
+// BoundTools 最近一次 WithTools 绑定的工具
+func (m *FakeChatModel) BoundTools() []*schema.ToolInfo {
+	m.script.mu.Lock()
+	defer m.script.mu.Unlock()
+	return m.script.tools
+}
+
+// Remaining 尚未消费的 Turn 数量，测试结束时通常应为 0
+func (m *FakeChatModel) Remaining() int {
+	m.script.mu.Lock()
+	defer m.script.mu.Unlock()
+	return len(m.script.turns)
+}
+
+// splitMessage 把消息拆成流式分片，拼接（schema.ConcatMessages）后与原消息一致
+func splitMessage(msg *schema.Message) []*schema.Message {
+	runes := []rune(msg.Content)
+	if len(runes) <= 1 {
+		return []*schema.Message{msg}
+	}
+	chunks := make([]*schema.Message, 0, len(runes))
+	for i, r := range runes {
+		chunk := &schema.Message{Role: msg.Role, Content: string(r)}
+		if i == 0 {
+			chunk.ToolCalls = msg.ToolCalls
+			chunk.ResponseMeta = msg.ResponseMeta
+		}
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
@@ -0,0 +1,511 @@
+// Package config 三个服务（MCP Server、Agent Server、Frontend Server）共用的配置：
+// 先取默认值，再用 --config 指定的 YAML/JSON 文件覆盖，最后用环境变量覆盖，启动时统一校验。
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config 全部服务的配置
+type Config struct {
+	Agent    AgentConfig    `yaml:"agent"`
+	MCP      MCPConfig      `yaml:"mcp"`
+	Frontend FrontendConfig `yaml:"frontend"`
+}
+
+// AgentConfig Agent Server 配置
+type AgentConfig struct {
+	// Addr 监听地址
+	Addr string `yaml:"addr"`
+	// CORSOrigin 允许跨域访问的前端来源
+	CORSOrigin string `yaml:"cors_origin"`
+	// MCPEndpoint 单个 MCP Server 的地址（格式同 MCPServerConfig.Endpoint），仅在未配置 MCPServers 时使用
+	MCPEndpoint string `yaml:"mcp_endpoint"`
+	// MCPServers 要连接的 MCP Server 列表，各 server 的工具合并后绑定到 agent
+	MCPServers []MCPServerConfig `yaml:"mcp_servers"`
+	// MaxIterations 单次运行中模型调用次数上限
+	MaxIterations int            `yaml:"max_iterations"`
+	Model         ModelConfig    `yaml:"model"`
+	Session       SessionConfig  `yaml:"session"`
+	Prompts       PromptConfig   `yaml:"prompts"`
+	Tools         ToolsConfig    `yaml:"tools"`
+	Tracing       TracingConfig  `yaml:"tracing"`
+	Jobs          JobsConfig     `yaml:"jobs"`
+	Approval      ApprovalConfig `yaml:"approval"`
+	Router        RouterConfig   `yaml:"router"`
+	Plan          PlanConfig     `yaml:"plan"`
+}
+
+// PlanConfig plan_agent（先规划再执行）配置
+type PlanConfig struct {
+	// MaxSteps 一次规划（或重新规划）最多包含的步骤数
+	MaxSteps int `yaml:"max_steps"`
+	// MaxReplans 步骤失败后最多重新规划的次数，用完后直接根据已有结果回答
+	MaxReplans int `yaml:"max_replans"`
+}
+
+// RouterConfig /chat 意图路由配置：先判断用户意图，再交给对应的子 agent（profile）处理
+type RouterConfig struct {
+	// Mode 意图判断方式：rules（关键词匹配）、llm（由模型从 Routes 中选择）或 auto（关键词未命中时再交给模型）
+	Mode string `yaml:"mode"`
+	// Default 无法判断意图时使用的子 agent
+	Default string `yaml:"default"`
+	// Routes 可路由的子 agent，按顺序匹配，关键词命中数相同时靠前的优先
+	Routes []RouteConfig `yaml:"routes"`
+}
+
+// RouteConfig 一个子 agent 的路由信息
+type RouteConfig struct {
+	// Agent 子 agent（profile）名，如 agent、tool_agent
+	Agent string `yaml:"agent"`
+	// Description 子 agent 能处理什么请求，llm 模式下提供给模型
+	Description string `yaml:"description"`
+	// Keywords 关键词，输入包含任一关键词（不区分大小写）即视为命中
+	Keywords []string `yaml:"keywords"`
+}
+
+// ApprovalConfig 人工审批配置：模型要调用匹配 Tools 的工具时，运行在执行工具前暂停，
+// 待 POST /approvals/{id} 批准、修改参数或拒绝后再继续
+type ApprovalConfig struct {
+	// Tools 需要审批的工具名或 glob，为空则所有工具直接执行
+	Tools []string `yaml:"tools"`
+	// Store 暂停运行的保存方式：memory 或 file（重启后仍可审批）
+	Store string `yaml:"store"`
+	// Dir file 存储的目录
+	Dir string `yaml:"dir"`
+}
+
+// JobsConfig 异步任务（/jobs）配置：任务进入有界队列，由固定数量的 worker 执行
+type JobsConfig struct {
+	// Workers 同时执行的任务数
+	Workers int `yaml:"workers"`
+	// QueueSize 排队等待的任务数上限，队列满时 POST /jobs 返回 503
+	QueueSize int `yaml:"queue_size"`
+	// Timeout 单个任务的运行超时
+	Timeout time.Duration `yaml:"timeout"`
+	// Retention 已结束任务的保留时长，过期后查询返回 404
+	Retention time.Duration `yaml:"retention"`
+}
+
+// ToolsConfig 各接口允许绑定的工具；每项为工具名或 glob（如 weather_*、*），列表为空则不绑定任何工具。
+// 工具名为合并后的名字（含 tool_prefix 与重名时的 <server>_ 前缀）
+type ToolsConfig struct {
+	// Agent /agent 与 /agent/stream 可用的工具
+	Agent []string `yaml:"agent"`
+	// ToolAgent /tool_agent 可用的工具
+	ToolAgent []string `yaml:"tool_agent"`
+	// PlanAgent /plan_agent 执行各步骤时可用的工具
+	PlanAgent []string `yaml:"plan_agent"`
+}
+
+// TracingConfig 节点级追踪配置
+type TracingConfig struct {
+	// Exporter span 导出方式：none、jsonl（写入 File）或 otlp-stdout（OTLP/JSON 输出到标准输出）
+	Exporter string `yaml:"exporter"`
+	// File jsonl 导出的文件路径
+	File string `yaml:"file"`
+}
+
+// PromptConfig 系统提示模板配置；模板文件修改后下次请求即生效，无需重启
+type PromptConfig struct {
+	// Dir 模板目录，模板名 <name> 对应 <name>.txt（FString）或 <name>.jinja（Jinja2）
+	Dir string `yaml:"dir"`
+	// Locale 请求未指定 locale 且没有 Accept-Language 时使用的默认值
+	Locale string `yaml:"locale"`
+	// Agent /agent 与 /agent/stream 使用的模板名
+	Agent string `yaml:"agent"`
+	// ToolAgent /tool_agent 使用的模板名
+	ToolAgent string `yaml:"tool_agent"`
+	// PlanAgent /plan_agent 执行各步骤时使用的模板名
+	PlanAgent string `yaml:"plan_agent"`
+}
+
+// MCPServerConfig 一个 MCP Server 连接
+type MCPServerConfig struct {
+	// Name server 名称，用于日志与工具重名时的前缀
+	Name string `yaml:"name"`
+	// Endpoint 连接地址，按 scheme 选择传输方式：
+	// 路径以 /sse 结尾的 http(s) 地址走 SSE，其余 http(s) 地址走 Streamable HTTP，
+	// "stdio:<命令> [参数...]" 以子进程方式启动 server 并通过 stdin/stdout 通信
+	Endpoint string `yaml:"endpoint"`
+	// Optional 为 true 时连接失败只记录 WARN（否则为 ERROR）；无论哪种，agent 都会在后台重试并先用其余 server 的工具运行
+	Optional bool `yaml:"optional"`
+	// ToolPrefix 非空时该 server 的所有工具名都加上此前缀
+	ToolPrefix string `yaml:"tool_prefix"`
+}
+
+// ModelConfig 聊天模型配置
+type ModelConfig struct {
+	// Provider 模型提供方，如 ollama、openai
+	Provider string `yaml:"provider"`
+	// BaseURL 模型服务地址，为空时使用 provider 的默认地址
+	BaseURL string `yaml:"base_url"`
+	// Model 模型名，为空时使用 provider 的默认模型
+	Model string `yaml:"model"`
+	// APIKey 鉴权密钥，建议通过环境变量 MODEL_API_KEY 提供
+	APIKey string `yaml:"api_key"`
+	// Temperature 采样温度，不设置则使用服务端默认值
+	Temperature *float32 `yaml:"temperature"`
+	// Timeout 单次模型请求的超时时间
+	Timeout time.Duration `yaml:"timeout"`
+	// Record 非空时把每次模型调用的输入与回复录制到该 golden 文件（见 modelreplay.Recorder）
+	Record string `yaml:"record"`
+	// Replay provider 为 replay 时回放的 golden 文件，不访问任何模型服务
+	Replay string `yaml:"replay"`
+}
+
+// SessionConfig 会话历史配置
+type SessionConfig struct {
+	// Store 存储方式：memory 或 file
+	Store string `yaml:"store"`
+	// Dir file 存储的目录
+	Dir string `yaml:"dir"`
+	// MaxMessages 发送给模型的历史消息条数上限
+	MaxMessages int `yaml:"max_messages"`
+	// MaxTokens 发送给模型的历史 token 估算上限
+	MaxTokens int `yaml:"max_tokens"`
+}
+
+// MCPConfig MCP Server 配置
+type MCPConfig struct {
+	// Addr 监听地址，stdio 传输下不使用
+	Addr string `yaml:"addr"`
+	// Transport 对外提供的传输方式：sse（/sse 与 /message）、http（Streamable HTTP，/mcp）或 stdio
+	Transport  string           `yaml:"transport"`
+	RunningHub RunningHubConfig `yaml:"runninghub"`
+	OpenMeteo  OpenMeteoConfig  `yaml:"openmeteo"`
+	Weather    WeatherConfig    `yaml:"weather"`
+}
+
+// WeatherConfig 天气数据源：地理编码、当前天气与预报按 Providers 的顺序尝试，出错或超时时切换到下一个；
+// 空气质量与历史天气只有 Open-Meteo 提供，不参与切换
+type WeatherConfig struct {
+	// Providers 数据源顺序：open-meteo、wttr
+	Providers []string `yaml:"providers"`
+	// ProviderTimeout 每个数据源单次调用的超时，超时后切换到下一个
+	ProviderTimeout time.Duration `yaml:"provider_timeout"`
+	Wttr            WttrConfig    `yaml:"wttr"`
+}
+
+// WttrConfig wttr.in 备用数据源
+type WttrConfig struct {
+	// BaseURL 服务地址，可指向自建实例或测试桩
+	BaseURL string `yaml:"base_url"`
+	// RequestTimeout 单次 HTTP 请求超时
+	RequestTimeout time.Duration `yaml:"request_timeout"`
+}
+
+// OpenMeteoConfig Open-Meteo 接口地址，可指向自建实例或测试桩
+type OpenMeteoConfig struct {
+	// GeocodeURL 地理编码接口
+	GeocodeURL string `yaml:"geocode_url"`
+	// ForecastURL 天气预报接口
+	ForecastURL string `yaml:"forecast_url"`
+	// AirQualityURL 空气质量接口
+	AirQualityURL string `yaml:"air_quality_url"`
+	// ArchiveURL 历史天气接口
+	ArchiveURL string `yaml:"archive_url"`
+	// RequestTimeout 单次 HTTP 请求超时
+	RequestTimeout time.Duration `yaml:"request_timeout"`
+}
+
+// RunningHubConfig RunningHub 工作流配置
+type RunningHubConfig struct {
+	// BaseURL RunningHub OpenAPI 地址
+	BaseURL string `yaml:"base_url"`
+	// APIKey 建议通过环境变量 RUNNINGHUB_API_KEY 提供
+	APIKey string `yaml:"api_key"`
+	// NovelToScriptWorkflowID 小说转剧本工作流 ID
+	NovelToScriptWorkflowID string `yaml:"novel_to_script_workflow_id"`
+	// RequestTimeout 单次 HTTP 请求超时
+	RequestTimeout time.Duration `yaml:"request_timeout"`
+	// PollInterval 任务状态轮询间隔
+	PollInterval time.Duration `yaml:"poll_interval"`
+	// RunTimeout 单个工作流任务的总超时
+	RunTimeout time.Duration `yaml:"run_timeout"`
+}
+
+// FrontendConfig Frontend Server 配置
+type FrontendConfig struct {
+	// Addr 监听地址
+	Addr string `yaml:"addr"`
+	// Dir 静态文件目录，相对路径以启动目录为准
+	Dir string `yaml:"dir"`
+}
+
+// Default 返回默认配置，与各服务原先写死的值一致
+func Default() *Config {
+	return &Config{
+		Agent: AgentConfig{
+			Addr:          ":8082",
+			CORSOrigin:    "http://localhost:8081",
+			MCPEndpoint:   "http://localhost:3333/sse",
+			MaxIterations: 8,
+			Model: ModelConfig{
+				Provider: "ollama",
+				Timeout:  2 * time.Minute,
+			},
+			Session: SessionConfig{
+				Store:       "memory",
+				Dir:         "data/sessions",
+				MaxMessages: 40,
+				MaxTokens:   4000,
+			},
+			Prompts: PromptConfig{
+				Dir:       "prompts",
+				Locale:    "zh-CN",
+				Agent:     "agent",
+				ToolAgent: "tool_agent",
+				PlanAgent: "plan_agent",
+			},
+			// 天气页只需要天气与空气质量工具，付费的 RunningHub 工作流只开放给 /tool_agent 与需要组合多个工具的 /plan_agent
+			Tools: ToolsConfig{
+				Agent:     []string{"weather", "weather_*", "air_quality"},
+				ToolAgent: []string{"novel_to_script"},
+				PlanAgent: []string{"weather", "weather_*", "air_quality", "novel_to_script"},
+			},
+			Tracing: TracingConfig{
+				Exporter: "none",
+				File:     "data/traces.jsonl",
+			},
+			Jobs: JobsConfig{
+				Workers:   2,
+				QueueSize: 16,
+				Timeout:   15 * time.Minute,
+				Retention: time.Hour,
+			},
+			// RunningHub 工作流按次计费，执行前需要人工确认
+			Approval: ApprovalConfig{
+				Tools: []string{"novel_to_script"},
+				Store: "memory",
+				Dir:   "data/approvals",
+			},
+			Router: RouterConfig{
+				Mode:    "auto",
+				Default: "agent",
+				Routes: []RouteConfig{
+					{
+						Agent:       "plan_agent",
+						Description: "需要多个步骤或多个工具配合完成的复合请求，例如先查天气再据此写剧本",
+						Keywords:    []string{"然后", "并且", "接着", "再根据", "并根据", "and then"},
+					},
+					{
+						Agent:       "agent",
+						Description: "查询城市天气与空气质量，以及闲聊等一般问题",
+						Keywords:    []string{"天气", "气温", "温度", "下雨", "空气", "雾霾", "pm2.5", "weather", "aqi"},
+					},
+					{
+						Agent:       "tool_agent",
+						Description: "把小说正文转换成剧本（调用按次计费的工作流）",
+						Keywords:    []string{"剧本", "小说", "script", "novel"},
+					},
+				},
+			},
+			Plan: PlanConfig{
+				MaxSteps:   6,
+				MaxReplans: 2,
+			},
+		},
+		MCP: MCPConfig{
+			Addr:      ":3333",
+			Transport: "sse",
+			RunningHub: RunningHubConfig{
+				BaseURL:                 "https://www.runninghub.ai",
+				NovelToScriptWorkflowID: "2014935539987783681",
+				RequestTimeout:          30 * time.Second,
+				PollInterval:            2 * time.Second,
+				RunTimeout:              10 * time.Minute,
+			},
+			OpenMeteo: OpenMeteoConfig{
+				GeocodeURL:     "https://geocoding-api.open-meteo.com/v1/search",
+				ForecastURL:    "https://api.open-meteo.com/v1/forecast",
+				AirQualityURL:  "https://air-quality-api.open-meteo.com/v1/air-quality",
+				ArchiveURL:     "https://archive-api.open-meteo.com/v1/archive",
+				RequestTimeout: 10 * time.Second,
+			},
+			Weather: WeatherConfig{
+				Providers:       []string{"open-meteo", "wttr"},
+				ProviderTimeout: 5 * time.Second,
+				Wttr: WttrConfig{
+					BaseURL:        "https://wttr.in",
+					RequestTimeout: 10 * time.Second,
+				},
+			},
+		},
+		Frontend: FrontendConfig{
+			Addr: ":8081",
+			Dir:  "frontend",
+		},
+	}
+}
+
+// Load 读取配置：默认值 → 配置文件（path 为空则跳过）→ 环境变量，最后校验
+func Load(path string) (*Config, error) {
+	cfg := Default()
+	if path != "" {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("读取配置文件失败: %w", err)
+		}
+		// YAML 是 JSON 的超集，两种格式都用同一个解析器；未知字段直接报错，避免拼错的配置项被静默忽略
+		dec := yaml.NewDecoder(bytes.NewReader(raw))
+		dec.KnownFields(true)
+		// 空文件解码时返回 io.EOF，视为没有覆盖任何配置
+		if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("解析配置文件 %s 失败: %w", path, err)
+		}
+	}
+	if err := applyEnv(cfg); err != nil {
+		return nil, err
+	}
+	// 兼容只配置单个 mcp_endpoint（或 MCP_WEATHER_ENDPOINT）的用法
+	if len(cfg.Agent.MCPServers) == 0 {
+		cfg.Agent.MCPServers = []MCPServerConfig{{Name: "default", Endpoint: cfg.Agent.MCPEndpoint}}
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Validate 校验配置，返回所有问题（每行一个字段）
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, field, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: %s", field, fmt.Sprintf(format, args...)))
+		}
+	}
+
+	check(c.Agent.Addr != "", "agent.addr", "不能为空")
+	check(c.Agent.CORSOrigin != "", "agent.cors_origin", "不能为空")
+	check(len(c.Agent.MCPServers) > 0 || isMCPEndpoint(c.Agent.MCPEndpoint), "agent.mcp_endpoint", "需要 http(s) 地址或 stdio:<命令>，当前为 %q", c.Agent.MCPEndpoint)
+	serverNames := make(map[string]bool)
+	for i, srv := range c.Agent.MCPServers {
+		field := fmt.Sprintf("agent.mcp_servers[%d]", i)
+		check(toolNamePattern.MatchString(srv.Name), field+".name", "只能包含字母、数字、下划线与连字符，当前为 %q", srv.Name)
+		check(!serverNames[srv.Name], field+".name", "与其他 server 重名：%q", srv.Name)
+		check(isMCPEndpoint(srv.Endpoint), field+".endpoint", "需要 http(s) 地址或 stdio:<命令>，当前为 %q", srv.Endpoint)
+		check(srv.ToolPrefix == "" || toolNamePattern.MatchString(srv.ToolPrefix), field+".tool_prefix", "只能包含字母、数字、下划线与连字符，当前为 %q", srv.ToolPrefix)
+		serverNames[srv.Name] = true
+	}
+	check(c.Agent.MaxIterations > 0, "agent.max_iterations", "必须大于 0，当前为 %d", c.Agent.MaxIterations)
+	check(c.Agent.Model.Provider != "", "agent.model.provider", "不能为空")
+	check(c.Agent.Model.BaseURL == "" || isHTTPURL(c.Agent.Model.BaseURL), "agent.model.base_url", "需要 http(s) 地址，当前为 %q", c.Agent.Model.BaseURL)
+	check(c.Agent.Model.Timeout > 0, "agent.model.timeout", "必须大于 0")
+	check(c.Agent.Model.Provider != "replay" || c.Agent.Model.Replay != "", "agent.model.replay", "replay provider 需要指定 golden 文件")
+	check(c.Agent.Model.Provider != "replay" || c.Agent.Model.Record == "", "agent.model.record", "replay provider 不能同时录制")
+	if t := c.Agent.Model.Temperature; t != nil {
+		check(*t >= 0 && *t <= 2, "agent.model.temperature", "取值范围 0~2，当前为 %g", *t)
+	}
+	check(c.Agent.Session.Store == "memory" || c.Agent.Session.Store == "file", "agent.session.store", "只能是 memory 或 file，当前为 %q", c.Agent.Session.Store)
+	check(c.Agent.Session.Store != "file" || c.Agent.Session.Dir != "", "agent.session.dir", "file 存储需要指定目录")
+	check(c.Agent.Session.MaxMessages >= 0, "agent.session.max_messages", "不能为负数")
+	check(c.Agent.Session.MaxTokens >= 0, "agent.session.max_tokens", "不能为负数")
+	check(c.Agent.Prompts.Dir != "", "agent.prompts.dir", "不能为空")
+	check(toolNamePattern.MatchString(c.Agent.Prompts.Agent), "agent.prompts.agent", "模板名只能包含字母、数字、下划线与连字符，当前为 %q", c.Agent.Prompts.Agent)
+	check(toolNamePattern.MatchString(c.Agent.Prompts.ToolAgent), "agent.prompts.tool_agent", "模板名只能包含字母、数字、下划线与连字符，当前为 %q", c.Agent.Prompts.ToolAgent)
+	check(toolNamePattern.MatchString(c.Agent.Prompts.PlanAgent), "agent.prompts.plan_agent", "模板名只能包含字母、数字、下划线与连字符，当前为 %q", c.Agent.Prompts.PlanAgent)
+	for i, p := range c.Agent.Tools.Agent {
+		check(isToolPattern(p), fmt.Sprintf("agent.tools.agent[%d]", i), "不是合法的工具名或 glob：%q", p)
+	}
+	for i, p := range c.Agent.Tools.ToolAgent {
+		check(isToolPattern(p), fmt.Sprintf("agent.tools.tool_agent[%d]", i), "不是合法的工具名或 glob：%q", p)
+	}
+	for i, p := range c.Agent.Tools.PlanAgent {
+		check(isToolPattern(p), fmt.Sprintf("agent.tools.plan_agent[%d]", i), "不是合法的工具名或 glob：%q", p)
+	}
+	tr := c.Agent.Tracing
+	check(tr.Exporter == "none" || tr.Exporter == "jsonl" || tr.Exporter == "otlp-stdout", "agent.tracing.exporter", "只能是 none、jsonl 或 otlp-stdout，当前为 %q", tr.Exporter)
+	check(tr.Exporter != "jsonl" || tr.File != "", "agent.tracing.file", "jsonl 导出需要指定文件")
+	check(c.Agent.Jobs.Workers > 0, "agent.jobs.workers", "必须大于 0，当前为 %d", c.Agent.Jobs.Workers)
+	check(c.Agent.Jobs.QueueSize >= 0, "agent.jobs.queue_size", "不能为负数")
+	check(c.Agent.Jobs.Timeout > 0, "agent.jobs.timeout", "必须大于 0")
+	check(c.Agent.Jobs.Retention > 0, "agent.jobs.retention", "必须大于 0")
+	for i, p := range c.Agent.Approval.Tools {
+		check(isToolPattern(p), fmt.Sprintf("agent.approval.tools[%d]", i), "不是合法的工具名或 glob：%q", p)
+	}
+	check(c.Agent.Approval.Store == "memory" || c.Agent.Approval.Store == "file", "agent.approval.store", "只能是 memory 或 file，当前为 %q", c.Agent.Approval.Store)
+	check(c.Agent.Approval.Store != "file" || c.Agent.Approval.Dir != "", "agent.approval.dir", "file 存储需要指定目录")
+	rt := c.Agent.Router
+	check(rt.Mode == "rules" || rt.Mode == "llm" || rt.Mode == "auto", "agent.router.mode", "只能是 rules、llm 或 auto，当前为 %q", rt.Mode)
+	check(len(rt.Routes) > 0, "agent.router.routes", "至少需要一个子 agent")
+	defaultRouted := false
+	for i, r := range rt.Routes {
+		check(r.Agent != "", fmt.Sprintf("agent.router.routes[%d].agent", i), "不能为空")
+		defaultRouted = defaultRouted || r.Agent == rt.Default
+	}
+	check(defaultRouted, "agent.router.default", "必须是 routes 中的子 agent，当前为 %q", rt.Default)
+	check(c.Agent.Plan.MaxSteps > 0, "agent.plan.max_steps", "必须大于 0，当前为 %d", c.Agent.Plan.MaxSteps)
+	check(c.Agent.Plan.MaxReplans >= 0, "agent.plan.max_replans", "不能为负数")
+
+	check(c.MCP.Transport == "stdio" || c.MCP.Addr != "", "mcp.addr", "不能为空")
+	check(c.MCP.Transport == "sse" || c.MCP.Transport == "http" || c.MCP.Transport == "stdio", "mcp.transport", "只能是 sse、http 或 stdio，当前为 %q", c.MCP.Transport)
+	rh := c.MCP.RunningHub
+	check(isHTTPURL(rh.BaseURL), "mcp.runninghub.base_url", "需要 http(s) 地址，当前为 %q", rh.BaseURL)
+	check(rh.NovelToScriptWorkflowID != "", "mcp.runninghub.novel_to_script_workflow_id", "不能为空")
+	check(rh.RequestTimeout > 0, "mcp.runninghub.request_timeout", "必须大于 0")
+	check(rh.PollInterval > 0, "mcp.runninghub.poll_interval", "必须大于 0")
+	check(rh.RunTimeout > rh.PollInterval, "mcp.runninghub.run_timeout", "必须大于 poll_interval")
+	om := c.MCP.OpenMeteo
+	check(isHTTPURL(om.GeocodeURL), "mcp.openmeteo.geocode_url", "需要 http(s) 地址，当前为 %q", om.GeocodeURL)
+	check(isHTTPURL(om.ForecastURL), "mcp.openmeteo.forecast_url", "需要 http(s) 地址，当前为 %q", om.ForecastURL)
+	check(isHTTPURL(om.AirQualityURL), "mcp.openmeteo.air_quality_url", "需要 http(s) 地址，当前为 %q", om.AirQualityURL)
+	check(isHTTPURL(om.ArchiveURL), "mcp.openmeteo.archive_url", "需要 http(s) 地址，当前为 %q", om.ArchiveURL)
+	check(om.RequestTimeout > 0, "mcp.openmeteo.request_timeout", "必须大于 0")
+	wc := c.MCP.Weather
+	check(len(wc.Providers) > 0, "mcp.weather.providers", "至少需要一个数据源")
+	seen := map[string]bool{}
+	for i, p := range wc.Providers {
+		check(p == "open-meteo" || p == "wttr", fmt.Sprintf("mcp.weather.providers[%d]", i), "只能是 open-meteo 或 wttr，当前为 %q", p)
+		check(!seen[p], fmt.Sprintf("mcp.weather.providers[%d]", i), "重复的数据源 %q", p)
+		seen[p] = true
+	}
+	check(wc.ProviderTimeout > 0, "mcp.weather.provider_timeout", "必须大于 0")
+	check(isHTTPURL(wc.Wttr.BaseURL), "mcp.weather.wttr.base_url", "需要 http(s) 地址，当前为 %q", wc.Wttr.BaseURL)
+	check(wc.Wttr.RequestTimeout > 0, "mcp.weather.wttr.request_timeout", "必须大于 0")
+
+	check(c.Frontend.Addr != "", "frontend.addr", "不能为空")
+	check(c.Frontend.Dir != "", "frontend.dir", "不能为空")
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("配置校验失败:\n%w", errors.Join(errs...))
+}
+
+// toolNamePattern 工具名允许的字符（与 OpenAI function name 的约束一致）
+var toolNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// isToolPattern 是否为合法的工具名 glob（path.Match 语法）
+func isToolPattern(p string) bool {
+	if p == "" {
+		return false
+	}
+	_, err := path.Match(p, "")
+	return err == nil
+}
+
+// isMCPEndpoint 是否为 agent 可连接的 MCP 地址：http(s) URL 或 "stdio:<命令>"
+func isMCPEndpoint(s string) bool {
+	if cmd, ok := strings.CutPrefix(s, "stdio:"); ok {
+		return strings.TrimSpace(cmd) != ""
+	}
+	return isHTTPURL(s)
+}
+
+func isHTTPURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
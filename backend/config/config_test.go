@@ -0,0 +1,240 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeConfig 把 content 写入临时配置文件，返回路径
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadDefaults(t *testing.T) {
+	for _, content := range []string{"", "# 只有注释\n"} {
+		cfg, err := Load(writeConfig(t, content))
+		if err != nil {
+			t.Fatalf("Load(%q): %v", content, err)
+		}
+		if cfg.Agent.Addr != ":8082" || cfg.MCP.Weather.Wttr.RequestTimeout != 10*time.Second {
+			t.Errorf("Load(%q) did not keep defaults: agent.addr=%q", content, cfg.Agent.Addr)
+		}
+		// 未配置 mcp_servers 时由 mcp_endpoint 生成一个 default server
+		if len(cfg.Agent.MCPServers) != 1 || cfg.Agent.MCPServers[0].Endpoint != cfg.Agent.MCPEndpoint {
+			t.Errorf("mcp_servers = %+v, want one default server for mcp_endpoint", cfg.Agent.MCPServers)
+		}
+	}
+}
+
+func TestLoadRejectsUnknownKeys(t *testing.T) {
+	for _, tc := range []struct{ content, field string }{
+		{"agent:\n  adr: \":9000\"\n", "adr"},
+		{"mcp:\n  weather:\n    wttr:\n      timeout: 5s\n", "timeout"},
+		{"frontends:\n  addr: \":1\"\n", "frontends"},
+	} {
+		_, err := Load(writeConfig(t, tc.content))
+		if err == nil || !strings.Contains(err.Error(), tc.field) {
+			t.Errorf("Load(%q) error = %v, want unknown field %q", tc.content, err, tc.field)
+		}
+	}
+}
+
+func TestLoadMissingFile(t *testing.T) {
+	if _, err := Load(filepath.Join(t.TempDir(), "nope.yaml")); err == nil {
+		t.Fatal("Load succeeded for missing file")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := Default().Validate(); err != nil {
+		t.Fatalf("default config invalid: %v", err)
+	}
+
+	for _, tc := range []struct {
+		field  string
+		mutate func(c *Config)
+	}{
+		{"agent.addr", func(c *Config) { c.Agent.Addr = "" }},
+		{"agent.cors_origin", func(c *Config) { c.Agent.CORSOrigin = "" }},
+		{"agent.mcp_endpoint", func(c *Config) { c.Agent.MCPEndpoint = "localhost:3333" }},
+		{"agent.mcp_endpoint", func(c *Config) { c.Agent.MCPEndpoint = "stdio: " }},
+		{"agent.mcp_servers[0].name", func(c *Config) { c.Agent.MCPServers = []MCPServerConfig{{Name: "a b", Endpoint: "stdio:x"}} }},
+		{"agent.mcp_servers[1].name", func(c *Config) {
+			c.Agent.MCPServers = []MCPServerConfig{{Name: "a", Endpoint: "stdio:x"}, {Name: "a", Endpoint: "stdio:y"}}
+		}},
+		{"agent.mcp_servers[0].endpoint", func(c *Config) { c.Agent.MCPServers = []MCPServerConfig{{Name: "a", Endpoint: "ftp://x"}} }},
+		{"agent.mcp_servers[0].tool_prefix", func(c *Config) {
+			c.Agent.MCPServers = []MCPServerConfig{{Name: "a", Endpoint: "stdio:x", ToolPrefix: "a."}}
+		}},
+		{"agent.max_iterations", func(c *Config) { c.Agent.MaxIterations = 0 }},
+		{"agent.model.provider", func(c *Config) { c.Agent.Model.Provider = "" }},
+		{"agent.model.base_url", func(c *Config) { c.Agent.Model.BaseURL = "localhost:11434" }},
+		{"agent.model.timeout", func(c *Config) { c.Agent.Model.Timeout = 0 }},
+		{"agent.model.replay", func(c *Config) { c.Agent.Model.Provider = "replay" }},
+		{"agent.model.record", func(c *Config) {
+			c.Agent.Model.Provider, c.Agent.Model.Replay, c.Agent.Model.Record = "replay", "a.json", "b.json"
+		}},
+		{"agent.model.temperature", func(c *Config) { t := float32(2.5); c.Agent.Model.Temperature = &t }},
+		{"agent.session.store", func(c *Config) { c.Agent.Session.Store = "redis" }},
+		{"agent.session.dir", func(c *Config) { c.Agent.Session.Store, c.Agent.Session.Dir = "file", "" }},
+		{"agent.session.max_messages", func(c *Config) { c.Agent.Session.MaxMessages = -1 }},
+		{"agent.session.max_tokens", func(c *Config) { c.Agent.Session.MaxTokens = -1 }},
+		{"agent.prompts.dir", func(c *Config) { c.Agent.Prompts.Dir = "" }},
+		{"agent.prompts.agent", func(c *Config) { c.Agent.Prompts.Agent = "../agent" }},
+		{"agent.prompts.tool_agent", func(c *Config) { c.Agent.Prompts.ToolAgent = "" }},
+		{"agent.prompts.plan_agent", func(c *Config) { c.Agent.Prompts.PlanAgent = "plan agent" }},
+		{"agent.tools.agent[1]", func(c *Config) { c.Agent.Tools.Agent = []string{"weather", "weather_["} }},
+		{"agent.tools.tool_agent[0]", func(c *Config) { c.Agent.Tools.ToolAgent = []string{""} }},
+		{"agent.tools.plan_agent[0]", func(c *Config) { c.Agent.Tools.PlanAgent = []string{"["} }},
+		{"agent.tracing.exporter", func(c *Config) { c.Agent.Tracing.Exporter = "jaeger" }},
+		{"agent.tracing.file", func(c *Config) { c.Agent.Tracing.Exporter, c.Agent.Tracing.File = "jsonl", "" }},
+		{"agent.jobs.workers", func(c *Config) { c.Agent.Jobs.Workers = 0 }},
+		{"agent.jobs.queue_size", func(c *Config) { c.Agent.Jobs.QueueSize = -1 }},
+		{"agent.jobs.timeout", func(c *Config) { c.Agent.Jobs.Timeout = 0 }},
+		{"agent.jobs.retention", func(c *Config) { c.Agent.Jobs.Retention = 0 }},
+		{"agent.approval.tools[0]", func(c *Config) { c.Agent.Approval.Tools = []string{"["} }},
+		{"agent.approval.store", func(c *Config) { c.Agent.Approval.Store = "redis" }},
+		{"agent.approval.dir", func(c *Config) { c.Agent.Approval.Store, c.Agent.Approval.Dir = "file", "" }},
+		{"agent.router.mode", func(c *Config) { c.Agent.Router.Mode = "random" }},
+		{"agent.router.routes", func(c *Config) { c.Agent.Router.Routes = nil }},
+		{"agent.router.routes[1].agent", func(c *Config) { c.Agent.Router.Routes[1].Agent = "" }},
+		{"agent.router.default", func(c *Config) { c.Agent.Router.Default = "nope" }},
+		{"agent.plan.max_steps", func(c *Config) { c.Agent.Plan.MaxSteps = 0 }},
+		{"agent.plan.max_replans", func(c *Config) { c.Agent.Plan.MaxReplans = -1 }},
+		{"mcp.addr", func(c *Config) { c.MCP.Addr = "" }},
+		{"mcp.transport", func(c *Config) { c.MCP.Transport = "ws" }},
+		{"mcp.runninghub.base_url", func(c *Config) { c.MCP.RunningHub.BaseURL = "" }},
+		{"mcp.runninghub.novel_to_script_workflow_id", func(c *Config) { c.MCP.RunningHub.NovelToScriptWorkflowID = "" }},
+		{"mcp.runninghub.request_timeout", func(c *Config) { c.MCP.RunningHub.RequestTimeout = 0 }},
+		{"mcp.runninghub.poll_interval", func(c *Config) { c.MCP.RunningHub.PollInterval = 0 }},
+		{"mcp.runninghub.run_timeout", func(c *Config) { c.MCP.RunningHub.RunTimeout = c.MCP.RunningHub.PollInterval }},
+		{"mcp.openmeteo.geocode_url", func(c *Config) { c.MCP.OpenMeteo.GeocodeURL = "geocoding-api.open-meteo.com" }},
+		{"mcp.openmeteo.forecast_url", func(c *Config) { c.MCP.OpenMeteo.ForecastURL = "" }},
+		{"mcp.openmeteo.air_quality_url", func(c *Config) { c.MCP.OpenMeteo.AirQualityURL = "" }},
+		{"mcp.openmeteo.archive_url", func(c *Config) { c.MCP.OpenMeteo.ArchiveURL = "" }},
+		{"mcp.openmeteo.request_timeout", func(c *Config) { c.MCP.OpenMeteo.RequestTimeout = 0 }},
+		{"mcp.weather.providers", func(c *Config) { c.MCP.Weather.Providers = nil }},
+		{"mcp.weather.providers[1]", func(c *Config) { c.MCP.Weather.Providers = []string{"wttr", "accuweather"} }},
+		{"mcp.weather.providers[1]", func(c *Config) { c.MCP.Weather.Providers = []string{"wttr", "wttr"} }},
+		{"mcp.weather.provider_timeout", func(c *Config) { c.MCP.Weather.ProviderTimeout = 0 }},
+		{"mcp.weather.wttr.base_url", func(c *Config) { c.MCP.Weather.Wttr.BaseURL = "" }},
+		{"mcp.weather.wttr.request_timeout", func(c *Config) { c.MCP.Weather.Wttr.RequestTimeout = 0 }},
+		{"frontend.addr", func(c *Config) { c.Frontend.Addr = "" }},
+		{"frontend.dir", func(c *Config) { c.Frontend.Dir = "" }},
+	} {
+		cfg := Default()
+		tc.mutate(cfg)
+		err := cfg.Validate()
+		if err == nil {
+			t.Errorf("%s: Validate succeeded", tc.field)
+			continue
+		}
+		// 每个问题一行，以字段名开头
+		if !strings.Contains(err.Error(), "\n"+tc.field+": ") {
+			t.Errorf("%s: error = %v", tc.field, err)
+		}
+	}
+
+	// stdio 传输不监听端口，不需要 mcp.addr
+	cfg := Default()
+	cfg.MCP.Transport, cfg.MCP.Addr = "stdio", ""
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("stdio transport without addr: %v", err)
+	}
+}
+
+func TestValidateReportsAllErrors(t *testing.T) {
+	cfg := Default()
+	cfg.Agent.Addr = ""
+	cfg.Agent.Jobs.Workers = 0
+	cfg.Frontend.Dir = ""
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("Validate succeeded")
+	}
+	lines := strings.Split(err.Error(), "\n")
+	if len(lines) != 4 || lines[0] != "配置校验失败:" {
+		t.Errorf("error = %q, want header and one line per field", err)
+	}
+}
+
+func TestLoadEnvOverrides(t *testing.T) {
+	path := writeConfig(t, `
+agent:
+  addr: ":9000"
+  max_iterations: 4
+mcp:
+  weather:
+    providers: ["open-meteo"]
+    wttr:
+      request_timeout: 3s
+`)
+	// 环境变量优先于配置文件，配置文件优先于默认值
+	t.Setenv("AGENT_ADDR", ":9100")
+	t.Setenv("WEATHER_PROVIDERS", " wttr, ,open-meteo ")
+	t.Setenv("WTTR_REQUEST_TIMEOUT", "7s")
+	t.Setenv("OPENMETEO_REQUEST_TIMEOUT", "4s")
+	t.Setenv("MODEL_TEMPERATURE", "0.3")
+	t.Setenv("MCP_WEATHER_ENDPOINT", "stdio:mcp_server")
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Agent.Addr != ":9100" {
+		t.Errorf("agent.addr = %q, want env value :9100", cfg.Agent.Addr)
+	}
+	if cfg.Agent.MaxIterations != 4 {
+		t.Errorf("agent.max_iterations = %d, want file value 4", cfg.Agent.MaxIterations)
+	}
+	if cfg.Agent.Jobs.Workers != 2 {
+		t.Errorf("agent.jobs.workers = %d, want default 2", cfg.Agent.Jobs.Workers)
+	}
+	if got := strings.Join(cfg.MCP.Weather.Providers, ","); got != "wttr,open-meteo" {
+		t.Errorf("mcp.weather.providers = %q, want wttr,open-meteo", got)
+	}
+	if cfg.MCP.Weather.Wttr.RequestTimeout != 7*time.Second {
+		t.Errorf("mcp.weather.wttr.request_timeout = %v, want 7s", cfg.MCP.Weather.Wttr.RequestTimeout)
+	}
+	if cfg.MCP.OpenMeteo.RequestTimeout != 4*time.Second {
+		t.Errorf("mcp.openmeteo.request_timeout = %v, want 4s", cfg.MCP.OpenMeteo.RequestTimeout)
+	}
+	if tp := cfg.Agent.Model.Temperature; tp == nil || *tp != 0.3 {
+		t.Errorf("agent.model.temperature = %v, want 0.3", tp)
+	}
+	if len(cfg.Agent.MCPServers) != 1 || cfg.Agent.MCPServers[0].Endpoint != "stdio:mcp_server" {
+		t.Errorf("mcp_servers = %+v, want default server from MCP_WEATHER_ENDPOINT", cfg.Agent.MCPServers)
+	}
+}
+
+func TestLoadEnvErrors(t *testing.T) {
+	t.Setenv("AGENT_MAX_ITERATIONS", "eight")
+	t.Setenv("MODEL_TEMPERATURE", "warm")
+	t.Setenv("WTTR_REQUEST_TIMEOUT", "10")
+	_, err := Load("")
+	if err == nil {
+		t.Fatal("Load succeeded with malformed env vars")
+	}
+	for _, name := range []string{"AGENT_MAX_ITERATIONS", "MODEL_TEMPERATURE", "WTTR_REQUEST_TIMEOUT"} {
+		if !strings.Contains(err.Error(), name) {
+			t.Errorf("error = %v, want %s", err, name)
+		}
+	}
+}
+
+// 环境变量覆盖后的值同样要通过校验
+func TestLoadValidatesEnvValues(t *testing.T) {
+	t.Setenv("SESSION_STORE", "redis")
+	_, err := Load("")
+	if err == nil || !strings.Contains(err.Error(), "agent.session.store") {
+		t.Errorf("error = %v, want agent.session.store", err)
+	}
+}
@@ -0,0 +1,138 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// applyEnv 用环境变量覆盖配置；保留各服务原先使用的变量名（MCP_WEATHER_ENDPOINT、RUNNINGHUB_API_KEY 等）
+func applyEnv(c *Config) error {
+	e := &envReader{}
+
+	e.str(&c.Agent.Addr, "AGENT_ADDR")
+	e.str(&c.Agent.CORSOrigin, "AGENT_CORS_ORIGIN")
+	e.str(&c.Agent.MCPEndpoint, "MCP_WEATHER_ENDPOINT")
+	e.int(&c.Agent.MaxIterations, "AGENT_MAX_ITERATIONS")
+	e.str(&c.Agent.Model.Provider, "MODEL_PROVIDER")
+	e.str(&c.Agent.Model.BaseURL, "MODEL_BASE_URL")
+	e.str(&c.Agent.Model.Model, "MODEL_NAME")
+	e.str(&c.Agent.Model.APIKey, "MODEL_API_KEY")
+	e.float32Ptr(&c.Agent.Model.Temperature, "MODEL_TEMPERATURE")
+	e.duration(&c.Agent.Model.Timeout, "MODEL_TIMEOUT")
+	e.str(&c.Agent.Model.Record, "MODEL_RECORD")
+	e.str(&c.Agent.Model.Replay, "MODEL_REPLAY")
+	e.str(&c.Agent.Session.Store, "SESSION_STORE")
+	e.str(&c.Agent.Session.Dir, "SESSION_DIR")
+	e.int(&c.Agent.Session.MaxMessages, "SESSION_MAX_MESSAGES")
+	e.int(&c.Agent.Session.MaxTokens, "SESSION_MAX_TOKENS")
+	e.str(&c.Agent.Prompts.Dir, "PROMPT_DIR")
+	e.str(&c.Agent.Prompts.Locale, "PROMPT_LOCALE")
+	e.str(&c.Agent.Tracing.Exporter, "TRACE_EXPORTER")
+	e.str(&c.Agent.Tracing.File, "TRACE_FILE")
+	e.int(&c.Agent.Jobs.Workers, "JOB_WORKERS")
+	e.int(&c.Agent.Jobs.QueueSize, "JOB_QUEUE_SIZE")
+	e.duration(&c.Agent.Jobs.Timeout, "JOB_TIMEOUT")
+	e.duration(&c.Agent.Jobs.Retention, "JOB_RETENTION")
+	e.str(&c.Agent.Approval.Store, "APPROVAL_STORE")
+	e.str(&c.Agent.Approval.Dir, "APPROVAL_DIR")
+	e.str(&c.Agent.Router.Mode, "ROUTER_MODE")
+	e.str(&c.Agent.Router.Default, "ROUTER_DEFAULT")
+	e.int(&c.Agent.Plan.MaxSteps, "PLAN_MAX_STEPS")
+	e.int(&c.Agent.Plan.MaxReplans, "PLAN_MAX_REPLANS")
+
+	e.str(&c.MCP.Addr, "MCP_ADDR")
+	e.str(&c.MCP.Transport, "MCP_TRANSPORT")
+	e.str(&c.MCP.RunningHub.BaseURL, "RUNNINGHUB_BASE_URL")
+	e.str(&c.MCP.RunningHub.APIKey, "RUNNINGHUB_API_KEY")
+	e.str(&c.MCP.RunningHub.NovelToScriptWorkflowID, "RUNNINGHUB_NOVEL_TO_SCRIPT_WORKFLOW_ID")
+	e.duration(&c.MCP.RunningHub.RequestTimeout, "RUNNINGHUB_REQUEST_TIMEOUT")
+	e.duration(&c.MCP.RunningHub.PollInterval, "RUNNINGHUB_POLL_INTERVAL")
+	e.duration(&c.MCP.RunningHub.RunTimeout, "RUNNINGHUB_RUN_TIMEOUT")
+	e.str(&c.MCP.OpenMeteo.GeocodeURL, "OPENMETEO_GEOCODE_URL")
+	e.str(&c.MCP.OpenMeteo.ForecastURL, "OPENMETEO_FORECAST_URL")
+	e.str(&c.MCP.OpenMeteo.AirQualityURL, "OPENMETEO_AIR_QUALITY_URL")
+	e.str(&c.MCP.OpenMeteo.ArchiveURL, "OPENMETEO_ARCHIVE_URL")
+	e.duration(&c.MCP.OpenMeteo.RequestTimeout, "OPENMETEO_REQUEST_TIMEOUT")
+	e.list(&c.MCP.Weather.Providers, "WEATHER_PROVIDERS")
+	e.duration(&c.MCP.Weather.ProviderTimeout, "WEATHER_PROVIDER_TIMEOUT")
+	e.str(&c.MCP.Weather.Wttr.BaseURL, "WTTR_BASE_URL")
+	e.duration(&c.MCP.Weather.Wttr.RequestTimeout, "WTTR_REQUEST_TIMEOUT")
+
+	e.str(&c.Frontend.Addr, "FRONTEND_ADDR")
+	e.str(&c.Frontend.Dir, "FRONTEND_DIR")
+
+	if len(e.errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("环境变量格式错误:\n%w", errors.Join(e.errs...))
+}
+
+// envReader 读取环境变量并收集格式错误，未设置的变量不覆盖原值
+type envReader struct {
+	errs []error
+}
+
+func (e *envReader) str(dst *string, name string) {
+	if v := os.Getenv(name); v != "" {
+		*dst = v
+	}
+}
+
+// list 逗号分隔的列表，忽略空项
+func (e *envReader) list(dst *[]string, name string) {
+	v := os.Getenv(name)
+	if v == "" {
+		return
+	}
+	var out []string
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	*dst = out
+}
+
+func (e *envReader) int(dst *int, name string) {
+	v := os.Getenv(name)
+	if v == "" {
+		return
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		e.errs = append(e.errs, fmt.Errorf("%s=%q: 需要整数", name, v))
+		return
+	}
+	*dst = n
+}
+
+func (e *envReader) float32Ptr(dst **float32, name string) {
+	v := os.Getenv(name)
+	if v == "" {
+		return
+	}
+	f, err := strconv.ParseFloat(v, 32)
+	if err != nil {
+		e.errs = append(e.errs, fmt.Errorf("%s=%q: 需要数字", name, v))
+		return
+	}
+	f32 := float32(f)
+	*dst = &f32
+}
+
+func (e *envReader) duration(dst *time.Duration, name string) {
+	v := os.Getenv(name)
+	if v == "" {
+		return
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		e.errs = append(e.errs, fmt.Errorf("%s=%q: 需要时长，例如 30s、2m", name, v))
+		return
+	}
+	*dst = d
+}
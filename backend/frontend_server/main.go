@@ -1,25 +1,35 @@
 package main
 
 import (
+	"flag"
 	"log"
 	"net/http"
+
+	"github.com/aistudiolabx/eino-demo/backend/config"
 )
 
 func main() {
+	configPath := flag.String("config", "", "配置文件路径（YAML/JSON），不指定则使用默认配置与环境变量")
+	flag.Parse()
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatalf("load config error: %v", err)
+	}
+
 	mux := http.NewServeMux()
 
-	// 静态文件目录：项目根下的 frontend
+	// 静态文件目录：默认为项目根下的 frontend（frontend.dir）
 	// 建议在项目根目录执行：go run ./backend/frontend_server
-	fs := http.FileServer(http.Dir("frontend"))
+	fs := http.FileServer(http.Dir(cfg.Frontend.Dir))
 
 	// 使用根路由提供前端：访问 http://localhost:8081 即返回 index.html
 	mux.Handle("/", fs)
 
-	addr := ":8081"
+	addr := cfg.Frontend.Addr
 	log.Printf("Frontend server listening on %s\n", addr)
 
 	if err := http.ListenAndServe(addr, mux); err != nil {
 		log.Fatalf("frontend server error: %v", err)
 	}
 }
-
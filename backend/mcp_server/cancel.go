@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// errToolCallCancelled 客户端通过 notifications/cancelled 取消了调用
+var errToolCallCancelled = errors.New("tool call cancelled by client")
+
+// requestIDMetaKey recordRequestID 把 tools/call 的 JSON-RPC 请求 ID 写入 _meta 的这个字段：
+// 工具 handler 与中间件拿不到请求 ID，只有 BeforeCallTool 钩子能看到
+const requestIDMetaKey = "eino_demo/requestId"
+
+// toolCallRegistry 进行中的工具调用，按 "会话 ID/请求 ID" 索引；请求 ID 只在同一会话内唯一
+type toolCallRegistry struct {
+	mu      sync.Mutex
+	cancels map[string]context.CancelCauseFunc
+}
+
+var inflightToolCalls = &toolCallRegistry{cancels: make(map[string]context.CancelCauseFunc)}
+
+// toolCallKey 注册表的键；JSON 解码后请求 ID 为字符串或 float64，统一格式化为字符串
+func toolCallKey(ctx context.Context, requestID any) string {
+	sessionID := ""
+	if session := server.ClientSessionFromContext(ctx); session != nil {
+		sessionID = session.SessionID()
+	}
+	return sessionID + "/" + fmt.Sprint(requestID)
+}
+
+// recordRequestID BeforeCallTool 钩子：把请求 ID 交给 cancellableToolMiddleware
+func recordRequestID(_ context.Context, id any, req *mcp.CallToolRequest) {
+	if id == nil {
+		return
+	}
+	if req.Params.Meta == nil {
+		req.Params.Meta = &mcp.Meta{}
+	}
+	if req.Params.Meta.AdditionalFields == nil {
+		req.Params.Meta.AdditionalFields = make(map[string]any)
+	}
+	req.Params.Meta.AdditionalFields[requestIDMetaKey] = id
+}
+
+// cancellableToolMiddleware 让工具调用可被客户端取消：handler 的 ctx 在收到对应请求 ID 的取消通知时结束
+func cancellableToolMiddleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if req.Params.Meta == nil || req.Params.Meta.AdditionalFields[requestIDMetaKey] == nil {
+			return next(ctx, req)
+		}
+		key := toolCallKey(ctx, req.Params.Meta.AdditionalFields[requestIDMetaKey])
+		ctx, cancel := context.WithCancelCause(ctx)
+		inflightToolCalls.mu.Lock()
+		inflightToolCalls.cancels[key] = cancel
+		inflightToolCalls.mu.Unlock()
+		defer func() {
+			inflightToolCalls.mu.Lock()
+			delete(inflightToolCalls.cancels, key)
+			inflightToolCalls.mu.Unlock()
+			cancel(nil)
+		}()
+		return next(ctx, req)
+	}
+}
+
+// handleCancelled 处理 notifications/cancelled；请求已结束或 ID 未知时忽略
+func handleCancelled(ctx context.Context, n mcp.JSONRPCNotification) {
+	requestID := n.Params.AdditionalFields["requestId"]
+	if requestID == nil {
+		return
+	}
+	key := toolCallKey(ctx, requestID)
+	inflightToolCalls.mu.Lock()
+	cancel, ok := inflightToolCalls.cancels[key]
+	inflightToolCalls.mu.Unlock()
+	if !ok {
+		return
+	}
+	reason, _ := n.Params.AdditionalFields["reason"].(string)
+	log.Printf("cancel tool call %s: %s", key, reason)
+	cancel(errToolCallCancelled)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aistudiolabx/eino-demo/backend/mcpext"
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/client/transport"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// SSE 传输下 handler 的 ctx 与客户端请求无关，只有 notifications/cancelled 能结束进行中的调用
+func TestCancelledNotificationCancelsToolCall(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	started := make(chan struct{})
+	cause := make(chan error, 1)
+	hooks := &server.Hooks{}
+	hooks.AddBeforeCallTool(recordRequestID)
+	s := server.NewMCPServer("test", "test",
+		server.WithToolCapabilities(true),
+		server.WithHooks(hooks),
+		server.WithToolHandlerMiddleware(cancellableToolMiddleware),
+	)
+	s.AddNotificationHandler(mcpext.CancelledNotification, handleCancelled)
+	s.AddTool(mcp.NewTool("block"), func(ctx context.Context, _ mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		close(started)
+		<-ctx.Done()
+		cause <- context.Cause(ctx)
+		return mcp.NewToolResultError("cancelled"), nil
+	})
+	srv := server.NewTestServer(s)
+	defer srv.Close()
+
+	cli, err := client.NewSSEMCPClient(srv.URL + "/sse")
+	if err != nil {
+		t.Fatalf("NewSSEMCPClient: %v", err)
+	}
+	defer cli.Close()
+	if err := cli.Start(ctx); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	initReq := mcp.InitializeRequest{}
+	initReq.Params.ProtocolVersion = mcp.LATEST_PROTOCOL_VERSION
+	if _, err := cli.Initialize(ctx, initReq); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+
+	id := mcp.NewRequestId("call-1")
+	go func() {
+		params := mcp.CallToolParams{Name: "block"}
+		_, _ = cli.GetTransport().SendRequest(ctx, transport.JSONRPCRequest{
+			JSONRPC: mcp.JSONRPC_VERSION, ID: id, Method: string(mcp.MethodToolsCall), Params: params,
+		})
+	}()
+	select {
+	case <-started:
+	case <-ctx.Done():
+		t.Fatal("tool not started")
+	}
+
+	// 其他请求 ID 的取消不影响这次调用
+	for _, other := range []mcp.RequestId{mcp.NewRequestId("call-2"), id} {
+		err := cli.GetTransport().SendNotification(ctx, mcp.JSONRPCNotification{
+			JSONRPC: mcp.JSONRPC_VERSION,
+			Notification: mcp.Notification{
+				Method: mcpext.CancelledNotification,
+				Params: mcp.NotificationParams{AdditionalFields: mcpext.CancelledParams(other, "test")},
+			},
+		})
+		if err != nil {
+			t.Fatalf("send cancelled: %v", err)
+		}
+		if other != id {
+			select {
+			case err := <-cause:
+				t.Fatalf("call cancelled by notification for another request: %v", err)
+			case <-time.After(50 * time.Millisecond):
+			}
+		}
+	}
+
+	select {
+	case err := <-cause:
+		if !errors.Is(err, errToolCallCancelled) {
+			t.Errorf("handler ctx cause = %v, want errToolCallCancelled", err)
+		}
+	case <-ctx.Done():
+		t.Fatal("tool call not cancelled")
+	}
+}
@@ -1,14 +1,33 @@
 package client
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
 	"time"
+
+	"github.com/aistudiolabx/eino-demo/backend/metrics"
 )
 
 const openMeteoGeocodeURL = "https://geocoding-api.open-meteo.com/v1/search"
 const openMeteoForecastURL = "https://api.open-meteo.com/v1/forecast"
+const openMeteoAirQualityURL = "https://air-quality-api.open-meteo.com/v1/air-quality"
+const openMeteoArchiveURL = "https://archive-api.open-meteo.com/v1/archive"
+
+// openMeteoDuration Open-Meteo 各接口的调用耗时，status 为 ok 或 error
+var openMeteoDuration = metrics.NewHistogram("openmeteo_request_duration_seconds",
+	"Open-Meteo API 调用耗时（秒）", nil, "api", "status")
+
+// observeOpenMeteo 记录一次调用的耗时与结果，配合 defer 使用
+func observeOpenMeteo(api string, start time.Time, err *error) {
+	status := "ok"
+	if *err != nil {
+		status = "error"
+	}
+	openMeteoDuration.ObserveSince(start, api, status)
+}
 
 // GeocodeResult 地理编码单条结果
 type GeocodeResult struct {
@@ -16,6 +35,13 @@ type GeocodeResult struct {
 	Latitude  float64 `json:"latitude"`
 	Longitude float64 `json:"longitude"`
 	Country   string  `json:"country"`
+	// CountryCode ISO 3166-1 alpha-2 国家代码，如 CN、US
+	CountryCode string `json:"country_code,omitempty"`
+	// Admin1 一级行政区（省、州），Admin2 二级行政区（市、县）
+	Admin1     string `json:"admin1,omitempty"`
+	Admin2     string `json:"admin2,omitempty"`
+	Timezone   string `json:"timezone,omitempty"`
+	Population int    `json:"population,omitempty"`
 }
 
 // GeocodeResponse 地理编码 API 响应
@@ -37,52 +63,189 @@ type WeatherResponse struct {
 // OpenMeteoClient Open-Meteo API 客户端
 type OpenMeteoClient struct {
 	HTTPClient *http.Client
+	// GeocodeURL 地理编码接口地址
+	GeocodeURL string
+	// ForecastURL 天气预报接口地址
+	ForecastURL string
+	// AirQualityURL 空气质量接口地址
+	AirQualityURL string
+	// ArchiveURL 历史天气接口地址
+	ArchiveURL string
 }
 
 // NewOpenMeteoClient 创建 Open-Meteo 客户端
 func NewOpenMeteoClient() *OpenMeteoClient {
 	return &OpenMeteoClient{
-		HTTPClient: &http.Client{Timeout: 10 * time.Second},
+		HTTPClient:    &http.Client{Timeout: 10 * time.Second},
+		GeocodeURL:    openMeteoGeocodeURL,
+		ForecastURL:   openMeteoForecastURL,
+		AirQualityURL: openMeteoAirQualityURL,
+		ArchiveURL:    openMeteoArchiveURL,
 	}
 }
 
-// Geocode 根据城市名查询经纬度
-func (c *OpenMeteoClient) Geocode(city string) (*GeocodeResponse, error) {
-	url := fmt.Sprintf("%s?name=%s&count=1&language=zh&format=json", openMeteoGeocodeURL, city)
-	resp, err := c.HTTPClient.Get(url)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("geocoding API status: %s", resp.Status)
-	}
+// Geocode 根据地名查询候选地点，最多 count 个，按 Open-Meteo 的相关度排序
+func (c *OpenMeteoClient) Geocode(ctx context.Context, name string, count int) (_ *GeocodeResponse, err error) {
+	defer observeOpenMeteo("geocode", time.Now(), &err)
+	u := fmt.Sprintf("%s?name=%s&count=%d&language=zh&format=json", c.GeocodeURL, url.QueryEscape(name), count)
 	var out GeocodeResponse
-	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+	if err := c.getJSON(ctx, u, "geocoding API", &out); err != nil {
 		return nil, err
 	}
 	return &out, nil
 }
 
 // GetWeather 根据经纬度查询当前天气
-func (c *OpenMeteoClient) GetWeather(lat, lon float64) (*WeatherResponse, error) {
+func (c *OpenMeteoClient) GetWeather(ctx context.Context, lat, lon float64) (_ *WeatherResponse, err error) {
+	defer observeOpenMeteo("forecast", time.Now(), &err)
 	url := fmt.Sprintf("%s?latitude=%f&longitude=%f&current=temperature_2m,weather_code",
-		openMeteoForecastURL, lat, lon)
-	resp, err := c.HTTPClient.Get(url)
-	if err != nil {
+		c.ForecastURL, lat, lon)
+	var out WeatherResponse
+	if err := c.getJSON(ctx, url, "weather API", &out); err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("weather API status: %s", resp.Status)
+	return &out, nil
+}
+
+// dailyForecastVars / hourlyForecastVars 预报请求的 Open-Meteo 变量，与 DailyForecast / HourlyForecast 的字段对应
+const (
+	dailyForecastVars  = "weather_code,temperature_2m_max,temperature_2m_min,precipitation_probability_max,precipitation_sum,wind_speed_10m_max,uv_index_max"
+	hourlyForecastVars = "temperature_2m,weather_code,precipitation_probability,wind_speed_10m,uv_index"
+)
+
+// DailyForecast 逐日预报，各字段按下标与 Time（YYYY-MM-DD，当地日期）对应
+type DailyForecast struct {
+	Time                        []string  `json:"time"`
+	WeatherCode                 []int     `json:"weather_code"`
+	Temperature2mMax            []float64 `json:"temperature_2m_max"`
+	Temperature2mMin            []float64 `json:"temperature_2m_min"`
+	PrecipitationProbabilityMax []float64 `json:"precipitation_probability_max"`
+	PrecipitationSum            []float64 `json:"precipitation_sum"`
+	WindSpeed10mMax             []float64 `json:"wind_speed_10m_max"`
+	UVIndexMax                  []float64 `json:"uv_index_max"`
+}
+
+// HourlyForecast 逐小时预报，各字段按下标与 Time（YYYY-MM-DDTHH:MM，当地时间）对应
+type HourlyForecast struct {
+	Time                     []string  `json:"time"`
+	Temperature2m            []float64 `json:"temperature_2m"`
+	WeatherCode              []int     `json:"weather_code"`
+	PrecipitationProbability []float64 `json:"precipitation_probability"`
+	WindSpeed10m             []float64 `json:"wind_speed_10m"`
+	UVIndex                  []float64 `json:"uv_index"`
+}
+
+// ForecastResponse 预报 API 响应，只包含请求了的 daily / hourly 部分
+type ForecastResponse struct {
+	Timezone string          `json:"timezone"`
+	Daily    *DailyForecast  `json:"daily,omitempty"`
+	Hourly   *HourlyForecast `json:"hourly,omitempty"`
+}
+
+// GetDailyForecast 查询从今天起 days 天的逐日预报（Open-Meteo 最多 16 天），日期按当地时区
+func (c *OpenMeteoClient) GetDailyForecast(ctx context.Context, lat, lon float64, days int) (*ForecastResponse, error) {
+	return c.getForecast(ctx, "forecast_daily", fmt.Sprintf("daily=%s&forecast_days=%d", dailyForecastVars, days), lat, lon)
+}
+
+// GetHourlyForecast 查询从当前整点起 hours 小时的逐小时预报，时间按当地时区
+func (c *OpenMeteoClient) GetHourlyForecast(ctx context.Context, lat, lon float64, hours int) (*ForecastResponse, error) {
+	return c.getForecast(ctx, "forecast_hourly", fmt.Sprintf("hourly=%s&forecast_hours=%d", hourlyForecastVars, hours), lat, lon)
+}
+
+// getForecast 以 query 指定的变量调用预报接口，api 为耗时指标的标签
+func (c *OpenMeteoClient) getForecast(ctx context.Context, api, query string, lat, lon float64) (_ *ForecastResponse, err error) {
+	defer observeOpenMeteo(api, time.Now(), &err)
+	url := fmt.Sprintf("%s?latitude=%f&longitude=%f&timezone=auto&%s", c.ForecastURL, lat, lon, query)
+	var out ForecastResponse
+	if err := c.getJSON(ctx, url, "forecast API", &out); err != nil {
+		return nil, err
 	}
-	var out WeatherResponse
-	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+	return &out, nil
+}
+
+// airQualityVars 空气质量请求的 Open-Meteo 变量，与 AirQualityCurrent 的字段对应
+const airQualityVars = "pm2_5,pm10,ozone,nitrogen_dioxide,european_aqi,us_aqi"
+
+// AirQualityCurrent 当前空气质量，污染物浓度单位为 μg/m³；
+// 部分地区缺少某些数据时 Open-Meteo 返回 null，对应字段为 nil
+type AirQualityCurrent struct {
+	Time            string   `json:"time"`
+	PM2_5           *float64 `json:"pm2_5"`
+	PM10            *float64 `json:"pm10"`
+	Ozone           *float64 `json:"ozone"`
+	NitrogenDioxide *float64 `json:"nitrogen_dioxide"`
+	EuropeanAQI     *float64 `json:"european_aqi"`
+	USAQI           *float64 `json:"us_aqi"`
+}
+
+// AirQualityResponse 空气质量 API 响应
+type AirQualityResponse struct {
+	Timezone string            `json:"timezone"`
+	Current  AirQualityCurrent `json:"current"`
+}
+
+// GetAirQuality 根据经纬度查询当前空气质量，时间按当地时区
+func (c *OpenMeteoClient) GetAirQuality(ctx context.Context, lat, lon float64) (_ *AirQualityResponse, err error) {
+	defer observeOpenMeteo("air_quality", time.Now(), &err)
+	url := fmt.Sprintf("%s?latitude=%f&longitude=%f&timezone=auto&current=%s", c.AirQualityURL, lat, lon, airQualityVars)
+	var out AirQualityResponse
+	if err := c.getJSON(ctx, url, "air quality API", &out); err != nil {
 		return nil, err
 	}
 	return &out, nil
 }
 
+// dailyHistoryVars 历史天气请求的 Open-Meteo 变量，与 DailyHistory 的字段对应
+const dailyHistoryVars = "weather_code,temperature_2m_max,temperature_2m_min,temperature_2m_mean,precipitation_sum,precipitation_hours,wind_speed_10m_max"
+
+// DailyHistory 逐日历史天气，各字段按下标与 Time（YYYY-MM-DD，当地日期）对应；
+// 归档数据有几天的滞后，尚未入库的日期对应的值为 nil
+type DailyHistory struct {
+	Time               []string   `json:"time"`
+	WeatherCode        []*int     `json:"weather_code"`
+	Temperature2mMax   []*float64 `json:"temperature_2m_max"`
+	Temperature2mMin   []*float64 `json:"temperature_2m_min"`
+	Temperature2mMean  []*float64 `json:"temperature_2m_mean"`
+	PrecipitationSum   []*float64 `json:"precipitation_sum"`
+	PrecipitationHours []*float64 `json:"precipitation_hours"`
+	WindSpeed10mMax    []*float64 `json:"wind_speed_10m_max"`
+}
+
+// HistoryResponse 历史天气 API 响应
+type HistoryResponse struct {
+	Timezone string       `json:"timezone"`
+	Daily    DailyHistory `json:"daily"`
+}
+
+// GetDailyHistory 查询 start~end（YYYY-MM-DD，含两端，当地日期）的逐日历史天气
+func (c *OpenMeteoClient) GetDailyHistory(ctx context.Context, lat, lon float64, start, end string) (_ *HistoryResponse, err error) {
+	defer observeOpenMeteo("archive", time.Now(), &err)
+	url := fmt.Sprintf("%s?latitude=%f&longitude=%f&timezone=auto&start_date=%s&end_date=%s&daily=%s",
+		c.ArchiveURL, lat, lon, start, end, dailyHistoryVars)
+	var out HistoryResponse
+	if err := c.getJSON(ctx, url, "archive API", &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// getJSON 发起 GET 请求并解析 JSON 响应，api 用于非 200 时的错误信息
+func (c *OpenMeteoClient) getJSON(ctx context.Context, url, api string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s status: %s", api, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
 // WeatherCodeToDesc 天气代码转描述
 func WeatherCodeToDesc(code int) string {
 	switch code {
@@ -96,8 +259,14 @@ func WeatherCodeToDesc(code int) string {
 		return "小雨"
 	case 61, 63, 65:
 		return "降雨"
-	case 71, 73, 75:
+	case 66, 67:
+		return "冻雨"
+	case 71, 73, 75, 77:
 		return "降雪"
+	case 80, 81, 82:
+		return "阵雨"
+	case 85, 86:
+		return "阵雪"
 	case 95, 96, 99:
 		return "雷暴"
 	default:
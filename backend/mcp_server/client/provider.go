@@ -0,0 +1,115 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/aistudiolabx/eino-demo/backend/metrics"
+)
+
+// WeatherProvider 天气数据源：地理编码、当前天气与预报。
+// 返回值统一使用 Open-Meteo 的数据结构（天气代码为 WMO 代码），其他数据源在实现中转换
+type WeatherProvider interface {
+	// Name 数据源名称，用于日志与指标
+	Name() string
+	// Geocode 根据地名查询候选地点，最多 count 个
+	Geocode(ctx context.Context, name string, count int) (*GeocodeResponse, error)
+	// GetWeather 根据经纬度查询当前天气
+	GetWeather(ctx context.Context, lat, lon float64) (*WeatherResponse, error)
+	// GetDailyForecast 查询从今天起 days 天的逐日预报，数据源支持的天数不足时返回能提供的部分
+	GetDailyForecast(ctx context.Context, lat, lon float64, days int) (*ForecastResponse, error)
+	// GetHourlyForecast 查询从当前整点起 hours 小时的逐小时预报
+	GetHourlyForecast(ctx context.Context, lat, lon float64, hours int) (*ForecastResponse, error)
+}
+
+// Name 实现 WeatherProvider
+func (c *OpenMeteoClient) Name() string { return "open-meteo" }
+
+// providerFailovers 数据源调用失败、切换到下一个数据源的次数，provider 为失败的数据源
+var providerFailovers = metrics.NewCounter("weather_provider_failovers_total",
+	"天气数据源调用失败并切换到下一个数据源的次数", "provider", "api")
+
+// FailoverProvider 按顺序尝试多个数据源，出错或超时时换下一个，全部失败时返回各数据源的错误。
+// 调用方的 ctx 取消或超时时不再尝试后续数据源
+type FailoverProvider struct {
+	Providers []WeatherProvider
+	// Timeout 每个数据源单次调用的超时，<=0 时只受 ctx 与数据源自身的 HTTP 超时限制
+	Timeout time.Duration
+}
+
+// NewFailoverProvider 创建按 providers 顺序切换的数据源
+func NewFailoverProvider(timeout time.Duration, providers ...WeatherProvider) *FailoverProvider {
+	return &FailoverProvider{Providers: providers, Timeout: timeout}
+}
+
+// Name 实现 WeatherProvider，形如 failover(open-meteo,wttr)
+func (f *FailoverProvider) Name() string {
+	names := make([]string, len(f.Providers))
+	for i, p := range f.Providers {
+		names[i] = p.Name()
+	}
+	return "failover(" + strings.Join(names, ",") + ")"
+}
+
+// Geocode 实现 WeatherProvider
+func (f *FailoverProvider) Geocode(ctx context.Context, name string, count int) (*GeocodeResponse, error) {
+	return failover(ctx, f, "geocode", func(ctx context.Context, p WeatherProvider) (*GeocodeResponse, error) {
+		return p.Geocode(ctx, name, count)
+	})
+}
+
+// GetWeather 实现 WeatherProvider
+func (f *FailoverProvider) GetWeather(ctx context.Context, lat, lon float64) (*WeatherResponse, error) {
+	return failover(ctx, f, "current", func(ctx context.Context, p WeatherProvider) (*WeatherResponse, error) {
+		return p.GetWeather(ctx, lat, lon)
+	})
+}
+
+// GetDailyForecast 实现 WeatherProvider
+func (f *FailoverProvider) GetDailyForecast(ctx context.Context, lat, lon float64, days int) (*ForecastResponse, error) {
+	return failover(ctx, f, "forecast_daily", func(ctx context.Context, p WeatherProvider) (*ForecastResponse, error) {
+		return p.GetDailyForecast(ctx, lat, lon, days)
+	})
+}
+
+// GetHourlyForecast 实现 WeatherProvider
+func (f *FailoverProvider) GetHourlyForecast(ctx context.Context, lat, lon float64, hours int) (*ForecastResponse, error) {
+	return failover(ctx, f, "forecast_hourly", func(ctx context.Context, p WeatherProvider) (*ForecastResponse, error) {
+		return p.GetHourlyForecast(ctx, lat, lon, hours)
+	})
+}
+
+// failover 依次用各数据源执行 call，返回第一个成功的结果
+func failover[T any](ctx context.Context, f *FailoverProvider, api string, call func(context.Context, WeatherProvider) (T, error)) (T, error) {
+	var zero T
+	var errs []error
+	for _, p := range f.Providers {
+		out, err := callWithTimeout(ctx, f.Timeout, p, call)
+		if err == nil {
+			return out, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", p.Name(), err))
+		if ctx.Err() != nil {
+			break
+		}
+		providerFailovers.Inc(p.Name(), api)
+		log.Printf("weather provider %s %s failed: %v", p.Name(), api, err)
+	}
+	if len(errs) == 0 {
+		return zero, errors.New("没有可用的天气数据源")
+	}
+	return zero, errors.Join(errs...)
+}
+
+func callWithTimeout[T any](ctx context.Context, timeout time.Duration, p WeatherProvider, call func(context.Context, WeatherProvider) (T, error)) (T, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return call(ctx, p)
+}
@@ -0,0 +1,95 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeProvider 只实现 GetWeather 的测试数据源：delay 内未被取消则返回 err 或温度 temp
+type fakeProvider struct {
+	name  string
+	temp  float64
+	err   error
+	delay time.Duration
+	calls atomic.Int32
+}
+
+func (p *fakeProvider) Name() string { return p.name }
+
+func (p *fakeProvider) GetWeather(ctx context.Context, _, _ float64) (*WeatherResponse, error) {
+	p.calls.Add(1)
+	select {
+	case <-time.After(p.delay):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if p.err != nil {
+		return nil, p.err
+	}
+	return &WeatherResponse{Current: WeatherCurrent{Temperature2m: p.temp}}, nil
+}
+
+func (p *fakeProvider) Geocode(context.Context, string, int) (*GeocodeResponse, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (p *fakeProvider) GetDailyForecast(context.Context, float64, float64, int) (*ForecastResponse, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (p *fakeProvider) GetHourlyForecast(context.Context, float64, float64, int) (*ForecastResponse, error) {
+	return nil, errors.New("not implemented")
+}
+
+func TestFailoverProvider(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		primary *fakeProvider
+	}{
+		{name: "error", primary: &fakeProvider{name: "primary", err: errors.New("503")}},
+		{name: "timeout", primary: &fakeProvider{name: "primary", temp: 1, delay: 5 * time.Second}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			backup := &fakeProvider{name: "backup", temp: 21.5}
+			f := NewFailoverProvider(50*time.Millisecond, tc.primary, backup)
+			start := time.Now()
+			w, err := f.GetWeather(context.Background(), 39.9, 116.4)
+			if err != nil {
+				t.Fatalf("GetWeather: %v", err)
+			}
+			if w.Current.Temperature2m != 21.5 {
+				t.Errorf("temperature = %v, want 21.5 from backup", w.Current.Temperature2m)
+			}
+			if elapsed := time.Since(start); elapsed > 2*time.Second {
+				t.Errorf("failover took %v, per-provider timeout not honored", elapsed)
+			}
+		})
+	}
+
+	t.Run("all fail", func(t *testing.T) {
+		f := NewFailoverProvider(0, &fakeProvider{name: "a", err: errors.New("boom")}, &fakeProvider{name: "b", err: errors.New("bang")})
+		_, err := f.GetWeather(context.Background(), 0, 0)
+		if err == nil || !strings.Contains(err.Error(), "a: boom") || !strings.Contains(err.Error(), "b: bang") {
+			t.Errorf("error = %v, want errors of both providers", err)
+		}
+	})
+
+	t.Run("caller cancelled", func(t *testing.T) {
+		primary := &fakeProvider{name: "primary", delay: 5 * time.Second}
+		backup := &fakeProvider{name: "backup", temp: 21.5}
+		f := NewFailoverProvider(time.Minute, primary, backup)
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		_, err := f.GetWeather(ctx, 0, 0)
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("error = %v, want caller's deadline", err)
+		}
+		if n := backup.calls.Load(); n != 0 {
+			t.Errorf("backup called %d times after caller's ctx ended", n)
+		}
+	})
+}
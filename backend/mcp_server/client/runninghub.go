@@ -9,14 +9,24 @@ import (
 	"net/http"
 	"strings"
 	"time"
+
+	"github.com/aistudiolabx/eino-demo/backend/metrics"
 )
 
-const baseURL = "https://www.runninghub.ai"
+const defaultBaseURL = "https://www.runninghub.ai"
 
-// 轮询间隔与默认超时
-const pollInterval = 2 * time.Second
+// 默认轮询间隔与默认超时
+const defaultPollInterval = 2 * time.Second
 const defaultRunTimeout = 10 * time.Minute
 
+// RunWorkflow 的任务指标：status 为 success、failed 或 error（创建/查询失败、超时等）
+var (
+	runningHubTaskDuration = metrics.NewHistogram("runninghub_task_duration_seconds",
+		"RunningHub 工作流任务从创建到结束的耗时（秒）", nil, "status")
+	runningHubPolls = metrics.NewCounter("runninghub_task_polls_total",
+		"RunningHub 任务状态轮询次数")
+)
+
 // NodeInfo ComfyUI 节点参数
 type NodeInfo struct {
 	NodeID     string `json:"nodeId"`
@@ -40,27 +50,35 @@ type TaskRequest struct {
 // RunningHubClient RunningHub OpenAPI 客户端
 type RunningHubClient struct {
 	HTTPClient *http.Client
+	// BaseURL OpenAPI 地址
+	BaseURL string
+	// PollInterval RunWorkflow 查询任务状态的间隔
+	PollInterval time.Duration
+	// RunTimeout RunWorkflow 在 ctx 未设置截止时间时使用的总超时
+	RunTimeout time.Duration
 }
 
 // NewRunningHubClient 创建 RunningHub 客户端
 func NewRunningHubClient() *RunningHubClient {
 	return &RunningHubClient{
-		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+		HTTPClient:   &http.Client{Timeout: 30 * time.Second},
+		BaseURL:      defaultBaseURL,
+		PollInterval: defaultPollInterval,
+		RunTimeout:   defaultRunTimeout,
 	}
 }
 
-// Post 发送 POST JSON 请求，返回响应体和状态码
-func (c *RunningHubClient) Post(path string, body any) ([]byte, int, error) {
+// Post 发送 POST JSON 请求，返回响应体和状态码；ctx 取消时请求随之中断
+func (c *RunningHubClient) Post(ctx context.Context, path string, body any) ([]byte, int, error) {
 	payload, err := json.Marshal(body)
 	if err != nil {
 		return nil, 0, err
 	}
-	req, err := http.NewRequest(http.MethodPost, baseURL+path, bytes.NewReader(payload))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.BaseURL+path, bytes.NewReader(payload))
 	if err != nil {
 		return nil, 0, err
 	}
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Host", "www.runninghub.ai")
 	resp, err := c.HTTPClient.Do(req)
 	if err != nil {
 		return nil, 0, err
@@ -74,8 +92,8 @@ func (c *RunningHubClient) Post(path string, body any) ([]byte, int, error) {
 }
 
 // CreateTask 创建任务
-func (c *RunningHubClient) CreateTask(apiKey, workflowID string, nodeInfoList []NodeInfo) ([]byte, int, error) {
-	return c.Post("/task/openapi/create", CreateTaskRequest{
+func (c *RunningHubClient) CreateTask(ctx context.Context, apiKey, workflowID string, nodeInfoList []NodeInfo) ([]byte, int, error) {
+	return c.Post(ctx, "/task/openapi/create", CreateTaskRequest{
 		APIKey:       apiKey,
 		WorkflowID:   workflowID,
 		NodeInfoList: nodeInfoList,
@@ -83,13 +101,13 @@ func (c *RunningHubClient) CreateTask(apiKey, workflowID string, nodeInfoList []
 }
 
 // TaskStatus 查询任务状态
-func (c *RunningHubClient) TaskStatus(apiKey, taskID string) ([]byte, int, error) {
-	return c.Post("/task/openapi/status", TaskRequest{APIKey: apiKey, TaskID: taskID})
+func (c *RunningHubClient) TaskStatus(ctx context.Context, apiKey, taskID string) ([]byte, int, error) {
+	return c.Post(ctx, "/task/openapi/status", TaskRequest{APIKey: apiKey, TaskID: taskID})
 }
 
 // TaskOutputs 获取任务输出
-func (c *RunningHubClient) TaskOutputs(apiKey, taskID string) ([]byte, int, error) {
-	return c.Post("/task/openapi/outputs", TaskRequest{APIKey: apiKey, TaskID: taskID})
+func (c *RunningHubClient) TaskOutputs(ctx context.Context, apiKey, taskID string) ([]byte, int, error) {
+	return c.Post(ctx, "/task/openapi/outputs", TaskRequest{APIKey: apiKey, TaskID: taskID})
 }
 
 // TaskOutputsResponse 获取输出 API 响应
@@ -113,7 +131,7 @@ type TaskOutputsItem struct {
 }
 
 // FetchOutputTextContent 解析 outputs API 的 JSON 响应，下载 data 中 fileType 为 txt 的 fileUrl 内容并拼接返回
-func (c *RunningHubClient) FetchOutputTextContent(outputsJSON []byte) (string, error) {
+func (c *RunningHubClient) FetchOutputTextContent(ctx context.Context, outputsJSON []byte) (string, error) {
 	var resp TaskOutputsResponse
 	if err := json.Unmarshal(outputsJSON, &resp); err != nil {
 		return "", fmt.Errorf("解析输出响应失败: %w", err)
@@ -125,7 +143,6 @@ func (c *RunningHubClient) FetchOutputTextContent(outputsJSON []byte) (string, e
 		return "", fmt.Errorf("输出 API 无 data")
 	}
 	var sb strings.Builder
-	httpClient := &http.Client{Timeout: 30 * time.Second}
 	for i, item := range resp.Data {
 		if item.FileUrl == "" {
 			continue
@@ -134,11 +151,11 @@ func (c *RunningHubClient) FetchOutputTextContent(outputsJSON []byte) (string, e
 		if item.FileType != "" && item.FileType != "txt" {
 			continue
 		}
-		req, err := http.NewRequest(http.MethodGet, item.FileUrl, nil)
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, item.FileUrl, nil)
 		if err != nil {
 			return "", fmt.Errorf("创建下载请求失败: %w", err)
 		}
-		r, err := httpClient.Do(req)
+		r, err := c.HTTPClient.Do(req)
 		if err != nil {
 			return "", fmt.Errorf("下载 %s 失败: %w", item.FileUrl, err)
 		}
@@ -206,14 +223,25 @@ func parseTaskStatus(data json.RawMessage) string {
 
 // RunWorkflow 创建任务并轮询直至完成，最后返回输出结果；可被业务层复用。
 // 状态值参考 RunningHub：QUEUED、RUNNING、SUCCESS、FAILED。
-func (c *RunningHubClient) RunWorkflow(ctx context.Context, apiKey, workflowID string, nodeInfoList []NodeInfo) (outputs []byte, err error) {
+// onPoll 非 nil 时在每次查询状态后回调（第几次查询与当前状态），用于向调用方报告进度；
+// ctx 取消后在下一次轮询前返回，不再查询任务状态。
+func (c *RunningHubClient) RunWorkflow(ctx context.Context, apiKey, workflowID string, nodeInfoList []NodeInfo, onPoll func(polls int, status string)) (outputs []byte, err error) {
+	start := time.Now()
+	taskStatus := "error"
+	defer func() {
+		if err == nil {
+			taskStatus = "success"
+		}
+		runningHubTaskDuration.ObserveSince(start, taskStatus)
+	}()
+
 	if _, ok := ctx.Deadline(); !ok {
 		var cancel context.CancelFunc
-		ctx, cancel = context.WithTimeout(ctx, defaultRunTimeout)
+		ctx, cancel = context.WithTimeout(ctx, c.RunTimeout)
 		defer cancel()
 	}
 
-	raw, code, err := c.CreateTask(apiKey, workflowID, nodeInfoList)
+	raw, code, err := c.CreateTask(ctx, apiKey, workflowID, nodeInfoList)
 	if err != nil {
 		return nil, fmt.Errorf("创建任务失败: %w", err)
 	}
@@ -229,16 +257,17 @@ func (c *RunningHubClient) RunWorkflow(ctx context.Context, apiKey, workflowID s
 		return nil, fmt.Errorf("创建响应中缺少 taskId: %s", string(raw))
 	}
 
-	ticker := time.NewTicker(pollInterval)
+	ticker := time.NewTicker(c.PollInterval)
 	defer ticker.Stop()
-	for {
+	for polls := 1; ; polls++ {
 		select {
 		case <-ctx.Done():
-			return nil, ctx.Err()
+			return nil, fmt.Errorf("任务 %s 已停止等待: %w", taskID, context.Cause(ctx))
 		case <-ticker.C:
 		}
 
-		raw, code, err := c.TaskStatus(apiKey, taskID)
+		runningHubPolls.Inc()
+		raw, code, err := c.TaskStatus(ctx, apiKey, taskID)
 		if err != nil {
 			return nil, fmt.Errorf("查询状态失败: %w", err)
 		}
@@ -250,9 +279,12 @@ func (c *RunningHubClient) RunWorkflow(ctx context.Context, apiKey, workflowID s
 			return nil, fmt.Errorf("解析状态响应失败: %w", err)
 		}
 		status := parseTaskStatus(statusResp.Data)
+		if onPoll != nil {
+			onPoll(polls, status)
+		}
 		switch status {
 		case "SUCCESS":
-			out, code, err := c.TaskOutputs(apiKey, taskID)
+			out, code, err := c.TaskOutputs(ctx, apiKey, taskID)
 			if err != nil {
 				return nil, fmt.Errorf("获取输出失败: %w", err)
 			}
@@ -261,6 +293,7 @@ func (c *RunningHubClient) RunWorkflow(ctx context.Context, apiKey, workflowID s
 			}
 			return out, nil
 		case "FAILED":
+			taskStatus = "failed"
 			return nil, fmt.Errorf("任务执行失败: %s", string(raw))
 		case "QUEUED", "RUNNING", "":
 			continue
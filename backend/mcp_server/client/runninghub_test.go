@@ -0,0 +1,49 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// 请求进行中 ctx 被取消时，创建任务与下载输出都应立即返回，而不是等到 HTTPClient 超时
+func TestRunningHubRequestsHonorContext(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	c := NewRunningHubClient()
+	c.BaseURL = srv.URL
+
+	t.Run("create", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		_, err := c.RunWorkflow(ctx, "key", "wf", nil, nil)
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("RunWorkflow error = %v, want deadline exceeded", err)
+		}
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Errorf("RunWorkflow returned after %v", elapsed)
+		}
+	})
+
+	t.Run("download", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		outputs := []byte(`{"code": 0, "msg": "success", "data": [{"fileUrl": "` + srv.URL + `/out.txt", "fileType": "txt"}]}`)
+		_, err := c.FetchOutputTextContent(ctx, outputs)
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("FetchOutputTextContent error = %v, want deadline exceeded", err)
+		}
+	})
+}
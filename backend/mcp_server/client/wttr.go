@@ -0,0 +1,346 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/aistudiolabx/eino-demo/backend/metrics"
+)
+
+const wttrBaseURL = "https://wttr.in"
+
+// WttrClient wttr.in 天气数据源，作为 Open-Meteo 不可用时的备用：无需 API Key，
+// 但预报只有 3 天、逐小时预报为 3 小时一档，地理编码只返回一个地点；
+// 不提供时区名，时区由观测时间推算为 Etc/GMT±N，见 wttrResponse.timezone
+type WttrClient struct {
+	HTTPClient *http.Client
+	// BaseURL 服务地址，可指向自建实例或测试桩
+	BaseURL string
+}
+
+// NewWttrClient 创建 wttr.in 客户端
+func NewWttrClient() *WttrClient {
+	return &WttrClient{
+		HTTPClient: &http.Client{Timeout: 10 * time.Second},
+		BaseURL:    wttrBaseURL,
+	}
+}
+
+// Name 实现 WeatherProvider
+func (c *WttrClient) Name() string { return "wttr" }
+
+// wttrResponse wttr.in format=j1 响应中用到的部分，数值均为字符串
+type wttrResponse struct {
+	CurrentCondition []wttrCurrent `json:"current_condition"`
+	NearestArea      []struct {
+		AreaName   []wttrValue `json:"areaName"`
+		Country    []wttrValue `json:"country"`
+		Region     []wttrValue `json:"region"`
+		Latitude   string      `json:"latitude"`
+		Longitude  string      `json:"longitude"`
+		Population string      `json:"population"`
+	} `json:"nearest_area"`
+	Weather []struct {
+		Date     string       `json:"date"`
+		MaxTempC string       `json:"maxtempC"`
+		MinTempC string       `json:"mintempC"`
+		UVIndex  string       `json:"uvIndex"`
+		Hourly   []wttrHourly `json:"hourly"`
+	} `json:"weather"`
+}
+
+// wttrCurrent 当前天气
+type wttrCurrent struct {
+	TempC       string `json:"temp_C"`
+	WeatherCode string `json:"weatherCode"`
+	// LocalObsDateTime 观测时间（当地时间），如 2025-01-01 10:30 AM；ObservationTime 同一时刻的 UTC 时间，如 02:30 AM
+	LocalObsDateTime string `json:"localObsDateTime"`
+	ObservationTime  string `json:"observation_time"`
+}
+
+type wttrValue struct {
+	Value string `json:"value"`
+}
+
+// wttrHourly 3 小时一档的预报，Time 为当天的 HHMM（0、300……2100）
+type wttrHourly struct {
+	Time          string `json:"time"`
+	TempC         string `json:"tempC"`
+	WeatherCode   string `json:"weatherCode"`
+	ChanceOfRain  string `json:"chanceofrain"`
+	WindSpeedKmph string `json:"windspeedKmph"`
+	UVIndex       string `json:"uvIndex"`
+	PrecipMM      string `json:"precipMM"`
+}
+
+// Geocode 实现 WeatherProvider；wttr.in 按名字只返回最匹配的一个地点，count 不起作用
+func (c *WttrClient) Geocode(ctx context.Context, name string, count int) (*GeocodeResponse, error) {
+	w, err := c.get(ctx, "~"+url.PathEscape(name))
+	if err != nil {
+		return nil, err
+	}
+	out := &GeocodeResponse{}
+	tz := w.timezone()
+	for _, a := range w.NearestArea {
+		out.Results = append(out.Results, GeocodeResult{
+			Name:       first(a.AreaName),
+			Latitude:   wttrNum(a.Latitude),
+			Longitude:  wttrNum(a.Longitude),
+			Country:    first(a.Country),
+			Admin1:     first(a.Region),
+			Population: int(wttrNum(a.Population)),
+			Timezone:   tz,
+		})
+	}
+	if len(out.Results) > count {
+		out.Results = out.Results[:count]
+	}
+	return out, nil
+}
+
+// GetWeather 实现 WeatherProvider
+func (c *WttrClient) GetWeather(ctx context.Context, lat, lon float64) (*WeatherResponse, error) {
+	w, err := c.getAt(ctx, lat, lon)
+	if err != nil {
+		return nil, err
+	}
+	if len(w.CurrentCondition) == 0 {
+		return nil, fmt.Errorf("wttr: 响应中没有当前天气")
+	}
+	cur := w.CurrentCondition[0]
+	return &WeatherResponse{Current: WeatherCurrent{
+		Temperature2m: wttrNum(cur.TempC),
+		WeatherCode:   wwoToWMO(cur.WeatherCode),
+	}}, nil
+}
+
+// GetDailyForecast 实现 WeatherProvider，最多 3 天；天气代码取当天中午的一档，降水量为各档之和
+func (c *WttrClient) GetDailyForecast(ctx context.Context, lat, lon float64, days int) (*ForecastResponse, error) {
+	w, err := c.getAt(ctx, lat, lon)
+	if err != nil {
+		return nil, err
+	}
+	d := &DailyForecast{}
+	for i, day := range w.Weather {
+		if i >= days {
+			break
+		}
+		code, probMax, precip, windMax := 0, 0.0, 0.0, 0.0
+		for j, h := range day.Hourly {
+			if j == 0 || h.Time == "1200" {
+				code = wwoToWMO(h.WeatherCode)
+			}
+			probMax = max(probMax, wttrNum(h.ChanceOfRain))
+			precip += wttrNum(h.PrecipMM)
+			windMax = max(windMax, wttrNum(h.WindSpeedKmph))
+		}
+		d.Time = append(d.Time, day.Date)
+		d.WeatherCode = append(d.WeatherCode, code)
+		d.Temperature2mMax = append(d.Temperature2mMax, wttrNum(day.MaxTempC))
+		d.Temperature2mMin = append(d.Temperature2mMin, wttrNum(day.MinTempC))
+		d.PrecipitationProbabilityMax = append(d.PrecipitationProbabilityMax, probMax)
+		d.PrecipitationSum = append(d.PrecipitationSum, precip)
+		d.WindSpeed10mMax = append(d.WindSpeed10mMax, windMax)
+		d.UVIndexMax = append(d.UVIndexMax, wttrNum(day.UVIndex))
+	}
+	return &ForecastResponse{Timezone: w.timezone(), Daily: d}, nil
+}
+
+// GetHourlyForecast 实现 WeatherProvider：从当前时间所在的一档起，返回 hours 小时内的各档（3 小时一档）
+func (c *WttrClient) GetHourlyForecast(ctx context.Context, lat, lon float64, hours int) (*ForecastResponse, error) {
+	w, err := c.getAt(ctx, lat, lon)
+	if err != nil {
+		return nil, err
+	}
+	now, err := w.localNow()
+	if err != nil {
+		return nil, err
+	}
+	end := now.Add(time.Duration(hours) * time.Hour)
+	h := &HourlyForecast{}
+	for _, day := range w.Weather {
+		date, err := time.Parse("2006-01-02", day.Date)
+		if err != nil {
+			continue
+		}
+		for _, e := range day.Hourly {
+			hhmm, _ := strconv.Atoi(e.Time)
+			t := date.Add(time.Duration(hhmm/100)*time.Hour + time.Duration(hhmm%100)*time.Minute)
+			if !t.Add(3*time.Hour).After(now) || !t.Before(end) {
+				continue
+			}
+			h.Time = append(h.Time, t.Format("2006-01-02T15:04"))
+			h.Temperature2m = append(h.Temperature2m, wttrNum(e.TempC))
+			h.WeatherCode = append(h.WeatherCode, wwoToWMO(e.WeatherCode))
+			h.PrecipitationProbability = append(h.PrecipitationProbability, wttrNum(e.ChanceOfRain))
+			h.WindSpeed10m = append(h.WindSpeed10m, wttrNum(e.WindSpeedKmph))
+			h.UVIndex = append(h.UVIndex, wttrNum(e.UVIndex))
+		}
+	}
+	return &ForecastResponse{Timezone: w.timezone(), Hourly: h}, nil
+}
+
+// localObsLayout / obsTimeLayout localObsDateTime 与 observation_time 的格式
+const (
+	localObsLayout = "2006-01-02 03:04 PM"
+	obsTimeLayout  = "03:04 PM"
+)
+
+// localNow 观测时刻的当地时间（按 UTC 表示，与预报各档的日期时间直接比较）
+func (w *wttrResponse) localNow() (time.Time, error) {
+	if len(w.CurrentCondition) == 0 {
+		return time.Time{}, fmt.Errorf("wttr: 响应中没有当前天气")
+	}
+	s := w.CurrentCondition[0].LocalObsDateTime
+	t, err := time.Parse(localObsLayout, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("wttr: 无法解析观测时间 %q: %w", s, err)
+	}
+	return t, nil
+}
+
+// timezone 由观测时刻的当地时间与 UTC 时间之差推算时区，返回 Etc/GMT-8 这样的 IANA 名称
+// （Etc 时区的符号与 UTC 偏移相反）；缺少观测时间或偏移不是整小时（如 +05:30）时返回空。
+// 只是观测时刻的固定偏移，不含夏令时规则，用于换算“今天”足够
+func (w *wttrResponse) timezone() string {
+	local, err := w.localNow()
+	if err != nil {
+		return ""
+	}
+	utc, err := time.Parse(obsTimeLayout, w.CurrentCondition[0].ObservationTime)
+	if err != nil {
+		return ""
+	}
+	// 两者只差不到一天，按钟面时间相减后归一到 (-12h, +14h]，即实际存在的 UTC 偏移范围
+	clock := func(t time.Time) time.Duration {
+		return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+	}
+	offset := clock(local) - clock(utc)
+	switch {
+	case offset > 14*time.Hour:
+		offset -= 24 * time.Hour
+	case offset <= -12*time.Hour:
+		offset += 24 * time.Hour
+	}
+	if offset%time.Hour != 0 {
+		return ""
+	}
+	switch h := int(offset / time.Hour); {
+	case h == 0:
+		return "UTC"
+	case h > 0:
+		return fmt.Sprintf("Etc/GMT-%d", h)
+	default:
+		return fmt.Sprintf("Etc/GMT+%d", -h)
+	}
+}
+
+func (c *WttrClient) getAt(ctx context.Context, lat, lon float64) (*wttrResponse, error) {
+	return c.get(ctx, fmt.Sprintf("%f,%f", lat, lon))
+}
+
+// get 查询 location（经纬度，或 ~地名）的 format=j1 数据
+func (c *WttrClient) get(ctx context.Context, location string) (_ *wttrResponse, err error) {
+	defer observeWttr(time.Now(), &err)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/%s?format=j1", c.BaseURL, location), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("wttr status: %s", resp.Status)
+	}
+	var out wttrResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// wttrDuration wttr.in 调用耗时，status 为 ok 或 error
+var wttrDuration = metrics.NewHistogram("wttr_request_duration_seconds",
+	"wttr.in 调用耗时（秒）", nil, "status")
+
+// observeWttr 记录一次调用的耗时与结果，配合 defer 使用
+func observeWttr(start time.Time, err *error) {
+	status := "ok"
+	if *err != nil {
+		status = "error"
+	}
+	wttrDuration.ObserveSince(start, status)
+}
+
+func first(v []wttrValue) string {
+	if len(v) == 0 {
+		return ""
+	}
+	return v[0].Value
+}
+
+func wttrNum(s string) float64 {
+	f, _ := strconv.ParseFloat(s, 64)
+	return f
+}
+
+// wwoToWMO wttr.in 使用的 WorldWeatherOnline 天气代码转为 WMO 代码，未知代码按多云处理
+func wwoToWMO(code string) int {
+	switch code {
+	case "113":
+		return 0
+	case "116":
+		return 2
+	case "119", "122":
+		return 3
+	case "143", "248":
+		return 45
+	case "260":
+		return 48
+	case "263", "266":
+		return 51
+	case "281":
+		return 56
+	case "284":
+		return 57
+	case "176", "293", "296":
+		return 61
+	case "299", "302":
+		return 63
+	case "305", "308":
+		return 65
+	case "185", "311":
+		return 66
+	case "314":
+		return 67
+	case "179", "182", "317", "323", "326":
+		return 71
+	case "320", "329", "332":
+		return 73
+	case "227", "230", "335", "338":
+		return 75
+	case "350", "374", "377":
+		return 77
+	case "353":
+		return 80
+	case "356":
+		return 81
+	case "359":
+		return 82
+	case "362", "365", "368":
+		return 85
+	case "371":
+		return 86
+	case "200", "386", "389", "392", "395":
+		return 95
+	default:
+		return 3
+	}
+}
@@ -0,0 +1,134 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// wttrJ1 wttr.in format=j1 响应的精简样本：北京，观测于当地 2025-01-01 10:30 AM（UTC 02:30 AM）
+const wttrJ1 = `{
+  "current_condition": [{"temp_C": "-3", "weatherCode": "113", "localObsDateTime": "2025-01-01 10:30 AM", "observation_time": "02:30 AM"}],
+  "nearest_area": [{
+    "areaName": [{"value": "Beijing"}], "country": [{"value": "China"}], "region": [{"value": "Beijing"}],
+    "latitude": "39.929", "longitude": "116.388", "population": "7480601"
+  }],
+  "weather": [
+    {"date": "2025-01-01", "maxtempC": "2", "mintempC": "-8", "uvIndex": "2", "hourly": [
+      {"time": "0", "tempC": "-7", "weatherCode": "113", "chanceofrain": "0", "windspeedKmph": "5", "uvIndex": "0", "precipMM": "0.0"},
+      {"time": "900", "tempC": "-4", "weatherCode": "116", "chanceofrain": "0", "windspeedKmph": "8", "uvIndex": "1", "precipMM": "0.0"},
+      {"time": "1200", "tempC": "1", "weatherCode": "122", "chanceofrain": "20", "windspeedKmph": "12", "uvIndex": "2", "precipMM": "0.3"},
+      {"time": "1500", "tempC": "2", "weatherCode": "113", "chanceofrain": "10", "windspeedKmph": "10", "uvIndex": "2", "precipMM": "0.1"}
+    ]},
+    {"date": "2025-01-02", "maxtempC": "4", "mintempC": "-6", "uvIndex": "3", "hourly": [
+      {"time": "0", "tempC": "-5", "weatherCode": "296", "chanceofrain": "80", "windspeedKmph": "15", "uvIndex": "0", "precipMM": "1.2"}
+    ]}
+  ]
+}`
+
+// wttrStub 以 body 应答 format=j1 请求，paths 记录请求路径
+func wttrStub(t *testing.T, body string) (*WttrClient, *[]string) {
+	t.Helper()
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("format") != "j1" {
+			http.Error(w, "format=j1 required", http.StatusBadRequest)
+			return
+		}
+		paths = append(paths, r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	c := NewWttrClient()
+	c.BaseURL = srv.URL
+	return c, &paths
+}
+
+func TestWttrGeocode(t *testing.T) {
+	c, paths := wttrStub(t, wttrJ1)
+	geo, err := c.Geocode(context.Background(), "Beijing", 5)
+	if err != nil {
+		t.Fatalf("Geocode: %v", err)
+	}
+	if got := (*paths)[0]; got != "/~Beijing" {
+		t.Errorf("path = %q, want /~Beijing", got)
+	}
+	if len(geo.Results) != 1 {
+		t.Fatalf("results = %+v, want 1", geo.Results)
+	}
+	want := GeocodeResult{Name: "Beijing", Latitude: 39.929, Longitude: 116.388, Country: "China", Admin1: "Beijing", Population: 7480601, Timezone: "Etc/GMT-8"}
+	if geo.Results[0] != want {
+		t.Errorf("result = %+v, want %+v", geo.Results[0], want)
+	}
+}
+
+func TestWttrWeatherAndForecast(t *testing.T) {
+	ctx := context.Background()
+	c, _ := wttrStub(t, wttrJ1)
+
+	w, err := c.GetWeather(ctx, 39.9, 116.4)
+	if err != nil {
+		t.Fatalf("GetWeather: %v", err)
+	}
+	if w.Current.Temperature2m != -3 || w.Current.WeatherCode != 0 {
+		t.Errorf("current = %+v, want -3°C clear (WMO 0)", w.Current)
+	}
+
+	daily, err := c.GetDailyForecast(ctx, 39.9, 116.4, 5)
+	if err != nil {
+		t.Fatalf("GetDailyForecast: %v", err)
+	}
+	d := daily.Daily
+	if daily.Timezone != "Etc/GMT-8" || len(d.Time) != 2 || d.Time[0] != "2025-01-01" {
+		t.Fatalf("daily = %+v (timezone %q), want 2 days from 2025-01-01 in Etc/GMT-8", d, daily.Timezone)
+	}
+	// 天气代码取中午一档，降水为各档之和
+	if d.WeatherCode[0] != 3 || d.PrecipitationProbabilityMax[0] != 20 || d.Temperature2mMin[0] != -8 || d.WindSpeed10mMax[0] != 12 {
+		t.Errorf("day 1 = code %d, rain %v%%, min %v, wind %v", d.WeatherCode[0], d.PrecipitationProbabilityMax[0], d.Temperature2mMin[0], d.WindSpeed10mMax[0])
+	}
+	if got := d.PrecipitationSum[0]; got < 0.39 || got > 0.41 {
+		t.Errorf("day 1 precipitation = %v, want 0.4", got)
+	}
+
+	// 10:30 所在的 09:00 一档起 24 小时
+	hourly, err := c.GetHourlyForecast(ctx, 39.9, 116.4, 24)
+	if err != nil {
+		t.Fatalf("GetHourlyForecast: %v", err)
+	}
+	want := []string{"2025-01-01T09:00", "2025-01-01T12:00", "2025-01-01T15:00", "2025-01-02T00:00"}
+	if got := strings.Join(hourly.Hourly.Time, ","); got != strings.Join(want, ",") {
+		t.Errorf("hourly times = %s, want %s", got, strings.Join(want, ","))
+	}
+	if hourly.Timezone != "Etc/GMT-8" {
+		t.Errorf("hourly timezone = %q, want Etc/GMT-8", hourly.Timezone)
+	}
+}
+
+func TestWttrHourlyForecastBadObsTime(t *testing.T) {
+	c, _ := wttrStub(t, strings.Replace(wttrJ1, "2025-01-01 10:30 AM", "01/01/2025 10:30", 1))
+	if _, err := c.GetHourlyForecast(context.Background(), 39.9, 116.4, 24); err == nil {
+		t.Fatal("GetHourlyForecast succeeded with unparsable localObsDateTime, want error")
+	}
+}
+
+func TestWttrTimezone(t *testing.T) {
+	for _, tc := range []struct {
+		local, utc, want string
+	}{
+		{"2025-01-01 10:30 AM", "02:30 AM", "Etc/GMT-8"},
+		{"2025-01-01 01:00 AM", "05:00 PM", "Etc/GMT-8"}, // 当地已过零点
+		{"2025-01-01 07:00 PM", "12:00 AM", "Etc/GMT+5"}, // UTC 已是第二天
+		{"2025-01-01 12:00 PM", "12:00 PM", "UTC"},
+		{"2025-01-01 01:00 PM", "01:00 AM", "Etc/GMT-12"},
+		{"2025-01-01 03:30 PM", "10:00 AM", ""}, // +05:30 没有对应的 Etc 时区
+		{"2025-01-01 03:30 PM", "", ""},
+	} {
+		w := wttrResponse{CurrentCondition: []wttrCurrent{{LocalObsDateTime: tc.local, ObservationTime: tc.utc}}}
+		if got := w.timezone(); got != tc.want {
+			t.Errorf("timezone(%s, utc %s) = %q, want %q", tc.local, tc.utc, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// AirQualityResult air_quality 的结构化结果，浓度单位为 μg/m³，缺失的数据不输出。
+// 地名有歧义时只有 City（用户给出的地名）与 Ambiguous
+type AirQualityResult struct {
+	City      string  `json:"city"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Timezone  string  `json:"timezone"`
+	// Time 数据对应的当地时间
+	Time            string   `json:"time,omitempty"`
+	PM2_5           *float64 `json:"pm2_5,omitempty"`
+	PM10            *float64 `json:"pm10,omitempty"`
+	Ozone           *float64 `json:"ozone,omitempty"`
+	NitrogenDioxide *float64 `json:"nitrogen_dioxide,omitempty"`
+	EuropeanAQI     *float64 `json:"european_aqi,omitempty"`
+	USAQI           *float64 `json:"us_aqi,omitempty"`
+	// Category 空气质量等级，按美国 AQI 划分，缺少时按欧洲 AQI
+	Category string `json:"category,omitempty"`
+	// Advice 对应等级的健康建议
+	Advice string `json:"advice,omitempty"`
+	// Ambiguous 地名匹配到多个地点时的候选列表
+	Ambiguous *Disambiguation `json:"ambiguous,omitempty"`
+}
+
+// aqiLevel AQI 不超过 Max 时的等级
+type aqiLevel struct {
+	Max      float64
+	Category string
+}
+
+// usAQILevels / europeanAQILevels 两种 AQI 的六级划分，同一下标对应 aqiAdvice 中的同一条健康建议
+var (
+	usAQILevels = []aqiLevel{
+		{50, "良好"}, {100, "中等"}, {150, "对敏感人群不健康"},
+		{200, "不健康"}, {300, "非常不健康"}, {math.Inf(1), "危险"},
+	}
+	europeanAQILevels = []aqiLevel{
+		{20, "良好"}, {40, "一般"}, {60, "中等"},
+		{80, "较差"}, {100, "很差"}, {math.Inf(1), "极差"},
+	}
+	aqiAdvice = []string{
+		"空气质量令人满意，适合各类户外活动。",
+		"空气质量可以接受，极少数异常敏感人群应减少长时间户外剧烈活动。",
+		"儿童、老人及心肺疾病患者应减少长时间或高强度户外活动。",
+		"所有人都应减少户外活动，敏感人群避免户外活动，外出建议佩戴口罩。",
+		"避免户外活动，关闭门窗，有条件时开启空气净化器。",
+		"所有人都应留在室内并避免体力活动，敏感人群遵医嘱。",
+	}
+)
+
+// aqiCategory 返回 AQI 所在等级与健康建议
+func aqiCategory(levels []aqiLevel, aqi float64) (category, advice string) {
+	for i, l := range levels {
+		if aqi <= l.Max {
+			return l.Category, aqiAdvice[i]
+		}
+	}
+	return "", ""
+}
+
+// AirQuality 查询指定城市当前空气质量（PM2.5、PM10、臭氧、二氧化氮、欧洲 / 美国 AQI）的 MCP tool handler，
+// 返回文字说明与 AirQualityResult 结构化结果；地名解析与 weather 相同，有歧义时返回候选列表
+func AirQuality(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	p, ambiguous, err := resolvePlace(ctx, req)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	if ambiguous != nil {
+		return mcp.NewToolResultStructured(AirQualityResult{City: ambiguous.Query, Ambiguous: ambiguous}, ambiguous.text()), nil
+	}
+
+	aq, err := openMeteoClient.GetAirQuality(ctx, p.Latitude, p.Longitude)
+	if err != nil {
+		return mcp.NewToolResultError("空气质量查询: " + err.Error()), nil
+	}
+	cur := aq.Current
+	result := AirQualityResult{
+		City:            p.DisplayName,
+		Latitude:        p.Latitude,
+		Longitude:       p.Longitude,
+		Timezone:        aq.Timezone,
+		Time:            cur.Time,
+		PM2_5:           cur.PM2_5,
+		PM10:            cur.PM10,
+		Ozone:           cur.Ozone,
+		NitrogenDioxide: cur.NitrogenDioxide,
+		EuropeanAQI:     cur.EuropeanAQI,
+		USAQI:           cur.USAQI,
+	}
+	switch {
+	case cur.USAQI != nil:
+		result.Category, result.Advice = aqiCategory(usAQILevels, *cur.USAQI)
+	case cur.EuropeanAQI != nil:
+		result.Category, result.Advice = aqiCategory(europeanAQILevels, *cur.EuropeanAQI)
+	}
+	return mcp.NewToolResultStructured(result, result.text()), nil
+}
+
+// text 精简的文字说明，缺失的数据显示为“无数据”
+func (a AirQualityResult) text() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "城市: %s，经纬度: (%.3f, %.3f)", a.City, a.Latitude, a.Longitude)
+	if a.Time != "" {
+		fmt.Fprintf(&b, "，时间: %s（%s）", strings.Replace(a.Time, "T", " ", 1), a.Timezone)
+	}
+	fmt.Fprintf(&b, "\nPM2.5: %s，PM10: %s，臭氧: %s，二氧化氮: %s（μg/m³）",
+		optional(a.PM2_5, "%.1f"), optional(a.PM10, "%.1f"), optional(a.Ozone, "%.1f"), optional(a.NitrogenDioxide, "%.1f"))
+	fmt.Fprintf(&b, "\n美国 AQI: %s，欧洲 AQI: %s", optional(a.USAQI, "%.0f"), optional(a.EuropeanAQI, "%.0f"))
+	if a.EuropeanAQI != nil {
+		eu, _ := aqiCategory(europeanAQILevels, *a.EuropeanAQI)
+		fmt.Fprintf(&b, "（欧洲标准：%s）", eu)
+	}
+	if a.Category != "" {
+		fmt.Fprintf(&b, "\n空气质量: %s。%s", a.Category, a.Advice)
+	}
+	return b.String()
+}
+
+// optional 按 format 格式化可能缺失的数值
+func optional(v *float64, format string) string {
+	if v == nil {
+		return "无数据"
+	}
+	return fmt.Sprintf(format, *v)
+}
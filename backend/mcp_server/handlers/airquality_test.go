@@ -0,0 +1,87 @@
+package handlers_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aistudiolabx/eino-demo/backend/mcp_server/client"
+	"github.com/aistudiolabx/eino-demo/backend/mcp_server/handlers"
+	"github.com/aistudiolabx/eino-demo/backend/mcp_server/mcptest"
+)
+
+func TestAirQuality(t *testing.T) {
+	eu := 35.0
+	pm25 := 12.5
+	cities := map[string]mcptest.City{
+		"Beijing": mcptest.DefaultCities["Beijing"],
+		// 只有 PM2.5 与欧洲 AQI：等级按欧洲 AQI
+		"Lhasa": {Latitude: 29.65, Longitude: 91.1, Country: "中国", CountryCode: "CN", Admin1: "西藏自治区", Population: 300000,
+			AirQuality: client.AirQualityCurrent{Time: "2025-01-01T00:00", PM2_5: &pm25, EuropeanAQI: &eu}},
+		// 没有任何数据
+		"Nuuk": {Latitude: 64.18, Longitude: -51.72, Country: "Greenland", CountryCode: "GL", Timezone: "America/Nuuk"},
+	}
+	cli := newClient(t, &mcptest.Upstream{Cities: cities})
+
+	for _, tc := range []struct {
+		city     string
+		category string
+		nils     []string
+		text     []string
+		absent   []string
+	}{
+		{
+			city:     "Beijing",
+			category: "对敏感人群不健康",
+			text: []string{
+				"城市: Beijing, 北京市, 中国，经纬度: (39.907, 116.397)，时间: 2025-01-01 00:00（Asia/Shanghai）",
+				"PM2.5: 55.2，PM10: 80.1，臭氧: 62.0，二氧化氮: 38.5（μg/m³）",
+				"美国 AQI: 150，欧洲 AQI: 48（欧洲标准：中等）",
+				"空气质量: 对敏感人群不健康。儿童、老人及心肺疾病患者应减少长时间或高强度户外活动。",
+			},
+		},
+		{
+			city:     "Lhasa",
+			category: "一般",
+			nils:     []string{"pm10", "ozone", "nitrogen_dioxide", "us_aqi"},
+			text: []string{
+				"PM2.5: 12.5，PM10: 无数据，臭氧: 无数据，二氧化氮: 无数据（μg/m³）",
+				"美国 AQI: 无数据，欧洲 AQI: 35（欧洲标准：一般）",
+				"空气质量: 一般。",
+			},
+		},
+		{
+			city:   "Nuuk",
+			nils:   []string{"time", "pm2_5", "pm10", "ozone", "nitrogen_dioxide", "european_aqi", "us_aqi", "category", "advice"},
+			text:   []string{"PM2.5: 无数据，PM10: 无数据，臭氧: 无数据，二氧化氮: 无数据（μg/m³）", "美国 AQI: 无数据，欧洲 AQI: 无数据"},
+			absent: []string{"时间:", "欧洲标准", "空气质量:"},
+		},
+	} {
+		t.Run(tc.city, func(t *testing.T) {
+			res, text := callTool(t, cli, "air_quality", map[string]any{"city": tc.city})
+			if res.IsError {
+				t.Fatalf("air_quality error: %s", text)
+			}
+			a := structured[handlers.AirQualityResult](t, res)
+			if a.Category != tc.category {
+				t.Errorf("category = %q, want %q", a.Category, tc.category)
+			}
+			// 缺失的数据在结构化结果中省略，而不是输出 0
+			fields := structured[map[string]any](t, res)
+			for _, key := range tc.nils {
+				if v, ok := fields[key]; ok {
+					t.Errorf("%s = %v, want omitted", key, v)
+				}
+			}
+			for _, want := range tc.text {
+				if !strings.Contains(text, want) {
+					t.Errorf("text missing %q:\n%s", want, text)
+				}
+			}
+			for _, unwanted := range tc.absent {
+				if strings.Contains(text, unwanted) {
+					t.Errorf("text contains %q:\n%s", unwanted, text)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package handlers
+
+import "testing"
+
+func TestAQICategory(t *testing.T) {
+	for _, tc := range []struct {
+		levels   []aqiLevel
+		aqi      float64
+		category string
+		advice   int
+	}{
+		{usAQILevels, 0, "良好", 0},
+		{usAQILevels, 50, "良好", 0},
+		{usAQILevels, 50.5, "中等", 1},
+		{usAQILevels, 100, "中等", 1},
+		{usAQILevels, 101, "对敏感人群不健康", 2},
+		{usAQILevels, 150, "对敏感人群不健康", 2},
+		{usAQILevels, 151, "不健康", 3},
+		{usAQILevels, 200, "不健康", 3},
+		{usAQILevels, 201, "非常不健康", 4},
+		{usAQILevels, 300, "非常不健康", 4},
+		{usAQILevels, 301, "危险", 5},
+		{usAQILevels, 999, "危险", 5},
+		{europeanAQILevels, 20, "良好", 0},
+		{europeanAQILevels, 21, "一般", 1},
+		{europeanAQILevels, 40, "一般", 1},
+		{europeanAQILevels, 41, "中等", 2},
+		{europeanAQILevels, 60, "中等", 2},
+		{europeanAQILevels, 61, "较差", 3},
+		{europeanAQILevels, 80, "较差", 3},
+		{europeanAQILevels, 81, "很差", 4},
+		{europeanAQILevels, 100, "很差", 4},
+		{europeanAQILevels, 100.5, "极差", 5},
+	} {
+		category, advice := aqiCategory(tc.levels, tc.aqi)
+		if category != tc.category || advice != aqiAdvice[tc.advice] {
+			t.Errorf("aqiCategory(%v) = %s %q, want %s %q", tc.aqi, category, advice, tc.category, aqiAdvice[tc.advice])
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	// 容器镜像不一定带时区数据库，内嵌一份以便按城市时区计算“昨天”
+	_ "time/tzdata"
+)
+
+// dateLayout 工具参数与 Open-Meteo 使用的日期格式
+const dateLayout = "2006-01-02"
+
+// now 当前时间，测试中替换为固定时间
+var now = time.Now
+
+// localToday 城市时区的今天（按 UTC 零点表示，只用于日期运算）；时区为空或无法识别时按 UTC
+func localToday(timezone string) time.Time {
+	loc, err := time.LoadLocation(timezone)
+	if timezone == "" || err != nil {
+		loc = time.UTC
+	}
+	y, m, d := now().In(loc).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+var (
+	weekdayNames = map[string]time.Weekday{
+		"sunday": time.Sunday, "monday": time.Monday, "tuesday": time.Tuesday, "wednesday": time.Wednesday,
+		"thursday": time.Thursday, "friday": time.Friday, "saturday": time.Saturday,
+		"日": time.Sunday, "天": time.Sunday, "一": time.Monday, "二": time.Tuesday, "三": time.Wednesday,
+		"四": time.Thursday, "五": time.Friday, "六": time.Saturday,
+	}
+	daysAgoRe   = regexp.MustCompile(`^(\d+)\s*(?:天前|days? ago)$`)
+	pastDaysRe  = regexp.MustCompile(`^(?:(?:last|past)\s+(\d+)\s+days?|(?:过去|最近|近)\s*(\d+)\s*天)$`)
+	enWeekdayRe = regexp.MustCompile(`^(last\s+)?(sunday|monday|tuesday|wednesday|thursday|friday|saturday)$`)
+	zhWeekdayRe = regexp.MustCompile(`^(上个?)?(?:周|星期|礼拜)([一二三四五六日天])$`)
+)
+
+// parseDates 把日期参数解析为含两端的日期范围：YYYY-MM-DD，或相对 today 的短语——
+// 今天/昨天/前天/大前天、N 天前、过去 N 天（不含今天）、上周/本周/上个月，
+// 以及星期几：“周六”“saturday”为今天或之前最近的周六，“last saturday”为今天之前最近的周六，
+// “上周六”为上一个自然周（周一至周日）的周六
+func parseDates(s string, today time.Time) (start, end time.Time, err error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	day := func(offset int) (time.Time, time.Time, error) {
+		d := today.AddDate(0, 0, offset)
+		return d, d, nil
+	}
+	// monday 本周一（一周从周一开始）
+	monday := today.AddDate(0, 0, -((int(today.Weekday()) + 6) % 7))
+
+	switch s {
+	case "today", "今天":
+		return day(0)
+	case "yesterday", "昨天":
+		return day(-1)
+	case "day before yesterday", "前天":
+		return day(-2)
+	case "大前天":
+		return day(-3)
+	case "this week", "本周", "这周", "这个星期":
+		return monday, today, nil
+	case "last week", "上周", "上星期", "上个星期":
+		return monday.AddDate(0, 0, -7), monday.AddDate(0, 0, -1), nil
+	case "last month", "上个月", "上月":
+		first := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return first.AddDate(0, -1, 0), first.AddDate(0, 0, -1), nil
+	}
+	if m := daysAgoRe.FindStringSubmatch(s); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		return day(-n)
+	}
+	if m := pastDaysRe.FindStringSubmatch(s); m != nil {
+		n, _ := strconv.Atoi(m[1] + m[2])
+		if n < 1 {
+			return time.Time{}, time.Time{}, fmt.Errorf("无法识别的日期 %q", s)
+		}
+		return today.AddDate(0, 0, -n), today.AddDate(0, 0, -1), nil
+	}
+	if m := enWeekdayRe.FindStringSubmatch(s); m != nil {
+		d := lastWeekday(today, weekdayNames[m[2]], m[1] != "")
+		return d, d, nil
+	}
+	if m := zhWeekdayRe.FindStringSubmatch(s); m != nil {
+		wd := weekdayNames[m[2]]
+		if m[1] == "" {
+			d := lastWeekday(today, wd, false)
+			return d, d, nil
+		}
+		d := monday.AddDate(0, 0, -7+(int(wd)+6)%7)
+		return d, d, nil
+	}
+	d, err := time.Parse(dateLayout, s)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("无法识别的日期 %q，请使用 YYYY-MM-DD 或“昨天”“上周六”“过去 7 天”等说法", s)
+	}
+	return d, d, nil
+}
+
+// lastWeekday 今天或之前最近的 wd；strict 为 true 时不含今天
+func lastWeekday(today time.Time, wd time.Weekday, strict bool) time.Time {
+	back := (int(today.Weekday()) - int(wd) + 7) % 7
+	if back == 0 && strict {
+		back = 7
+	}
+	return today.AddDate(0, 0, -back)
+}
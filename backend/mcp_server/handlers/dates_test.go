@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// day 解析 YYYY-MM-DD，格式错误时直接 panic（只用于测试数据）
+func day(s string) time.Time {
+	d, err := time.Parse(dateLayout, s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func TestParseDates(t *testing.T) {
+	for _, tc := range []struct {
+		today      string
+		in         string
+		start, end string
+	}{
+		// 周一
+		{"2025-03-10", "今天", "2025-03-10", "2025-03-10"},
+		{"2025-03-10", " Yesterday ", "2025-03-09", "2025-03-09"},
+		{"2025-03-10", "前天", "2025-03-08", "2025-03-08"},
+		{"2025-03-10", "day before yesterday", "2025-03-08", "2025-03-08"},
+		{"2025-03-10", "大前天", "2025-03-07", "2025-03-07"},
+		{"2025-03-10", "3天前", "2025-03-07", "2025-03-07"},
+		{"2025-03-10", "1 day ago", "2025-03-09", "2025-03-09"},
+		{"2025-03-10", "本周", "2025-03-10", "2025-03-10"},
+		{"2025-03-10", "last week", "2025-03-03", "2025-03-09"},
+		{"2025-03-10", "过去 7 天", "2025-03-03", "2025-03-09"},
+		{"2025-03-10", "last 3 days", "2025-03-07", "2025-03-09"},
+		{"2025-03-10", "近1天", "2025-03-09", "2025-03-09"},
+		{"2025-03-10", "周一", "2025-03-10", "2025-03-10"},
+		{"2025-03-10", "monday", "2025-03-10", "2025-03-10"},
+		{"2025-03-10", "last monday", "2025-03-03", "2025-03-03"},
+		{"2025-03-10", "周六", "2025-03-08", "2025-03-08"},
+		{"2025-03-10", "last saturday", "2025-03-08", "2025-03-08"},
+		{"2025-03-10", "上周六", "2025-03-08", "2025-03-08"},
+		{"2025-03-10", "上周一", "2025-03-03", "2025-03-03"},
+		{"2025-03-10", "上个星期日", "2025-03-09", "2025-03-09"},
+		// 周日：本周与上周按周一开始计算
+		{"2025-03-16", "这周", "2025-03-10", "2025-03-16"},
+		{"2025-03-16", "上周", "2025-03-03", "2025-03-09"},
+		{"2025-03-16", "星期天", "2025-03-16", "2025-03-16"},
+		{"2025-03-16", "sunday", "2025-03-16", "2025-03-16"},
+		{"2025-03-16", "last sunday", "2025-03-09", "2025-03-09"},
+		{"2025-03-16", "上周日", "2025-03-09", "2025-03-09"},
+		{"2025-03-16", "周六", "2025-03-15", "2025-03-15"},
+		{"2025-03-16", "last saturday", "2025-03-15", "2025-03-15"},
+		{"2025-03-16", "上礼拜六", "2025-03-08", "2025-03-08"},
+		// 月初（周六）：跨月、跨年与闰年
+		{"2025-03-01", "昨天", "2025-02-28", "2025-02-28"},
+		{"2025-03-01", "上个月", "2025-02-01", "2025-02-28"},
+		{"2025-03-01", "本周", "2025-02-24", "2025-03-01"},
+		{"2025-03-01", "上周", "2025-02-17", "2025-02-23"},
+		{"2025-03-01", "过去3天", "2025-02-26", "2025-02-28"},
+		{"2025-03-01", "周六", "2025-03-01", "2025-03-01"},
+		{"2025-03-01", "上周六", "2025-02-22", "2025-02-22"},
+		{"2024-03-01", "last month", "2024-02-01", "2024-02-29"},
+		{"2025-01-01", "上月", "2024-12-01", "2024-12-31"},
+		{"2025-01-01", "上周三", "2024-12-25", "2024-12-25"},
+		{"2025-01-01", "this week", "2024-12-30", "2025-01-01"},
+		// 绝对日期原样返回，范围检查由 historyRange 负责
+		{"2025-03-10", "2024-02-29", "2024-02-29", "2024-02-29"},
+		{"2025-03-10", "2030-01-01", "2030-01-01", "2030-01-01"},
+	} {
+		start, end, err := parseDates(tc.in, day(tc.today))
+		if err != nil {
+			t.Errorf("parseDates(%q, %s): %v", tc.in, tc.today, err)
+			continue
+		}
+		if got := start.Format(dateLayout) + "~" + end.Format(dateLayout); got != tc.start+"~"+tc.end {
+			t.Errorf("parseDates(%q, %s) = %s, want %s~%s", tc.in, tc.today, got, tc.start, tc.end)
+		}
+	}
+}
+
+func TestParseDatesRejects(t *testing.T) {
+	for _, in := range []string{"", "明天", "过去 0 天", "2025/03/01", "2025-02-30", "next saturday", "下周六"} {
+		if start, end, err := parseDates(in, day("2025-03-10")); err == nil {
+			t.Errorf("parseDates(%q) = %s~%s, want error", in, start.Format(dateLayout), end.Format(dateLayout))
+		}
+	}
+}
+
+func TestHistoryRange(t *testing.T) {
+	today := day("2025-03-10") // 周一
+	for _, tc := range []struct {
+		name               string
+		date, start, end   string
+		wantStart, wantEnd string
+		wantErr            string
+	}{
+		{name: "date", date: "上周六", wantStart: "2025-03-08", wantEnd: "2025-03-08"},
+		{name: "date range phrase", date: "上个月", wantStart: "2025-02-01", wantEnd: "2025-02-28"},
+		{name: "start only", start: "2025-03-01", wantStart: "2025-03-01", wantEnd: "2025-03-01"},
+		{name: "start and end", start: "上周一", end: "昨天", wantStart: "2025-03-03", wantEnd: "2025-03-09"},
+		// end_date 为范围短语时取范围的结束日期
+		{name: "end phrase", start: "2025-02-20", end: "上周", wantStart: "2025-02-20", wantEnd: "2025-03-09"},
+		{name: "date wins over start", date: "昨天", start: "2025-01-01", wantStart: "2025-03-09", wantEnd: "2025-03-09"},
+		{name: "today", date: "今天", wantStart: "2025-03-10", wantEnd: "2025-03-10"},
+		{name: "31 days", start: "2025-02-08", end: "2025-03-10", wantStart: "2025-02-08", wantEnd: "2025-03-10"},
+		{name: "past 31 days", date: "过去31天", wantStart: "2025-02-07", wantEnd: "2025-03-09"},
+		{name: "archive start", start: "1940-01-01", end: "1940-01-31", wantStart: "1940-01-01", wantEnd: "1940-01-31"},
+		{name: "32 days", start: "2025-02-07", end: "2025-03-10", wantErr: "一次最多查询 31 天"},
+		{name: "past 32 days", date: "过去 32 天", wantErr: "一次最多查询 31 天"},
+		{name: "before archive", start: "1939-12-31", end: "1940-01-05", wantErr: "1940-01-01"},
+		{name: "future date", date: "2025-03-11", wantErr: "晚于当地今天 2025-03-10"},
+		{name: "future end", start: "2025-03-08", end: "2025-03-12", wantErr: "晚于当地今天"},
+		{name: "end before start", start: "2025-03-08", end: "2025-03-01", wantErr: "早于开始日期"},
+		{name: "bad date", date: "明天", wantErr: "无法识别的日期"},
+		{name: "bad end", start: "2025-03-01", end: "下周", wantErr: "无法识别的日期"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			start, end, err := historyRange(tc.date, tc.start, tc.end, today)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Errorf("error = %v, want %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("historyRange: %v", err)
+			}
+			if got := start.Format(dateLayout) + "~" + end.Format(dateLayout); got != tc.wantStart+"~"+tc.wantEnd {
+				t.Errorf("range = %s, want %s~%s", got, tc.wantStart, tc.wantEnd)
+			}
+		})
+	}
+}
+
+func TestLocalToday(t *testing.T) {
+	defer func(orig func() time.Time) { now = orig }(now)
+	for _, tc := range []struct {
+		now      string
+		timezone string
+		want     string
+	}{
+		// UTC 3 月 9 日 17:30：东八区已是 10 日，美东（已切换夏令时，UTC-4）仍是 9 日
+		{"2025-03-09T17:30:00Z", "Asia/Shanghai", "2025-03-10"},
+		{"2025-03-09T17:30:00Z", "America/New_York", "2025-03-09"},
+		{"2025-03-09T17:30:00Z", "Pacific/Kiritimati", "2025-03-10"},
+		{"2025-03-09T17:30:00Z", "UTC", "2025-03-09"},
+		// UTC 3 月 1 日 05:00：洛杉矶仍是 2 月最后一天
+		{"2025-03-01T05:00:00Z", "America/Los_Angeles", "2025-02-28"},
+		{"2025-03-01T05:00:00Z", "Etc/GMT-8", "2025-03-01"},
+		// 时区为空或无法识别时按 UTC
+		{"2025-03-09T23:59:59Z", "", "2025-03-09"},
+		{"2025-03-09T23:59:59Z", "Mars/Olympus_Mons", "2025-03-09"},
+	} {
+		fixed, err := time.Parse(time.RFC3339, tc.now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		now = func() time.Time { return fixed }
+		got := localToday(tc.timezone)
+		if !got.Equal(day(tc.want)) {
+			t.Errorf("localToday(%q) at %s = %s, want %s", tc.timezone, tc.now, got.Format(time.RFC3339), tc.want)
+		}
+	}
+}
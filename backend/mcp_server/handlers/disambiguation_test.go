@@ -0,0 +1,101 @@
+package handlers_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aistudiolabx/eino-demo/backend/mcp_server/handlers"
+	"github.com/aistudiolabx/eino-demo/backend/mcp_server/mcptest"
+)
+
+// ambiguousPlaces 同名地点：朝阳（北京的区与辽宁的市、县，人口相近）与 7 个人口相同的 Springfield
+func ambiguousPlaces() map[string][]mcptest.City {
+	places := map[string][]mcptest.City{
+		"朝阳": {
+			{Name: "朝阳区", Latitude: 39.92149, Longitude: 116.48641, Country: "中国", CountryCode: "CN", Admin1: "北京市", Population: 3452460, Timezone: "Asia/Shanghai", Temperature: 20},
+			{Name: "朝阳市", Latitude: 41.57028, Longitude: 120.45861, Country: "中国", CountryCode: "CN", Admin1: "辽宁省", Population: 2872857, Timezone: "Asia/Shanghai", Temperature: 12},
+			{Name: "朝阳县", Latitude: 41.5, Longitude: 120.4, Country: "中国", CountryCode: "CN", Admin1: "辽宁省", Admin2: "朝阳市", Timezone: "Asia/Shanghai", Temperature: 11},
+		},
+	}
+	for i := 0; i < 7; i++ {
+		places["Springfield"] = append(places["Springfield"], mcptest.City{
+			Latitude: 30 + float64(i), Longitude: -90, Country: "United States", CountryCode: "US", Population: 100000, Timezone: "America/Chicago",
+		})
+	}
+	return places
+}
+
+func TestAmbiguousPlace(t *testing.T) {
+	cli := newClient(t, &mcptest.Upstream{Places: ambiguousPlaces()})
+
+	res, text := callTool(t, cli, "weather_forecast", map[string]any{"city": "朝阳"})
+	if res.IsError {
+		t.Fatalf("weather_forecast error: %s", text)
+	}
+	f := structured[handlers.ForecastResult](t, res)
+	if f.City != "朝阳" || f.Ambiguous == nil || len(f.Daily) != 0 {
+		t.Fatalf("result = %+v, want candidates for 朝阳 without forecast", f)
+	}
+	var got []string
+	for _, c := range f.Ambiguous.Candidates {
+		got = append(got, c.Name)
+	}
+	if strings.Join(got, ",") != "朝阳区,朝阳市,朝阳县" {
+		t.Errorf("candidates = %v, want ordered by population", got)
+	}
+	for _, want := range []string{
+		"「朝阳」匹配到 3 个地点，请向用户确认是哪一个，或带上 country / region 参数重新查询：",
+		"1. 朝阳区, 北京市, 中国（country=CN，region=北京市），经纬度: (39.921, 116.486)，人口: 3452460，时区: Asia/Shanghai",
+		"3. 朝阳县, 辽宁省, 中国（country=CN，region=辽宁省），经纬度: (41.500, 120.400)，时区: Asia/Shanghai",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("text missing %q:\n%s", want, text)
+		}
+	}
+
+	// 其余用到 resolvePlace 的工具返回相同的候选
+	for _, tool := range []string{"weather", "air_quality", "weather_history"} {
+		args := map[string]any{"city": "朝阳", "date": "2025-01-01"}
+		if res, got := callTool(t, cli, tool, args); res.IsError || got != text {
+			t.Errorf("%s = %q (error %v), want the same candidate list", tool, got, res.IsError)
+		}
+	}
+
+	// 候选最多列出 5 个
+	res, _ = callTool(t, cli, "weather_forecast", map[string]any{"city": "Springfield"})
+	if f := structured[handlers.ForecastResult](t, res); f.Ambiguous == nil || len(f.Ambiguous.Candidates) != 5 {
+		t.Errorf("Springfield = %+v, want 5 listed candidates", f.Ambiguous)
+	}
+}
+
+func TestAmbiguousPlaceFilters(t *testing.T) {
+	cli := newClient(t, &mcptest.Upstream{Places: ambiguousPlaces()})
+	for _, tc := range []struct {
+		args    map[string]any
+		city    string
+		wantErr string
+	}{
+		{args: map[string]any{"region": "北京"}, city: "朝阳区, 北京市, 中国"},
+		// 辽宁的市与县：县没有人口数据，市直接选中
+		{args: map[string]any{"country": "CN", "region": "辽宁省"}, city: "朝阳市, 辽宁省, 中国"},
+		{args: map[string]any{"country": "中国", "region": "朝阳市"}, city: "朝阳县, 辽宁省, 中国"},
+		{args: map[string]any{"country": "JP"}, wantErr: "未找到位于 JP 的「朝阳」，可去掉 country / region 参数查看全部候选"},
+		{args: map[string]any{"country": "CN", "region": "吉林"}, wantErr: "未找到位于 CN 吉林 的「朝阳」"},
+	} {
+		tc.args["city"] = "朝阳"
+		res, text := callTool(t, cli, "weather_forecast", tc.args)
+		if tc.wantErr != "" {
+			if !res.IsError || !strings.HasPrefix(text, tc.wantErr) {
+				t.Errorf("%v: result = %q (error %v), want error %q", tc.args, text, res.IsError, tc.wantErr)
+			}
+			continue
+		}
+		if res.IsError {
+			t.Errorf("%v: error %s", tc.args, text)
+			continue
+		}
+		if f := structured[handlers.ForecastResult](t, res); f.City != tc.city || f.Ambiguous != nil || len(f.Daily) != 3 {
+			t.Errorf("%v: city %q, ambiguous %v, %d days, want forecast for %s", tc.args, f.City, f.Ambiguous, len(f.Daily), tc.city)
+		}
+	}
+}
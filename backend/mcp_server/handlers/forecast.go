@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aistudiolabx/eino-demo/backend/mcp_server/client"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// weather_forecast 参数范围：days 对应 Open-Meteo forecast_days（最多 16），hours 限制在 3 天内以保持结果精简
+const (
+	defaultForecastDays = 3
+	maxForecastDays     = 16
+	maxForecastHours    = 72
+)
+
+// ForecastResult weather_forecast 的结构化结果，日期与时间均为当地时间。
+// 地名有歧义时只有 City（用户给出的地名）与 Ambiguous，没有预报数据
+type ForecastResult struct {
+	City      string          `json:"city"`
+	Latitude  float64         `json:"latitude"`
+	Longitude float64         `json:"longitude"`
+	Timezone  string          `json:"timezone"`
+	Daily     []DailyWeather  `json:"daily,omitempty"`
+	Hourly    []HourlyWeather `json:"hourly,omitempty"`
+	// Ambiguous 地名匹配到多个地点时的候选列表
+	Ambiguous *Disambiguation `json:"ambiguous,omitempty"`
+}
+
+// DailyWeather 一天的预报
+type DailyWeather struct {
+	Date        string  `json:"date"`
+	Weather     string  `json:"weather"`
+	WeatherCode int     `json:"weather_code"`
+	TempMinC    float64 `json:"temp_min_c"`
+	TempMaxC    float64 `json:"temp_max_c"`
+	// PrecipitationProbability 当天最大降水概率（%）
+	PrecipitationProbability float64 `json:"precipitation_probability"`
+	PrecipitationMM          float64 `json:"precipitation_mm"`
+	WindSpeedMaxKMH          float64 `json:"wind_speed_max_kmh"`
+	UVIndexMax               float64 `json:"uv_index_max"`
+}
+
+// HourlyWeather 一小时的预报
+type HourlyWeather struct {
+	Time        string  `json:"time"`
+	Weather     string  `json:"weather"`
+	WeatherCode int     `json:"weather_code"`
+	TempC       float64 `json:"temp_c"`
+	// PrecipitationProbability 降水概率（%）
+	PrecipitationProbability float64 `json:"precipitation_probability"`
+	WindSpeedKMH             float64 `json:"wind_speed_kmh"`
+	UVIndex                  float64 `json:"uv_index"`
+}
+
+// WeatherForecast 查询指定城市未来几天（可选逐小时）天气预报的 MCP tool handler，
+// 返回精简的文字说明与 ForecastResult 结构化结果；地名有歧义时返回候选列表
+func WeatherForecast(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	days := req.GetInt("days", defaultForecastDays)
+	if days < 1 || days > maxForecastDays {
+		return mcp.NewToolResultError(fmt.Sprintf("days 取值范围 1~%d，当前为 %d", maxForecastDays, days)), nil
+	}
+	hours := req.GetInt("hours", 0)
+	if hours < 0 || hours > maxForecastHours {
+		return mcp.NewToolResultError(fmt.Sprintf("hours 取值范围 0~%d，当前为 %d", maxForecastHours, hours)), nil
+	}
+
+	r, ambiguous, err := resolvePlace(ctx, req)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	if ambiguous != nil {
+		return mcp.NewToolResultStructured(ForecastResult{City: ambiguous.Query, Ambiguous: ambiguous}, ambiguous.text()), nil
+	}
+
+	daily, err := weatherProvider.GetDailyForecast(ctx, r.Latitude, r.Longitude, days)
+	if err != nil {
+		return mcp.NewToolResultError("天气预报查询: " + err.Error()), nil
+	}
+	result := ForecastResult{
+		City:      r.DisplayName,
+		Latitude:  r.Latitude,
+		Longitude: r.Longitude,
+		Timezone:  daily.Timezone,
+		Daily:     dailyWeather(daily.Daily),
+	}
+	if hours > 0 {
+		hourly, err := weatherProvider.GetHourlyForecast(ctx, r.Latitude, r.Longitude, hours)
+		if err != nil {
+			return mcp.NewToolResultError("逐小时预报查询: " + err.Error()), nil
+		}
+		result.Hourly = hourlyWeather(hourly.Hourly)
+	}
+	return mcp.NewToolResultStructured(result, result.text()), nil
+}
+
+// text 精简的文字说明，每天 / 每小时一行
+func (f ForecastResult) text() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "城市: %s，经纬度: (%.3f, %.3f)", f.City, f.Latitude, f.Longitude)
+	if f.Timezone != "" {
+		fmt.Fprintf(&b, "，时区: %s", f.Timezone)
+	}
+	b.WriteString("\n")
+	fmt.Fprintf(&b, "未来 %d 天：\n", len(f.Daily))
+	for _, d := range f.Daily {
+		fmt.Fprintf(&b, "%s %s %.1f~%.1f°C，降水概率 %.0f%%，降水 %.1fmm，最大风速 %.1fkm/h，紫外线指数 %.1f\n",
+			d.Date, d.Weather, d.TempMinC, d.TempMaxC, d.PrecipitationProbability, d.PrecipitationMM, d.WindSpeedMaxKMH, d.UVIndexMax)
+	}
+	if len(f.Hourly) > 0 {
+		fmt.Fprintf(&b, "逐时预报（%d 个时段）：\n", len(f.Hourly))
+		for _, h := range f.Hourly {
+			fmt.Fprintf(&b, "%s %s %.1f°C，降水概率 %.0f%%，风速 %.1fkm/h，紫外线指数 %.1f\n",
+				strings.Replace(h.Time, "T", " ", 1), h.Weather, h.TempC, h.PrecipitationProbability, h.WindSpeedKMH, h.UVIndex)
+		}
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+func dailyWeather(d *client.DailyForecast) []DailyWeather {
+	if d == nil {
+		return nil
+	}
+	out := make([]DailyWeather, 0, len(d.Time))
+	for i, date := range d.Time {
+		code := at(d.WeatherCode, i)
+		out = append(out, DailyWeather{
+			Date:                     date,
+			Weather:                  client.WeatherCodeToDesc(code),
+			WeatherCode:              code,
+			TempMinC:                 at(d.Temperature2mMin, i),
+			TempMaxC:                 at(d.Temperature2mMax, i),
+			PrecipitationProbability: at(d.PrecipitationProbabilityMax, i),
+			PrecipitationMM:          at(d.PrecipitationSum, i),
+			WindSpeedMaxKMH:          at(d.WindSpeed10mMax, i),
+			UVIndexMax:               at(d.UVIndexMax, i),
+		})
+	}
+	return out
+}
+
+func hourlyWeather(h *client.HourlyForecast) []HourlyWeather {
+	if h == nil {
+		return nil
+	}
+	out := make([]HourlyWeather, 0, len(h.Time))
+	for i, t := range h.Time {
+		code := at(h.WeatherCode, i)
+		out = append(out, HourlyWeather{
+			Time:                     t,
+			Weather:                  client.WeatherCodeToDesc(code),
+			WeatherCode:              code,
+			TempC:                    at(h.Temperature2m, i),
+			PrecipitationProbability: at(h.PrecipitationProbability, i),
+			WindSpeedKMH:             at(h.WindSpeed10m, i),
+			UVIndex:                  at(h.UVIndex, i),
+		})
+	}
+	return out
+}
+
+// at 取 s[i]，越界时返回零值（Open-Meteo 个别变量可能缺失）
+func at[T any](s []T, i int) T {
+	var zero T
+	if i < 0 || i >= len(s) {
+		return zero
+	}
+	return s[i]
+}
@@ -0,0 +1,89 @@
+package handlers_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aistudiolabx/eino-demo/backend/mcp_server/handlers"
+)
+
+func TestWeatherForecast(t *testing.T) {
+	cli := newClient(t, nil)
+
+	res, text := callTool(t, cli, "weather_forecast", map[string]any{"city": "Beijing", "hours": 4})
+	if res.IsError {
+		t.Fatalf("weather_forecast error: %s", text)
+	}
+	f := structured[handlers.ForecastResult](t, res)
+	if f.City != "Beijing, 北京市, 中国" || f.Timezone != "Asia/Shanghai" || f.Ambiguous != nil {
+		t.Errorf("result = %+v, want Beijing in Asia/Shanghai", f)
+	}
+	// 默认 3 天；桩服务第 i 天最高气温为 21.5+i、最低低 6 度
+	if len(f.Daily) != 3 {
+		t.Fatalf("daily = %d days, want 3", len(f.Daily))
+	}
+	want := handlers.DailyWeather{
+		Date: "2025-01-02", Weather: "晴朗", WeatherCode: 0, TempMinC: 16.5, TempMaxC: 22.5,
+		PrecipitationProbability: 10, PrecipitationMM: 1, WindSpeedMaxKMH: 11, UVIndexMax: 5,
+	}
+	if f.Daily[1] != want {
+		t.Errorf("day 2 = %+v, want %+v", f.Daily[1], want)
+	}
+	if len(f.Hourly) != 4 || f.Hourly[3].Time != "2025-01-01T03:00" || f.Hourly[3].PrecipitationProbability != 15 {
+		t.Errorf("hourly = %+v, want 4 hours from 2025-01-01T00:00", f.Hourly)
+	}
+	for _, line := range []string{
+		"城市: Beijing, 北京市, 中国，经纬度: (39.907, 116.397)，时区: Asia/Shanghai",
+		"未来 3 天：",
+		"2025-01-02 晴朗 16.5~22.5°C，降水概率 10%，降水 1.0mm，最大风速 11.0km/h，紫外线指数 5.0",
+		"逐时预报（4 个时段）：",
+		"2025-01-01 03:00 晴朗 21.5°C，降水概率 15%，风速 8.0km/h，紫外线指数 1.0",
+	} {
+		if !strings.Contains(text, line+"\n") && !strings.HasSuffix(text, line) {
+			t.Errorf("text missing line %q:\n%s", line, text)
+		}
+	}
+}
+
+func TestWeatherForecastBounds(t *testing.T) {
+	cli := newClient(t, nil)
+	for _, tc := range []struct {
+		args    map[string]any
+		days    int
+		hours   int
+		wantErr string
+	}{
+		{args: map[string]any{"days": 1}, days: 1},
+		{args: map[string]any{"days": 16, "hours": 72}, days: 16, hours: 72},
+		{args: map[string]any{"days": 2, "hours": 0}, days: 2},
+		{args: map[string]any{"days": 0}, wantErr: "days 取值范围 1~16，当前为 0"},
+		{args: map[string]any{"days": 17}, wantErr: "days 取值范围 1~16，当前为 17"},
+		{args: map[string]any{"hours": -1}, wantErr: "hours 取值范围 0~72，当前为 -1"},
+		{args: map[string]any{"hours": 73}, wantErr: "hours 取值范围 0~72，当前为 73"},
+	} {
+		tc.args["city"] = "Shenzhen"
+		res, text := callTool(t, cli, "weather_forecast", tc.args)
+		if tc.wantErr != "" {
+			if !res.IsError || text != tc.wantErr {
+				t.Errorf("%v: result = %q (error %v), want error %q", tc.args, text, res.IsError, tc.wantErr)
+			}
+			continue
+		}
+		if res.IsError {
+			t.Errorf("%v: error %s", tc.args, text)
+			continue
+		}
+		f := structured[handlers.ForecastResult](t, res)
+		if len(f.Daily) != tc.days || len(f.Hourly) != tc.hours {
+			t.Errorf("%v: %d days, %d hours, want %d and %d", tc.args, len(f.Daily), len(f.Hourly), tc.days, tc.hours)
+		}
+	}
+}
+
+func TestWeatherForecastUnknownCity(t *testing.T) {
+	cli := newClient(t, nil)
+	res, text := callTool(t, cli, "weather_forecast", map[string]any{"city": "Atlantis"})
+	if !res.IsError || text != "未找到该城市的地理信息" {
+		t.Errorf("result = %q (error %v), want unknown city error", text, res.IsError)
+	}
+}
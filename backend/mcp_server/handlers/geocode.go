@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aistudiolabx/eino-demo/backend/mcp_server/client"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// 地名解析：Open-Meteo 按相关度返回多个同名地点（Springfield、朝阳……），
+// 先按 country / region 参数过滤，剩下多个且人口最多的一个不够突出时，
+// 不再默认取第一个，而是返回候选列表，由模型向用户确认或带上过滤参数重新调用。
+const (
+	// geocodeCandidates 每次地理编码请求的候选数量
+	geocodeCandidates = 10
+	// dominanceRatio 人口最多的候选至少是第二名的多少倍才直接选中
+	dominanceRatio = 5
+	// maxListedCandidates 歧义结果中最多列出的候选数
+	maxListedCandidates = 5
+)
+
+// Disambiguation 地名有歧义时的结构化结果
+type Disambiguation struct {
+	// Query 用户给出的地名
+	Query string `json:"query"`
+	// Candidates 候选地点，人口多的在前
+	Candidates []client.GeocodeResult `json:"candidates"`
+}
+
+// text 歧义结果的文字说明，提示模型如何继续
+func (d *Disambiguation) text() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "「%s」匹配到 %d 个地点，请向用户确认是哪一个，或带上 country / region 参数重新查询：\n", d.Query, len(d.Candidates))
+	for i, c := range d.Candidates {
+		fmt.Fprintf(&b, "%d. %s", i+1, placeName(c))
+		if c.CountryCode != "" {
+			fmt.Fprintf(&b, "（country=%s", c.CountryCode)
+			if c.Admin1 != "" {
+				fmt.Fprintf(&b, "，region=%s", c.Admin1)
+			}
+			b.WriteString("）")
+		}
+		fmt.Fprintf(&b, "，经纬度: (%.3f, %.3f)", c.Latitude, c.Longitude)
+		if c.Population > 0 {
+			fmt.Fprintf(&b, "，人口: %d", c.Population)
+		}
+		if c.Timezone != "" {
+			fmt.Fprintf(&b, "，时区: %s", c.Timezone)
+		}
+		b.WriteString("\n")
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// place 解析出的唯一地点
+type place struct {
+	client.GeocodeResult
+	// DisplayName 展示用的名字，如 “朝阳区, 北京市, 中国”
+	DisplayName string
+}
+
+// resolvePlace 按工具参数 city（必填）、country、region（可选）解析地点。
+// 地名有歧义时返回 Disambiguation；error 可直接作为工具错误信息
+func resolvePlace(ctx context.Context, req mcp.CallToolRequest) (*place, *Disambiguation, error) {
+	city, err := req.RequireString("city")
+	if err != nil {
+		return nil, nil, err
+	}
+	country := strings.TrimSpace(req.GetString("country", ""))
+	region := strings.TrimSpace(req.GetString("region", ""))
+
+	geo, err := weatherProvider.Geocode(ctx, city, geocodeCandidates)
+	if err != nil {
+		return nil, nil, fmt.Errorf("地理编码: %w", err)
+	}
+	if len(geo.Results) == 0 {
+		return nil, nil, errors.New("未找到该城市的地理信息")
+	}
+	candidates := filterPlaces(geo.Results, country, region)
+	if len(candidates) == 0 {
+		return nil, nil, fmt.Errorf("未找到位于 %s 的「%s」，可去掉 country / region 参数查看全部候选",
+			strings.Trim(country+" "+region, " "), city)
+	}
+	r, ok := pickPlace(candidates)
+	if !ok {
+		if len(candidates) > maxListedCandidates {
+			candidates = candidates[:maxListedCandidates]
+		}
+		return nil, &Disambiguation{Query: city, Candidates: candidates}, nil
+	}
+	return &place{GeocodeResult: r, DisplayName: placeName(r)}, nil, nil
+}
+
+// filterPlaces 按国家（代码或名称）与地区（一级或二级行政区）过滤，不区分大小写，名称按包含匹配
+func filterPlaces(results []client.GeocodeResult, country, region string) []client.GeocodeResult {
+	if country == "" && region == "" {
+		return results
+	}
+	var out []client.GeocodeResult
+	for _, r := range results {
+		if country != "" && !strings.EqualFold(r.CountryCode, country) && !containsFold(r.Country, country) {
+			continue
+		}
+		if region != "" && !containsFold(r.Admin1, region) && !containsFold(r.Admin2, region) {
+			continue
+		}
+		out = append(out, r)
+	}
+	return out
+}
+
+// pickPlace 只有一个候选，或人口最多的候选至少是第二名的 dominanceRatio 倍时选中它；
+// 否则返回 false，候选按人口从多到少重排（人口相同保持原有的相关度顺序）
+func pickPlace(candidates []client.GeocodeResult) (client.GeocodeResult, bool) {
+	if len(candidates) == 1 {
+		return candidates[0], true
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Population > candidates[j].Population
+	})
+	first, second := candidates[0].Population, candidates[1].Population
+	if first > 0 && first >= second*dominanceRatio {
+		return candidates[0], true
+	}
+	return client.GeocodeResult{}, false
+}
+
+// placeName 名字、一级行政区、国家，去掉空值与重复（如 “Beijing, Beijing”）
+func placeName(r client.GeocodeResult) string {
+	parts := []string{r.Name}
+	for _, p := range []string{r.Admin1, r.Country} {
+		if p != "" && p != parts[len(parts)-1] {
+			parts = append(parts, p)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+func containsFold(s, substr string) bool {
+	return s != "" && strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aistudiolabx/eino-demo/backend/mcp_server/client"
+)
+
+// names 候选地点的名字，按顺序以逗号连接
+func names(results []client.GeocodeResult) string {
+	var out []string
+	for _, r := range results {
+		out = append(out, r.Name)
+	}
+	return strings.Join(out, ",")
+}
+
+func TestFilterPlaces(t *testing.T) {
+	results := []client.GeocodeResult{
+		{Name: "朝阳区", CountryCode: "CN", Country: "中国", Admin1: "北京市"},
+		{Name: "朝阳市", CountryCode: "CN", Country: "中国", Admin1: "辽宁省"},
+		{Name: "朝阳县", CountryCode: "CN", Country: "中国", Admin1: "辽宁省", Admin2: "朝阳市"},
+		{Name: "Springfield", CountryCode: "US", Country: "United States", Admin1: "Illinois", Admin2: "Sangamon"},
+		{Name: "Springfield", CountryCode: "AU", Country: "Australia", Admin1: "Queensland"},
+	}
+	for _, tc := range []struct {
+		country, region string
+		want            string
+	}{
+		{"", "", "朝阳区,朝阳市,朝阳县,Springfield,Springfield"},
+		{"cn", "", "朝阳区,朝阳市,朝阳县"},
+		{"中国", "辽宁", "朝阳市,朝阳县"},
+		{"", "北京", "朝阳区"},
+		// 地区也匹配二级行政区
+		{"", "朝阳市", "朝阳县"},
+		{"", "sangamon", "Springfield"},
+		{"united", "illinois", "Springfield"},
+		{"AU", "", "Springfield"},
+		// 国家代码按全等匹配，"U" 不匹配 US / AU，只按名称包含匹配
+		{"U", "", "Springfield,Springfield"},
+		{"JP", "", ""},
+		{"CN", "Queensland", ""},
+	} {
+		got := names(filterPlaces(results, tc.country, tc.region))
+		if got != tc.want {
+			t.Errorf("filterPlaces(country %q, region %q) = %q, want %q", tc.country, tc.region, got, tc.want)
+		}
+	}
+}
+
+func TestPickPlace(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		populations []int
+		picked      string
+		order       string
+	}{
+		{name: "single", populations: []int{0}, picked: "p0"},
+		{name: "dominant", populations: []int{100, 500, 20}, picked: "p1"},
+		{name: "exactly 5x", populations: []int{100, 500}, picked: "p1"},
+		{name: "below 5x", populations: []int{101, 500}, order: "p1,p0"},
+		{name: "only first populated", populations: []int{0, 1}, picked: "p1"},
+		{name: "no population", populations: []int{0, 0, 0}, order: "p0,p1,p2"},
+		// 人口相同时保持相关度顺序
+		{name: "ties stable", populations: []int{10, 30, 10, 30}, order: "p1,p3,p0,p2"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var candidates []client.GeocodeResult
+			for i, p := range tc.populations {
+				candidates = append(candidates, client.GeocodeResult{Name: "p" + string(rune('0'+i)), Population: p})
+			}
+			r, ok := pickPlace(candidates)
+			if tc.picked != "" {
+				if !ok || r.Name != tc.picked {
+					t.Errorf("pickPlace = %q, %v, want %q", r.Name, ok, tc.picked)
+				}
+				return
+			}
+			if ok {
+				t.Fatalf("pickPlace picked %q, want ambiguous", r.Name)
+			}
+			if got := names(candidates); got != tc.order {
+				t.Errorf("candidates reordered to %q, want %q", got, tc.order)
+			}
+		})
+	}
+}
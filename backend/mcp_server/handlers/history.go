@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/aistudiolabx/eino-demo/backend/mcp_server/client"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// maxHistoryDays 一次最多查询的天数，保持结果精简
+const maxHistoryDays = 31
+
+// archiveStart Open-Meteo 历史数据的最早日期
+var archiveStart = time.Date(1940, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// HistoryResult weather_history 的结构化结果，日期为城市当地日期。
+// 地名有歧义时只有 City（用户给出的地名）与 Ambiguous
+type HistoryResult struct {
+	City      string  `json:"city"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Timezone  string  `json:"timezone"`
+	StartDate string  `json:"start_date,omitempty"`
+	EndDate   string  `json:"end_date,omitempty"`
+	// Days 逐日汇总，归档数据尚未入库的日期只有 Date
+	Days []HistoryDay `json:"days,omitempty"`
+	// Ambiguous 地名匹配到多个地点时的候选列表
+	Ambiguous *Disambiguation `json:"ambiguous,omitempty"`
+}
+
+// HistoryDay 一天的历史天气汇总
+type HistoryDay struct {
+	Date        string   `json:"date"`
+	Weather     string   `json:"weather,omitempty"`
+	WeatherCode *int     `json:"weather_code,omitempty"`
+	TempMinC    *float64 `json:"temp_min_c,omitempty"`
+	TempMaxC    *float64 `json:"temp_max_c,omitempty"`
+	TempMeanC   *float64 `json:"temp_mean_c,omitempty"`
+	// PrecipitationMM 当天降水总量，PrecipitationHours 有降水的小时数
+	PrecipitationMM    *float64 `json:"precipitation_mm,omitempty"`
+	PrecipitationHours *float64 `json:"precipitation_hours,omitempty"`
+	WindSpeedMaxKMH    *float64 `json:"wind_speed_max_kmh,omitempty"`
+}
+
+// WeatherHistory 查询指定城市某天或某段日期历史天气的 MCP tool handler。
+// date 与 start_date / end_date 二选一，可以是 YYYY-MM-DD 或“昨天”“上周六”等短语，按城市时区换算
+func WeatherHistory(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	date := req.GetString("date", "")
+	startArg := req.GetString("start_date", "")
+	endArg := req.GetString("end_date", "")
+	if date == "" && startArg == "" {
+		return mcp.NewToolResultError("需要 date，或 start_date（可选 end_date）"), nil
+	}
+
+	p, ambiguous, err := resolvePlace(ctx, req)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	if ambiguous != nil {
+		return mcp.NewToolResultStructured(HistoryResult{City: ambiguous.Query, Ambiguous: ambiguous}, ambiguous.text()), nil
+	}
+
+	start, end, err := historyRange(date, startArg, endArg, localToday(p.Timezone))
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	h, err := openMeteoClient.GetDailyHistory(ctx, p.Latitude, p.Longitude, start.Format(dateLayout), end.Format(dateLayout))
+	if err != nil {
+		return mcp.NewToolResultError("历史天气查询: " + err.Error()), nil
+	}
+	result := HistoryResult{
+		City:      p.DisplayName,
+		Latitude:  p.Latitude,
+		Longitude: p.Longitude,
+		Timezone:  h.Timezone,
+		StartDate: start.Format(dateLayout),
+		EndDate:   end.Format(dateLayout),
+		Days:      historyDays(&h.Daily),
+	}
+	return mcp.NewToolResultStructured(result, result.text()), nil
+}
+
+// historyRange 由工具参数得到含两端的日期范围，并检查不晚于今天、不早于 1940 年、不超过 maxHistoryDays 天
+func historyRange(date, startArg, endArg string, today time.Time) (start, end time.Time, err error) {
+	if date != "" {
+		start, end, err = parseDates(date, today)
+	} else {
+		start, end, err = parseDates(startArg, today)
+		if err == nil && endArg != "" {
+			_, end, err = parseDates(endArg, today)
+		}
+	}
+	if err != nil {
+		return start, end, err
+	}
+	switch {
+	case end.Before(start):
+		return start, end, fmt.Errorf("结束日期 %s 早于开始日期 %s", end.Format(dateLayout), start.Format(dateLayout))
+	case end.After(today):
+		return start, end, fmt.Errorf("%s 晚于当地今天 %s，未来的天气请使用 weather_forecast", end.Format(dateLayout), today.Format(dateLayout))
+	case start.Before(archiveStart):
+		return start, end, errors.New("历史数据从 1940-01-01 开始")
+	case int(end.Sub(start).Hours()/24)+1 > maxHistoryDays:
+		return start, end, fmt.Errorf("一次最多查询 %d 天，当前为 %s~%s", maxHistoryDays, start.Format(dateLayout), end.Format(dateLayout))
+	}
+	return start, end, nil
+}
+
+// text 精简的文字说明，每天一行；多天时附带整段的汇总
+func (h HistoryResult) text() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "城市: %s，经纬度: (%.3f, %.3f)，时区: %s\n", h.City, h.Latitude, h.Longitude, h.Timezone)
+	fmt.Fprintf(&b, "%s~%s 历史天气：\n", h.StartDate, h.EndDate)
+	lo, hi, precip := math.Inf(1), math.Inf(-1), 0.0
+	rainy, known, missing := 0, 0, 0
+	for _, d := range h.Days {
+		if d.TempMaxC == nil || d.TempMinC == nil {
+			missing++
+			fmt.Fprintf(&b, "%s 暂无数据\n", d.Date)
+			continue
+		}
+		known++
+		lo, hi = math.Min(lo, *d.TempMinC), math.Max(hi, *d.TempMaxC)
+		fmt.Fprintf(&b, "%s %s %.1f~%.1f°C", d.Date, d.Weather, *d.TempMinC, *d.TempMaxC)
+		if d.TempMeanC != nil {
+			fmt.Fprintf(&b, "（平均 %.1f°C）", *d.TempMeanC)
+		}
+		if d.PrecipitationMM != nil {
+			precip += *d.PrecipitationMM
+			if *d.PrecipitationMM >= 0.1 {
+				rainy++
+			}
+			fmt.Fprintf(&b, "，降水 %.1fmm", *d.PrecipitationMM)
+		}
+		if d.WindSpeedMaxKMH != nil {
+			fmt.Fprintf(&b, "，最大风速 %.1fkm/h", *d.WindSpeedMaxKMH)
+		}
+		b.WriteString("\n")
+	}
+	if known > 1 {
+		fmt.Fprintf(&b, "汇总：最低 %.1f°C，最高 %.1f°C，总降水 %.1fmm，%d 天有降水\n", lo, hi, precip, rainy)
+	}
+	if missing > 0 {
+		b.WriteString("注：历史数据通常滞后数天入库，最近几天可能暂无数据，当前天气可使用 weather 查询\n")
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+func historyDays(d *client.DailyHistory) []HistoryDay {
+	out := make([]HistoryDay, 0, len(d.Time))
+	for i, date := range d.Time {
+		day := HistoryDay{
+			Date:               date,
+			WeatherCode:        at(d.WeatherCode, i),
+			TempMinC:           at(d.Temperature2mMin, i),
+			TempMaxC:           at(d.Temperature2mMax, i),
+			TempMeanC:          at(d.Temperature2mMean, i),
+			PrecipitationMM:    at(d.PrecipitationSum, i),
+			PrecipitationHours: at(d.PrecipitationHours, i),
+			WindSpeedMaxKMH:    at(d.WindSpeed10mMax, i),
+		}
+		if day.WeatherCode != nil {
+			day.Weather = client.WeatherCodeToDesc(*day.WeatherCode)
+		}
+		out = append(out, day)
+	}
+	return out
+}
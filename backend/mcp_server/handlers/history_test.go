@@ -0,0 +1,56 @@
+package handlers_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aistudiolabx/eino-demo/backend/mcp_server/handlers"
+)
+
+func TestWeatherHistory(t *testing.T) {
+	cli := newClient(t, nil)
+
+	res, text := callTool(t, cli, "weather_history", map[string]any{"city": "Beijing", "start_date": "2024-12-01", "end_date": "2024-12-07"})
+	if res.IsError {
+		t.Fatalf("weather_history error: %s", text)
+	}
+	h := structured[handlers.HistoryResult](t, res)
+	if h.City != "Beijing, 北京市, 中国" || h.StartDate != "2024-12-01" || h.EndDate != "2024-12-07" || len(h.Days) != 7 {
+		t.Fatalf("result = %+v, want 7 days of Beijing", h)
+	}
+	// 桩服务逢 3 的倍数日有降水
+	d := h.Days[2]
+	if d.Date != "2024-12-03" || d.Weather != "降雨" || *d.TempMaxC != 24.5 || *d.TempMinC != 18.5 || *d.PrecipitationMM != 2.5 || *d.PrecipitationHours != 3 {
+		t.Errorf("2024-12-03 = %+v", d)
+	}
+	for _, want := range []string{
+		"城市: Beijing, 北京市, 中国，经纬度: (39.907, 116.397)，时区: Asia/Shanghai\n2024-12-01~2024-12-07 历史天气：",
+		"2024-12-03 降雨 18.5~24.5°C（平均 21.5°C），降水 2.5mm，最大风速 12.0km/h\n",
+		"汇总：最低 10.5°C，最高 26.5°C，总降水 5.0mm，2 天有降水",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("text missing %q:\n%s", want, text)
+		}
+	}
+}
+
+func TestWeatherHistoryErrors(t *testing.T) {
+	cli := newClient(t, nil)
+	for _, tc := range []struct {
+		args    map[string]any
+		wantErr string
+	}{
+		{args: map[string]any{}, wantErr: "需要 date，或 start_date（可选 end_date）"},
+		{args: map[string]any{"end_date": "2024-12-01"}, wantErr: "需要 date"},
+		{args: map[string]any{"date": "2999-01-01"}, wantErr: "2999-01-01 晚于当地今天"},
+		{args: map[string]any{"start_date": "2024-01-01", "end_date": "2024-02-01"}, wantErr: "一次最多查询 31 天"},
+		{args: map[string]any{"date": "1939-12-31"}, wantErr: "历史数据从 1940-01-01 开始"},
+		{args: map[string]any{"date": "下周"}, wantErr: "无法识别的日期"},
+	} {
+		tc.args["city"] = "Shanghai"
+		res, text := callTool(t, cli, "weather_history", tc.args)
+		if !res.IsError || !strings.Contains(text, tc.wantErr) {
+			t.Errorf("%v: result = %q (error %v), want error %q", tc.args, text, res.IsError, tc.wantErr)
+		}
+	}
+}
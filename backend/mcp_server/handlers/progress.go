@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"context"
+	"log"
+
+	"github.com/aistudiolabx/eino-demo/backend/mcpext"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// reportProgress 向发起调用的客户端发送 notifications/progress；请求未带 progressToken 时不发送。
+// 进度只是提示信息，发送失败不影响工具本身的执行
+func reportProgress(ctx context.Context, req mcp.CallToolRequest, progress float64, message string) {
+	if req.Params.Meta == nil || req.Params.Meta.ProgressToken == nil {
+		return
+	}
+	srv := server.ServerFromContext(ctx)
+	if srv == nil {
+		return
+	}
+	err := srv.SendNotificationToClient(ctx, mcpext.ProgressNotification, map[string]any{
+		"progressToken": req.Params.Meta.ProgressToken,
+		"progress":      progress,
+		"message":       message,
+	})
+	if err != nil {
+		log.Printf("send progress for %s error: %v", req.Params.Name, err)
+	}
+}
@@ -4,7 +4,9 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"strings"
 
+	"github.com/aistudiolabx/eino-demo/backend/config"
 	"github.com/aistudiolabx/eino-demo/backend/mcp_server/client"
 	"github.com/mark3labs/mcp-go/mcp"
 )
@@ -13,16 +15,32 @@ const runningHubAPIKeyEnv = "RUNNINGHUB_API_KEY"
 
 var runningHubClient = client.NewRunningHubClient()
 
+// runningHubAPIKey 由 ConfigureRunningHub 设置；为空时回退读取环境变量
+var runningHubAPIKey string
+
 func getRunningHubAPIKey() (string, error) {
-	apiKey := os.Getenv(runningHubAPIKeyEnv)
+	apiKey := runningHubAPIKey
+	if apiKey == "" {
+		apiKey = os.Getenv(runningHubAPIKeyEnv)
+	}
 	if apiKey == "" {
-		return "", fmt.Errorf("未配置环境变量 %s", runningHubAPIKeyEnv)
+		return "", fmt.Errorf("未配置 RunningHub API Key（mcp.runninghub.api_key 或环境变量 %s）", runningHubAPIKeyEnv)
 	}
 	return apiKey, nil
 }
 
-// 小说转剧本工作流 ID（RunningHub）
-const novelToScriptWorkflowID = "2014935539987783681"
+// 小说转剧本工作流 ID（RunningHub），可由 ConfigureRunningHub 覆盖
+var novelToScriptWorkflowID = "2014935539987783681"
+
+// ConfigureRunningHub 按配置设置 RunningHub 客户端与工作流参数，需在 server 启动前调用
+func ConfigureRunningHub(cfg config.RunningHubConfig) {
+	runningHubClient.BaseURL = strings.TrimRight(cfg.BaseURL, "/")
+	runningHubClient.HTTPClient.Timeout = cfg.RequestTimeout
+	runningHubClient.PollInterval = cfg.PollInterval
+	runningHubClient.RunTimeout = cfg.RunTimeout
+	runningHubAPIKey = cfg.APIKey
+	novelToScriptWorkflowID = cfg.NovelToScriptWorkflowID
+}
 
 // 小说转剧本工作流节点：节点 8 为文本输入，节点 6 为 seed（可选）
 const novelToScriptNodeText = "8"
@@ -46,12 +64,14 @@ func NovelToScript(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolR
 			NodeID: novelToScriptNodeSeed, FieldName: "seed", FieldValue: seed,
 		})
 	}
-	outputs, err := runningHubClient.RunWorkflow(ctx, apiKey, novelToScriptWorkflowID, nodeInfoList)
+	outputs, err := runningHubClient.RunWorkflow(ctx, apiKey, novelToScriptWorkflowID, nodeInfoList, func(polls int, status string) {
+		reportProgress(ctx, req, float64(polls), fmt.Sprintf("RunningHub 任务状态：%s（第 %d 次查询）", status, polls))
+	})
 	if err != nil {
 		return mcp.NewToolResultError(err.Error()), nil
 	}
 	// 解析 outputs 中的 fileUrl，下载 txt 文件内容并作为返回
-	content, err := runningHubClient.FetchOutputTextContent(outputs)
+	content, err := runningHubClient.FetchOutputTextContent(ctx, outputs)
 	if err != nil {
 		return mcp.NewToolResultError("下载输出文件失败: " + err.Error()), nil
 	}
@@ -0,0 +1,56 @@
+package handlers_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/aistudiolabx/eino-demo/backend/mcp_server/mcptest"
+	mcpclient "github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// 工具测试经 mcptest 的进程内客户端调用，与 agent 看到的结果一致；夹具改写包级客户端，测试不能并行
+
+// newClient 启动 mcptest 夹具并返回已初始化的客户端，测试结束时关闭
+func newClient(t *testing.T, up *mcptest.Upstream) *mcpclient.Client {
+	t.Helper()
+	srv := mcptest.NewServer(up)
+	t.Cleanup(srv.Close)
+	cli, err := srv.Client(context.Background())
+	if err != nil {
+		t.Fatalf("mcptest client: %v", err)
+	}
+	t.Cleanup(func() { _ = cli.Close() })
+	return cli
+}
+
+// callTool 调用工具，返回结果与第一段文字内容
+func callTool(t *testing.T, cli *mcpclient.Client, name string, args map[string]any) (*mcp.CallToolResult, string) {
+	t.Helper()
+	req := mcp.CallToolRequest{}
+	req.Params.Name = name
+	req.Params.Arguments = args
+	res, err := cli.CallTool(context.Background(), req)
+	if err != nil {
+		t.Fatalf("call %s: %v", name, err)
+	}
+	if len(res.Content) == 0 {
+		t.Fatalf("call %s: empty content", name)
+	}
+	return res, mcp.GetTextFromContent(res.Content[0])
+}
+
+// structured 把结构化结果解码为 T；客户端收到的是 JSON 解码后的 map
+func structured[T any](t *testing.T, res *mcp.CallToolResult) T {
+	t.Helper()
+	var out T
+	raw, err := json.Marshal(res.StructuredContent)
+	if err != nil {
+		t.Fatalf("marshal structured content: %v", err)
+	}
+	if err := json.Unmarshal(raw, &out); err != nil {
+		t.Fatalf("decode structured content %s: %v", raw, err)
+	}
+	return out
+}
@@ -4,41 +4,63 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/aistudiolabx/eino-demo/backend/config"
 	"github.com/aistudiolabx/eino-demo/backend/mcp_server/client"
 	"github.com/mark3labs/mcp-go/mcp"
 )
 
-var openMeteoClient = client.NewOpenMeteoClient()
+var (
+	openMeteoClient = client.NewOpenMeteoClient()
+	wttrClient      = client.NewWttrClient()
+	// weatherProvider 地理编码、当前天气与预报使用的数据源，由 ConfigureWeather 按配置组合；
+	// 空气质量与历史天气直接使用 openMeteoClient
+	weatherProvider client.WeatherProvider = openMeteoClient
+)
 
-// Weather 查询指定城市当前天气的 MCP tool handler
-func Weather(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	city, err := req.RequireString("city")
-	if err != nil {
-		return mcp.NewToolResultError(err.Error()), nil
-	}
+// ConfigureOpenMeteo 按配置设置 Open-Meteo 接口地址与超时，需在 server 启动前调用
+func ConfigureOpenMeteo(cfg config.OpenMeteoConfig) {
+	openMeteoClient.GeocodeURL = cfg.GeocodeURL
+	openMeteoClient.ForecastURL = cfg.ForecastURL
+	openMeteoClient.AirQualityURL = cfg.AirQualityURL
+	openMeteoClient.ArchiveURL = cfg.ArchiveURL
+	openMeteoClient.HTTPClient.Timeout = cfg.RequestTimeout
+}
 
-	geo, err := openMeteoClient.Geocode(city)
-	if err != nil {
-		return mcp.NewToolResultError("地理编码: " + err.Error()), nil
+// ConfigureWeather 按配置设置备用数据源并组合为按顺序切换的 weatherProvider，需在 server 启动前调用
+func ConfigureWeather(cfg config.WeatherConfig) {
+	wttrClient.BaseURL = cfg.Wttr.BaseURL
+	wttrClient.HTTPClient.Timeout = cfg.Wttr.RequestTimeout
+	available := map[string]client.WeatherProvider{
+		openMeteoClient.Name(): openMeteoClient,
+		wttrClient.Name():      wttrClient,
 	}
-	if len(geo.Results) == 0 {
-		return mcp.NewToolResultError("未找到该城市的地理信息"), nil
+	var providers []client.WeatherProvider
+	for _, name := range cfg.Providers {
+		if p, ok := available[name]; ok {
+			providers = append(providers, p)
+		}
 	}
+	weatherProvider = client.NewFailoverProvider(cfg.ProviderTimeout, providers...)
+}
 
-	r := geo.Results[0]
-	lat, lon := r.Latitude, r.Longitude
-	displayName := r.Name
-	if r.Country != "" {
-		displayName = fmt.Sprintf("%s, %s", r.Name, r.Country)
+// Weather 查询指定城市当前天气的 MCP tool handler；地名有歧义时返回候选列表而不是天气
+func Weather(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	p, ambiguous, err := resolvePlace(ctx, req)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	if ambiguous != nil {
+		return mcp.NewToolResultStructured(ambiguous, ambiguous.text()), nil
 	}
+	lat, lon := p.Latitude, p.Longitude
 
-	w, err := openMeteoClient.GetWeather(lat, lon)
+	w, err := weatherProvider.GetWeather(ctx, lat, lon)
 	if err != nil {
 		return mcp.NewToolResultError("天气查询: " + err.Error()), nil
 	}
 
 	desc := client.WeatherCodeToDesc(w.Current.WeatherCode)
 	result := fmt.Sprintf("城市: %s，经纬度: (%.3f, %.3f)，温度: %.1f°C，天气: %s",
-		displayName, lat, lon, w.Current.Temperature2m, desc)
+		p.DisplayName, lat, lon, w.Current.Temperature2m, desc)
 	return mcp.NewToolResultText(result), nil
 }
@@ -1,27 +1,90 @@
 package main
 
 import (
+	"flag"
 	"log"
+	"net/http"
 
+	"github.com/aistudiolabx/eino-demo/backend/config"
+	"github.com/aistudiolabx/eino-demo/backend/mcp_server/handlers"
 	"github.com/aistudiolabx/eino-demo/backend/mcp_server/tools"
+	"github.com/aistudiolabx/eino-demo/backend/mcpext"
+	"github.com/aistudiolabx/eino-demo/backend/metrics"
 	"github.com/mark3labs/mcp-go/server"
 )
 
 func main() {
-	s := server.NewMCPServer("weather_agent", "1.0.0", server.WithToolCapabilities(false))
+	configPath := flag.String("config", "", "配置文件路径（YAML/JSON），不指定则使用默认配置与环境变量")
+	transport := flag.String("transport", "", "传输方式：sse、http 或 stdio，覆盖配置中的 mcp.transport")
+	flag.Parse()
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatalf("load config error: %v", err)
+	}
+	if *transport != "" {
+		cfg.MCP.Transport = *transport
+	}
+	handlers.ConfigureRunningHub(cfg.MCP.RunningHub)
+	handlers.ConfigureOpenMeteo(cfg.MCP.OpenMeteo)
+	handlers.ConfigureWeather(cfg.MCP.Weather)
+
+	// 工具调用可被客户端以 notifications/cancelled 取消，见 cancel.go
+	hooks := &server.Hooks{}
+	hooks.AddBeforeCallTool(recordRequestID)
+	s := server.NewMCPServer("weather_agent", "1.0.0",
+		server.WithToolCapabilities(true),
+		server.WithHooks(hooks),
+		server.WithToolHandlerMiddleware(toolMetricsMiddleware),
+		server.WithToolHandlerMiddleware(cancellableToolMiddleware),
+	)
+	s.AddNotificationHandler(mcpext.CancelledNotification, handleCancelled)
 
 	s.AddTools(tools.All()...)
 
-	addr := ":3333"
-	log.Printf("MCP SSE server listening on %s\n", addr)
+	addr := cfg.MCP.Addr
 
-	sseServer := server.NewSSEServer(
-		s,
-		server.WithSSEEndpoint("/sse"),
-		server.WithMessageEndpoint("/message"),
-	)
+	// 同一组工具可通过三种传输方式提供；日志统一写 stderr，stdio 模式下 stdout 只用于协议消息。
+	// HTTP 类传输在同一端口提供 /metrics（Prometheus 文本格式），stdio 模式下没有 HTTP 端口，不提供指标
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	httpSrv := &http.Server{Handler: mux}
+	switch cfg.MCP.Transport {
+	case "sse":
+		log.Printf("MCP SSE server listening on %s\n", addr)
+
+		sseServer := server.NewSSEServer(
+			s,
+			server.WithSSEEndpoint("/sse"),
+			server.WithMessageEndpoint("/message"),
+			server.WithHTTPServer(httpSrv),
+		)
+		// /sse 是长连接，只统计 /message 的请求数与耗时
+		mux.Handle("/sse", sseServer.SSEHandler())
+		mux.Handle("/message", metrics.InstrumentHandler("/message", sseServer.MessageHandler()))
+
+		if err := sseServer.Start(addr); err != nil {
+			log.Fatalf("mcp sse server error: %v", err)
+		}
+	case "http":
+		log.Printf("MCP Streamable HTTP server listening on %s/mcp\n", addr)
+
+		httpServer := server.NewStreamableHTTPServer(s,
+			server.WithEndpointPath("/mcp"),
+			server.WithStreamableHTTPServer(httpSrv),
+		)
+		mux.Handle("/mcp", metrics.InstrumentHandler("/mcp", httpServer))
+
+		if err := httpServer.Start(addr); err != nil {
+			log.Fatalf("mcp streamable http server error: %v", err)
+		}
+	case "stdio":
+		log.Println("MCP stdio server started")
 
-	if err := sseServer.Start(addr); err != nil {
-		log.Fatalf("mcp sse server error: %v", err)
+		if err := server.ServeStdio(s); err != nil {
+			log.Fatalf("mcp stdio server error: %v", err)
+		}
+	default:
+		log.Fatalf("unknown transport %q, want sse, http or stdio", cfg.MCP.Transport)
 	}
 }
@@ -0,0 +1,470 @@
+// Package mcptest 进程内的 MCP Server 测试夹具：注册 tools.All() 的全部工具，
+// Open-Meteo 与 RunningHub 由本地桩服务代替，不访问外网、不产生费用。
+//
+// 用法：
+//
+//	srv := mcptest.NewServer(&mcptest.Upstream{Script: "第一幕……"})
+//	defer srv.Close()
+//	// agent 侧按普通 MCP Server 连接：endpoint 设为 srv.URL（Streamable HTTP）
+//	// 或直接使用进程内客户端：cli, err := srv.Client(ctx)
+//
+// 夹具通过 handlers.ConfigureOpenMeteo / ConfigureWeather / ConfigureRunningHub 改写工具使用的包级客户端，
+// 同一进程内同时只能有一个夹具，使用夹具的测试不能并行。
+package mcptest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aistudiolabx/eino-demo/backend/config"
+	"github.com/aistudiolabx/eino-demo/backend/mcp_server/client"
+	"github.com/aistudiolabx/eino-demo/backend/mcp_server/handlers"
+	"github.com/aistudiolabx/eino-demo/backend/mcp_server/tools"
+	mcpclient "github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// DefaultCities Upstream.Cities 为空时可查询的城市
+var DefaultCities = map[string]City{
+	"Beijing": {Latitude: 39.9075, Longitude: 116.39723, Country: "中国", CountryCode: "CN", Admin1: "北京市", Population: 18960744, Timezone: "Asia/Shanghai", Temperature: 21.5, WeatherCode: 0,
+		AirQuality: airQuality(55.2, 80.1, 62, 38.5, 48, 150)},
+	"Shanghai": {Latitude: 31.22222, Longitude: 121.45806, Country: "中国", CountryCode: "CN", Admin1: "上海市", Population: 24874500, Timezone: "Asia/Shanghai", Temperature: 24.1, WeatherCode: 3,
+		AirQuality: airQuality(18.4, 30.2, 75, 25.1, 28, 64)},
+	"Shenzhen": {Latitude: 22.54554, Longitude: 114.0683, Country: "中国", CountryCode: "CN", Admin1: "广东省", Population: 17494398, Timezone: "Asia/Shanghai", Temperature: 28.3, WeatherCode: 61,
+		AirQuality: airQuality(8.1, 15.6, 40, 12.3, 12, 34)},
+}
+
+// airQuality 各项数据齐全的空气质量
+func airQuality(pm25, pm10, ozone, no2, europeanAQI, usAQI float64) client.AirQualityCurrent {
+	return client.AirQualityCurrent{
+		Time: forecastStart.Format("2006-01-02T15:04"), PM2_5: &pm25, PM10: &pm10,
+		Ozone: &ozone, NitrogenDioxide: &no2, EuropeanAQI: &europeanAQI, USAQI: &usAQI,
+	}
+}
+
+// City 桩服务中的一个城市：地理编码结果与当前天气
+type City struct {
+	// Name 地理编码结果中的名字，为空时使用查询的名字
+	Name        string
+	Latitude    float64
+	Longitude   float64
+	Country     string
+	CountryCode string
+	Admin1      string
+	Admin2      string
+	Timezone    string
+	Population  int
+	Temperature float64
+	WeatherCode int
+	// AirQuality air_quality 工具查询到的当前空气质量，字段为 nil 表示缺少该项数据
+	AirQuality client.AirQualityCurrent
+}
+
+// geocode 转换为地理编码结果
+func (c City) geocode(name string) client.GeocodeResult {
+	if c.Name != "" {
+		name = c.Name
+	}
+	return client.GeocodeResult{
+		Name: name, Latitude: c.Latitude, Longitude: c.Longitude,
+		Country: c.Country, CountryCode: c.CountryCode, Admin1: c.Admin1, Admin2: c.Admin2,
+		Timezone: c.Timezone, Population: c.Population,
+	}
+}
+
+// Upstream 桩服务的数据与行为，零值可用
+type Upstream struct {
+	// Cities 按城市名（即 weather 工具的 city 参数）索引，为空时使用 DefaultCities
+	Cities map[string]City
+	// Places 同名的多个地点，按地名索引，优先于 Cities；用于测试地名歧义
+	Places map[string][]City
+	// Script novel_to_script 工作流输出的剧本文本
+	Script string
+	// Polls RunningHub 任务在第几次状态查询时完成，<=0 时第一次查询即完成
+	Polls int
+	// Fail 为 true 时 RunningHub 任务以 FAILED 结束
+	Fail bool
+	// OpenMeteoDown 为 true 时 Open-Meteo 的地理编码与预报接口返回 503，weather 等工具切换到 wttr 桩
+	OpenMeteoDown bool
+
+	mu       sync.Mutex
+	requests []string
+	polls    int
+}
+
+// Requests 桩服务收到的请求（方法与路径），按到达顺序
+func (u *Upstream) Requests() []string {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return append([]string(nil), u.requests...)
+}
+
+func (u *Upstream) cities() map[string]City {
+	if len(u.Cities) == 0 {
+		return DefaultCities
+	}
+	return u.Cities
+}
+
+// candidates 地名对应的地理编码候选
+func (u *Upstream) candidates(name string) []City {
+	if places, ok := u.Places[name]; ok {
+		return places
+	}
+	if c, ok := u.cities()[name]; ok {
+		return []City{c}
+	}
+	return nil
+}
+
+// locate 按经纬度查找城市；请求中的经纬度按 %f 格式化，只保留 6 位小数
+func (u *Upstream) locate(lat, lon float64) (City, bool) {
+	all := make([]City, 0, len(u.cities()))
+	for _, c := range u.cities() {
+		all = append(all, c)
+	}
+	for _, places := range u.Places {
+		all = append(all, places...)
+	}
+	for _, c := range all {
+		if fmt.Sprintf("%f", c.Latitude) == fmt.Sprintf("%f", lat) && fmt.Sprintf("%f", c.Longitude) == fmt.Sprintf("%f", lon) {
+			return c, true
+		}
+	}
+	return City{}, false
+}
+
+// forecastStart 预报数据的起始时间，固定值使结果可复现
+var forecastStart = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// timezone 未设置 Timezone 时按 Asia/Shanghai
+func (c City) timezone() string {
+	if c.Timezone == "" {
+		return "Asia/Shanghai"
+	}
+	return c.Timezone
+}
+
+// daily 以当前天气为基准生成逐日预报：第 i 天最高气温为 Temperature+i，最低气温低 6 度
+func (c City) daily(days int) *client.DailyForecast {
+	d := &client.DailyForecast{}
+	for i := 0; i < days; i++ {
+		d.Time = append(d.Time, forecastStart.AddDate(0, 0, i).Format("2006-01-02"))
+		d.WeatherCode = append(d.WeatherCode, c.WeatherCode)
+		d.Temperature2mMax = append(d.Temperature2mMax, c.Temperature+float64(i))
+		d.Temperature2mMin = append(d.Temperature2mMin, c.Temperature+float64(i)-6)
+		d.PrecipitationProbabilityMax = append(d.PrecipitationProbabilityMax, float64(10*i%100))
+		d.PrecipitationSum = append(d.PrecipitationSum, float64(i))
+		d.WindSpeed10mMax = append(d.WindSpeed10mMax, 10+float64(i))
+		d.UVIndexMax = append(d.UVIndexMax, 5)
+	}
+	return d
+}
+
+// hourly 以当前天气为基准生成逐小时预报
+func (c City) hourly(hours int) *client.HourlyForecast {
+	h := &client.HourlyForecast{}
+	for i := 0; i < hours; i++ {
+		h.Time = append(h.Time, forecastStart.Add(time.Duration(i)*time.Hour).Format("2006-01-02T15:04"))
+		h.Temperature2m = append(h.Temperature2m, c.Temperature)
+		h.WeatherCode = append(h.WeatherCode, c.WeatherCode)
+		h.PrecipitationProbability = append(h.PrecipitationProbability, float64(5*i%100))
+		h.WindSpeed10m = append(h.WindSpeed10m, 8)
+		h.UVIndex = append(h.UVIndex, 1)
+	}
+	return h
+}
+
+// history 以当前天气为基准生成 start~end 的逐日历史天气：日期按一年中的第几天取余使气温在 Temperature±5 度间变化，
+// 逢 3 的倍数日有 2.5mm 降水
+func (c City) history(start, end time.Time) client.DailyHistory {
+	var h client.DailyHistory
+	for d := start; !d.After(end); d = d.AddDate(0, 0, 1) {
+		shift := float64(d.YearDay()%11 - 5)
+		code, hi, lo, mean := c.WeatherCode, c.Temperature+shift, c.Temperature+shift-6, c.Temperature+shift-3
+		precip, hours, wind := 0.0, 0.0, 12.0
+		if d.Day()%3 == 0 {
+			code, precip, hours = 61, 2.5, 3
+		}
+		h.Time = append(h.Time, d.Format("2006-01-02"))
+		h.WeatherCode = append(h.WeatherCode, &code)
+		h.Temperature2mMax = append(h.Temperature2mMax, &hi)
+		h.Temperature2mMin = append(h.Temperature2mMin, &lo)
+		h.Temperature2mMean = append(h.Temperature2mMean, &mean)
+		h.PrecipitationSum = append(h.PrecipitationSum, &precip)
+		h.PrecipitationHours = append(h.PrecipitationHours, &hours)
+		h.WindSpeed10mMax = append(h.WindSpeed10mMax, &wind)
+	}
+	return h
+}
+
+// wwoCodes WMO 天气代码对应的 wttr.in（WorldWeatherOnline）代码，桩服务只需覆盖常见天气
+var wwoCodes = map[int]string{0: "113", 1: "116", 2: "116", 3: "122", 45: "248", 61: "296", 63: "302", 65: "308", 71: "326", 95: "389"}
+
+// wttr 生成 wttr.in format=j1 格式的数据：当前天气与从 forecastStart 起 3 天、每天 8 档的预报
+func (c City) wttr() map[string]any {
+	str := func(f float64) string { return strconv.FormatFloat(f, 'f', -1, 64) }
+	code := wwoCodes[c.WeatherCode]
+	var days []map[string]any
+	for i := 0; i < 3; i++ {
+		var hourly []map[string]any
+		for slot := 0; slot < 8; slot++ {
+			hourly = append(hourly, map[string]any{
+				"time": strconv.Itoa(slot * 300), "tempC": str(c.Temperature + float64(i)), "weatherCode": code,
+				"chanceofrain": strconv.Itoa(10 * slot), "windspeedKmph": "9", "uvIndex": "2", "precipMM": "0.5",
+			})
+		}
+		days = append(days, map[string]any{
+			"date":     forecastStart.AddDate(0, 0, i).Format("2006-01-02"),
+			"maxtempC": str(c.Temperature + float64(i)), "mintempC": str(c.Temperature + float64(i) - 6),
+			"uvIndex": "4", "hourly": hourly,
+		})
+	}
+	value := func(s string) []map[string]string { return []map[string]string{{"value": s}} }
+	// forecastStart 是当地时间，observation_time 为同一时刻的 UTC 时间
+	loc, err := time.LoadLocation(c.timezone())
+	if err != nil {
+		loc = time.UTC
+	}
+	_, offset := time.Date(forecastStart.Year(), forecastStart.Month(), forecastStart.Day(), 0, 0, 0, 0, loc).Zone()
+	return map[string]any{
+		"current_condition": []map[string]any{{
+			"temp_C": str(c.Temperature), "weatherCode": code,
+			"localObsDateTime": forecastStart.Format("2006-01-02 03:04 PM"),
+			"observation_time": forecastStart.Add(-time.Duration(offset) * time.Second).Format("03:04 PM"),
+		}},
+		"nearest_area": []map[string]any{{
+			"areaName": value(c.Name), "country": value(c.Country), "region": value(c.Admin1),
+			"latitude": str(c.Latitude), "longitude": str(c.Longitude), "population": strconv.Itoa(c.Population),
+		}},
+		"weather": days,
+	}
+}
+
+// Server 注册了全部工具的 MCP Server 与上游桩服务
+type Server struct {
+	// MCP 工具已注册的 server，可继续添加工具或中间件
+	MCP *server.MCPServer
+	// URL Streamable HTTP 端点，可直接作为 agent.mcp_servers[].endpoint
+	URL string
+	// Upstream 桩服务的数据，可在测试中检查收到的请求
+	Upstream *Upstream
+
+	mcpHTTP  *httptest.Server
+	upstream *httptest.Server
+}
+
+// NewServer 启动桩服务与 MCP Server；up 为 nil 时使用零值 Upstream
+func NewServer(up *Upstream) *Server {
+	if up == nil {
+		up = &Upstream{}
+	}
+	upstream := httptest.NewServer(up.handler())
+
+	handlers.ConfigureOpenMeteo(config.OpenMeteoConfig{
+		GeocodeURL:     upstream.URL + "/v1/search",
+		ForecastURL:    upstream.URL + "/v1/forecast",
+		AirQualityURL:  upstream.URL + "/v1/air-quality",
+		ArchiveURL:     upstream.URL + "/v1/archive",
+		RequestTimeout: 5 * time.Second,
+	})
+	handlers.ConfigureWeather(config.WeatherConfig{
+		Providers:       []string{"open-meteo", "wttr"},
+		ProviderTimeout: 5 * time.Second,
+		Wttr:            config.WttrConfig{BaseURL: upstream.URL + "/wttr", RequestTimeout: 5 * time.Second},
+	})
+	handlers.ConfigureRunningHub(config.RunningHubConfig{
+		BaseURL:                 upstream.URL + "/runninghub",
+		APIKey:                  "mcptest",
+		NovelToScriptWorkflowID: "mcptest",
+		RequestTimeout:          5 * time.Second,
+		PollInterval:            10 * time.Millisecond,
+		RunTimeout:              10 * time.Second,
+	})
+
+	s := server.NewMCPServer("weather_agent", "test", server.WithToolCapabilities(true))
+	s.AddTools(tools.All()...)
+	mcpHTTP := server.NewTestStreamableHTTPServer(s)
+
+	return &Server{
+		MCP:      s,
+		URL:      mcpHTTP.URL + "/mcp",
+		Upstream: up,
+		mcpHTTP:  mcpHTTP,
+		upstream: upstream,
+	}
+}
+
+// Client 返回已完成 initialize 的进程内客户端，调用方负责 Close
+func (s *Server) Client(ctx context.Context) (*mcpclient.Client, error) {
+	cli, err := mcpclient.NewInProcessClient(s.MCP)
+	if err != nil {
+		return nil, err
+	}
+	if err := cli.Start(ctx); err != nil {
+		return nil, err
+	}
+	req := mcp.InitializeRequest{}
+	req.Params.ProtocolVersion = mcp.LATEST_PROTOCOL_VERSION
+	req.Params.ClientInfo = mcp.Implementation{Name: "mcptest", Version: "test"}
+	if _, err := cli.Initialize(ctx, req); err != nil {
+		_ = cli.Close()
+		return nil, fmt.Errorf("initialize: %w", err)
+	}
+	return cli, nil
+}
+
+// Close 关闭 MCP Server 与桩服务
+func (s *Server) Close() {
+	s.mcpHTTP.Close()
+	s.upstream.Close()
+}
+
+// handler 模拟 Open-Meteo（地理编码、预报、空气质量、历史天气）、wttr.in 与 RunningHub OpenAPI 中工具用到的接口
+func (u *Upstream) handler() http.Handler {
+	mux := http.NewServeMux()
+	writeJSON := func(w http.ResponseWriter, v any) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(v)
+	}
+
+	mux.HandleFunc("GET /v1/search", func(w http.ResponseWriter, r *http.Request) {
+		if u.OpenMeteoDown {
+			http.Error(w, "open-meteo down", http.StatusServiceUnavailable)
+			return
+		}
+		q := r.URL.Query()
+		name := q.Get("name")
+		resp := client.GeocodeResponse{}
+		for _, c := range u.candidates(name) {
+			resp.Results = append(resp.Results, c.geocode(name))
+		}
+		if count, err := strconv.Atoi(q.Get("count")); err == nil && count < len(resp.Results) {
+			resp.Results = resp.Results[:count]
+		}
+		writeJSON(w, resp)
+	})
+	mux.HandleFunc("GET /v1/forecast", func(w http.ResponseWriter, r *http.Request) {
+		if u.OpenMeteoDown {
+			http.Error(w, "open-meteo down", http.StatusServiceUnavailable)
+			return
+		}
+		q := r.URL.Query()
+		lat, _ := strconv.ParseFloat(q.Get("latitude"), 64)
+		lon, _ := strconv.ParseFloat(q.Get("longitude"), 64)
+		c, ok := u.locate(lat, lon)
+		if !ok {
+			http.Error(w, "unknown location", http.StatusBadRequest)
+			return
+		}
+		tz := c.timezone()
+		switch {
+		case q.Has("daily"):
+			days, _ := strconv.Atoi(q.Get("forecast_days"))
+			writeJSON(w, client.ForecastResponse{Timezone: tz, Daily: c.daily(days)})
+		case q.Has("hourly"):
+			hours, _ := strconv.Atoi(q.Get("forecast_hours"))
+			writeJSON(w, client.ForecastResponse{Timezone: tz, Hourly: c.hourly(hours)})
+		default:
+			writeJSON(w, client.WeatherResponse{Current: client.WeatherCurrent{Temperature2m: c.Temperature, WeatherCode: c.WeatherCode}})
+		}
+	})
+
+	mux.HandleFunc("GET /v1/air-quality", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		lat, _ := strconv.ParseFloat(q.Get("latitude"), 64)
+		lon, _ := strconv.ParseFloat(q.Get("longitude"), 64)
+		c, ok := u.locate(lat, lon)
+		if !ok {
+			http.Error(w, "unknown location", http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, client.AirQualityResponse{Timezone: c.timezone(), Current: c.AirQuality})
+	})
+
+	mux.HandleFunc("GET /v1/archive", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		lat, _ := strconv.ParseFloat(q.Get("latitude"), 64)
+		lon, _ := strconv.ParseFloat(q.Get("longitude"), 64)
+		c, ok := u.locate(lat, lon)
+		if !ok {
+			http.Error(w, "unknown location", http.StatusBadRequest)
+			return
+		}
+		start, err1 := time.Parse("2006-01-02", q.Get("start_date"))
+		end, err2 := time.Parse("2006-01-02", q.Get("end_date"))
+		if err1 != nil || err2 != nil {
+			http.Error(w, "invalid date", http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, client.HistoryResponse{Timezone: c.timezone(), Daily: c.history(start, end)})
+	})
+
+	// wttr.in format=j1：location 为 ~地名 或 纬度,经度
+	mux.HandleFunc("GET /wttr/{location}", func(w http.ResponseWriter, r *http.Request) {
+		loc := r.PathValue("location")
+		var c City
+		var ok bool
+		if name, byName := strings.CutPrefix(loc, "~"); byName {
+			if cands := u.candidates(name); len(cands) > 0 {
+				c, ok = cands[0], true
+				if c.Name == "" {
+					c.Name = name
+				}
+			}
+		} else if lat, lon, found := strings.Cut(loc, ","); found {
+			latF, _ := strconv.ParseFloat(lat, 64)
+			lonF, _ := strconv.ParseFloat(lon, 64)
+			c, ok = u.locate(latF, lonF)
+		}
+		if !ok {
+			http.Error(w, "unknown location", http.StatusNotFound)
+			return
+		}
+		writeJSON(w, c.wttr())
+	})
+
+	mux.HandleFunc("POST /runninghub/task/openapi/create", func(w http.ResponseWriter, r *http.Request) {
+		u.mu.Lock()
+		u.polls = 0
+		u.mu.Unlock()
+		writeJSON(w, map[string]any{"code": 0, "msg": "success", "data": map[string]any{"taskId": "mcptest-task", "taskStatus": "QUEUED"}})
+	})
+	mux.HandleFunc("POST /runninghub/task/openapi/status", func(w http.ResponseWriter, r *http.Request) {
+		u.mu.Lock()
+		u.polls++
+		polls := u.polls
+		u.mu.Unlock()
+		status := "RUNNING"
+		switch {
+		case polls < u.Polls:
+		case u.Fail:
+			status = "FAILED"
+		default:
+			status = "SUCCESS"
+		}
+		writeJSON(w, map[string]any{"code": 0, "msg": "success", "data": status})
+	})
+	mux.HandleFunc("POST /runninghub/task/openapi/outputs", func(w http.ResponseWriter, r *http.Request) {
+		fileURL := "http://" + r.Host + "/runninghub/output.txt"
+		writeJSON(w, map[string]any{"code": 0, "msg": "success", "data": []map[string]any{{"fileUrl": fileURL, "fileType": "txt", "nodeId": "9"}}})
+	})
+	mux.HandleFunc("GET /runninghub/output.txt", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(u.Script))
+	})
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u.mu.Lock()
+		u.requests = append(u.requests, r.Method+" "+r.URL.Path)
+		u.mu.Unlock()
+		mux.ServeHTTP(w, r)
+	})
+}
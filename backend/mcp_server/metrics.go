@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/aistudiolabx/eino-demo/backend/metrics"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// MCP 工具调用指标；工具返回 isError 结果也计为 error
+var (
+	mcpToolCalls = metrics.NewCounter("mcp_tool_calls_total",
+		"MCP 工具调用次数", "tool", "status")
+	mcpToolCallDuration = metrics.NewHistogram("mcp_tool_call_duration_seconds",
+		"MCP 工具调用耗时（秒）", nil, "tool")
+)
+
+// toolMetricsMiddleware 统计每个工具的调用次数、错误与耗时
+func toolMetricsMiddleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		start := time.Now()
+		res, err := next(ctx, req)
+
+		status := "ok"
+		if err != nil || (res != nil && res.IsError) {
+			status = "error"
+		}
+		mcpToolCalls.Inc(req.Params.Name, status)
+		mcpToolCallDuration.ObserveSince(start, req.Params.Name)
+		return res, err
+	}
+}
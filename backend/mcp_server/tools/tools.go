@@ -6,17 +6,64 @@ import (
 	"github.com/mark3labs/mcp-go/server"
 )
 
+// city 的可选过滤参数：同名地点较多时（Springfield、朝阳）用来缩小范围，
+// 工具返回候选列表时可按其中给出的 country / region 重新调用
+const (
+	countryDesc = "可选，国家代码或国家名，例如：CN、US、中国"
+	regionDesc  = "可选，省、州或下一级行政区，例如：北京、辽宁、Illinois"
+)
+
 // All 返回所有 MCP tool 定义及 handler，供 server 一次性注册（AddTools）
 func All() []server.ServerTool {
 	return []server.ServerTool{
 		{
 			Tool: mcp.NewTool(
 				"weather",
-				mcp.WithDescription("查询指定城市的当前天气（使用 Open-Meteo）"),
+				mcp.WithDescription("查询指定城市的当前天气（使用 Open-Meteo）；地名有歧义时返回候选地点列表"),
 				mcp.WithString("city", mcp.Required(), mcp.Description("城市名，例如：Beijing、Shenzhen")),
+				mcp.WithString("country", mcp.Description(countryDesc)),
+				mcp.WithString("region", mcp.Description(regionDesc)),
 			),
 			Handler: server.ToolHandlerFunc(handlers.Weather),
 		},
+		{
+			Tool: mcp.NewTool(
+				"weather_forecast",
+				mcp.WithDescription("查询指定城市未来几天的逐日天气预报（最低/最高气温、降水概率与降水量、最大风速、紫外线指数），可选附带未来若干小时的逐小时预报（使用 Open-Meteo）"),
+				mcp.WithString("city", mcp.Required(), mcp.Description("城市名，例如：Beijing、Shenzhen")),
+				mcp.WithString("country", mcp.Description(countryDesc)),
+				mcp.WithString("region", mcp.Description(regionDesc)),
+				mcp.WithNumber("days", mcp.Description("预报天数，从今天算起，1~16，默认 3；问明天的天气时传 2"), mcp.Min(1), mcp.Max(16)),
+				mcp.WithNumber("hours", mcp.Description("可选，附带从当前整点起多少小时的逐小时预报，0~72，默认 0 表示不需要"), mcp.Min(0), mcp.Max(72)),
+				mcp.WithOutputSchema[handlers.ForecastResult](),
+			),
+			Handler: server.ToolHandlerFunc(handlers.WeatherForecast),
+		},
+		{
+			Tool: mcp.NewTool(
+				"weather_history",
+				mcp.WithDescription("查询指定城市过去某天或某段日期（最多 31 天）的逐日历史天气：天气、最低/最高/平均气温、降水量与降水时长、最大风速（使用 Open-Meteo 历史数据，最近几天可能尚未入库）"),
+				mcp.WithString("city", mcp.Required(), mcp.Description("城市名，例如：Beijing、Shenzhen")),
+				mcp.WithString("country", mcp.Description(countryDesc)),
+				mcp.WithString("region", mcp.Description(regionDesc)),
+				mcp.WithString("date", mcp.Description("要查询的日期或时间段：YYYY-MM-DD，或“昨天”“前天”“3天前”“上周六”“last saturday”“上周”“过去7天”“上个月”等，按城市当地时间换算")),
+				mcp.WithString("start_date", mcp.Description("不传 date 时使用：开始日期，格式同 date")),
+				mcp.WithString("end_date", mcp.Description("可选，结束日期（含），格式同 date，默认与 start_date 相同")),
+				mcp.WithOutputSchema[handlers.HistoryResult](),
+			),
+			Handler: server.ToolHandlerFunc(handlers.WeatherHistory),
+		},
+		{
+			Tool: mcp.NewTool(
+				"air_quality",
+				mcp.WithDescription("查询指定城市当前的空气质量：PM2.5、PM10、臭氧、二氧化氮浓度，欧洲与美国 AQI，以及空气质量等级和健康建议（使用 Open-Meteo）"),
+				mcp.WithString("city", mcp.Required(), mcp.Description("城市名，例如：Beijing、Shenzhen")),
+				mcp.WithString("country", mcp.Description(countryDesc)),
+				mcp.WithString("region", mcp.Description(regionDesc)),
+				mcp.WithOutputSchema[handlers.AirQualityResult](),
+			),
+			Handler: server.ToolHandlerFunc(handlers.AirQuality),
+		},
 		{
 			Tool: mcp.NewTool(
 				"novel_to_script",
@@ -0,0 +1,36 @@
+// Package mcpext Agent Server 与 MCP Server 之间关于工具调用进度与取消的约定。
+//
+// Agent 在每次 tools/call 的 _meta.progressToken 中带上唯一 token：
+//   - MCP Server 以标准的 notifications/progress 回报该调用的进度；
+//   - 调用方取消（例如 DELETE /jobs/{id}）时，Agent 发送标准的 notifications/cancelled，
+//     requestId 为该 tools/call 的 JSON-RPC 请求 ID，MCP Server 据此取消对应 handler 的 ctx，
+//     RunningHub 轮询等长任务随之结束。
+//
+// SSE 传输下 server 处理请求的 ctx 与客户端连接无关，因此需要显式的取消通知；
+// 不处理取消通知的 server 会忽略它，行为与普通 MCP 调用一致。
+package mcpext
+
+import (
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// ProgressNotification MCP 标准的进度通知（mcp-go 未提供该方法名常量）
+const ProgressNotification = "notifications/progress"
+
+// CancelledNotification MCP 标准的取消通知（mcp-go 未提供该方法名常量），params: {"requestId": ..., "reason": "..."}
+const CancelledNotification = "notifications/cancelled"
+
+// CancelledParams 构造取消请求 requestID 的通知参数
+func CancelledParams(requestID mcp.RequestId, reason string) map[string]any {
+	return map[string]any{"requestId": requestID, "reason": reason}
+}
+
+// TokenKey 把 progressToken 统一为字符串，JSON 解码后数字 token 会变成 float64
+func TokenKey(token mcp.ProgressToken) string {
+	if token == nil {
+		return ""
+	}
+	return fmt.Sprint(token)
+}
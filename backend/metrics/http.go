@@ -0,0 +1,57 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// HTTP 接口的请求数与耗时，两个服务共用
+var (
+	httpRequests = NewCounter("http_requests_total",
+		"HTTP 请求数", "endpoint", "method", "code")
+	httpRequestDuration = NewHistogram("http_request_duration_seconds",
+		"HTTP 请求耗时（秒），流式接口为整个流的持续时间", nil, "endpoint", "method")
+)
+
+// InstrumentHandler 统计 endpoint 的请求数（按方法与状态码）与耗时
+func InstrumentHandler(endpoint string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		httpRequests.Inc(endpoint, r.Method, strconv.Itoa(rec.status))
+		httpRequestDuration.ObserveSince(start, endpoint, r.Method)
+	})
+}
+
+// statusRecorder 记录响应状态码；保留 Flush 以免 SSE 等流式接口失去刷新能力
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	if !r.wroteHeader {
+		r.status = code
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap 供 http.ResponseController 取到底层 ResponseWriter
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
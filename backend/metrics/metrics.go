@@ -0,0 +1,268 @@
+// Package metrics 两个后端服务（Agent Server、MCP Server）共用的最小指标库：
+// 计数器与直方图注册到进程内的默认注册表，Handler 以 Prometheus 文本格式输出，供 /metrics 抓取。
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefBuckets 默认的耗时直方图分桶（秒），覆盖毫秒级 HTTP 调用到分钟级的模型与工作流调用
+var DefBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300}
+
+// collector 可输出为 Prometheus 文本格式的指标
+type collector interface {
+	name() string
+	write(w io.Writer)
+}
+
+// registry 进程内的指标注册表
+type registry struct {
+	mu         sync.Mutex
+	collectors map[string]collector
+}
+
+var defaultRegistry = &registry{collectors: make(map[string]collector)}
+
+// register 注册指标；重名说明代码里重复定义了同一指标，直接 panic
+func (r *registry) register(c collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, dup := r.collectors[c.name()]; dup {
+		panic(fmt.Sprintf("metrics: duplicate metric %q", c.name()))
+	}
+	r.collectors[c.name()] = c
+}
+
+func (r *registry) write(w io.Writer) {
+	r.mu.Lock()
+	names := make([]string, 0, len(r.collectors))
+	for name := range r.collectors {
+		names = append(names, name)
+	}
+	collectors := r.collectors
+	r.mu.Unlock()
+
+	sort.Strings(names)
+	for _, name := range names {
+		collectors[name].write(w)
+	}
+}
+
+// Handler 以 Prometheus 文本格式（0.0.4）输出默认注册表中的全部指标
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		defaultRegistry.write(w)
+	})
+}
+
+// series 一组标签值对应的一条时间序列
+type series[T any] struct {
+	labelValues []string
+	value       T
+}
+
+// vec 按标签值区分的一组时间序列
+type vec[T any] struct {
+	metricName string
+	help       string
+	labels     []string
+	newValue   func() T
+
+	mu     sync.Mutex
+	series map[string]*series[T]
+}
+
+func (v *vec[T]) name() string { return v.metricName }
+
+// with 返回标签值对应的序列，不存在则创建；标签值个数必须与定义一致
+func (v *vec[T]) with(labelValues []string) T {
+	if len(labelValues) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", v.metricName, len(v.labels), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	s, ok := v.series[key]
+	if !ok {
+		s = &series[T]{labelValues: append([]string(nil), labelValues...), value: v.newValue()}
+		v.series[key] = s
+	}
+	return s.value
+}
+
+// sorted 按标签值排序的序列快照，保证输出稳定
+func (v *vec[T]) sorted() []*series[T] {
+	v.mu.Lock()
+	out := make([]*series[T], 0, len(v.series))
+	for _, s := range v.series {
+		out = append(out, s)
+	}
+	v.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool {
+		return strings.Join(out[i].labelValues, "\xff") < strings.Join(out[j].labelValues, "\xff")
+	})
+	return out
+}
+
+func (v *vec[T]) writeHeader(w io.Writer, typ string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", v.metricName, escapeHelp(v.help), v.metricName, typ)
+}
+
+// CounterVec 只增不减的计数器
+type CounterVec struct {
+	vec[*counter]
+}
+
+type counter struct {
+	mu sync.Mutex
+	v  float64
+}
+
+// NewCounter 定义并注册一个计数器
+func NewCounter(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{vec[*counter]{
+		metricName: name,
+		help:       help,
+		labels:     labels,
+		newValue:   func() *counter { return &counter{} },
+		series:     make(map[string]*series[*counter]),
+	}}
+	defaultRegistry.register(c)
+	return c
+}
+
+// Inc 计数加 1
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add 计数加 delta（必须非负）
+func (c *CounterVec) Add(delta float64, labelValues ...string) {
+	if delta < 0 {
+		return
+	}
+	ct := c.with(labelValues)
+	ct.mu.Lock()
+	ct.v += delta
+	ct.mu.Unlock()
+}
+
+func (c *CounterVec) write(w io.Writer) {
+	c.writeHeader(w, "counter")
+	for _, s := range c.sorted() {
+		s.value.mu.Lock()
+		v := s.value.v
+		s.value.mu.Unlock()
+		fmt.Fprintf(w, "%s%s %s\n", c.metricName, formatLabels(c.labels, s.labelValues, "", ""), formatFloat(v))
+	}
+}
+
+// HistogramVec 按分桶统计的直方图，用于耗时等分布
+type HistogramVec struct {
+	vec[*histogram]
+	buckets []float64
+}
+
+type histogram struct {
+	mu     sync.Mutex
+	counts []uint64 // 各分桶（非累计）计数，最后一个为 +Inf
+	sum    float64
+	count  uint64
+}
+
+// NewHistogram 定义并注册一个直方图；buckets 为 nil 时使用 DefBuckets
+func NewHistogram(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	if buckets == nil {
+		buckets = DefBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	h := &HistogramVec{
+		vec: vec[*histogram]{
+			metricName: name,
+			help:       help,
+			labels:     labels,
+			newValue:   func() *histogram { return &histogram{counts: make([]uint64, len(buckets)+1)} },
+			series:     make(map[string]*series[*histogram]),
+		},
+		buckets: buckets,
+	}
+	defaultRegistry.register(h)
+	return h
+}
+
+// Observe 记录一个观测值
+func (h *HistogramVec) Observe(v float64, labelValues ...string) {
+	hg := h.with(labelValues)
+	i := sort.SearchFloat64s(h.buckets, v)
+	hg.mu.Lock()
+	hg.counts[i]++
+	hg.sum += v
+	hg.count++
+	hg.mu.Unlock()
+}
+
+// ObserveSince 记录从 start 到现在经过的秒数
+func (h *HistogramVec) ObserveSince(start time.Time, labelValues ...string) {
+	h.Observe(time.Since(start).Seconds(), labelValues...)
+}
+
+func (h *HistogramVec) write(w io.Writer) {
+	h.writeHeader(w, "histogram")
+	for _, s := range h.sorted() {
+		s.value.mu.Lock()
+		counts := append([]uint64(nil), s.value.counts...)
+		sum, count := s.value.sum, s.value.count
+		s.value.mu.Unlock()
+
+		var cumulative uint64
+		for i, upper := range h.buckets {
+			cumulative += counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, formatLabels(h.labels, s.labelValues, "le", formatFloat(upper)), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, formatLabels(h.labels, s.labelValues, "le", "+Inf"), count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.metricName, formatLabels(h.labels, s.labelValues, "", ""), formatFloat(sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.metricName, formatLabels(h.labels, s.labelValues, "", ""), count)
+	}
+}
+
+// formatLabels 输出 {a="x",b="y"}；extraName 非空时追加一个标签（直方图的 le）
+func formatLabels(names, values []string, extraName, extraValue string) string {
+	if len(names) == 0 && extraName == "" {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, "%s=\"%s\"", name, escapeLabelValue(values[i]))
+	}
+	if extraName != "" {
+		if len(names) > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, "%s=\"%s\"", extraName, extraValue)
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(s string) string { return labelValueEscaper.Replace(s) }
+
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+func escapeHelp(s string) string { return helpEscaper.Replace(s) }
+
+func formatFloat(v float64) string { return strconv.FormatFloat(v, 'g', -1, 64) }
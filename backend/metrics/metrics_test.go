@@ -0,0 +1,129 @@
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// newTestCounter / newTestHistogram 创建不注册到默认注册表的指标，避免测试之间重名
+func newTestCounter(name, help string, labels ...string) *CounterVec {
+	return &CounterVec{vec[*counter]{
+		metricName: name,
+		help:       help,
+		labels:     labels,
+		newValue:   func() *counter { return &counter{} },
+		series:     make(map[string]*series[*counter]),
+	}}
+}
+
+func newTestHistogram(name string, buckets []float64, labels ...string) *HistogramVec {
+	return &HistogramVec{
+		vec: vec[*histogram]{
+			metricName: name,
+			help:       name,
+			labels:     labels,
+			newValue:   func() *histogram { return &histogram{counts: make([]uint64, len(buckets)+1)} },
+			series:     make(map[string]*series[*histogram]),
+		},
+		buckets: buckets,
+	}
+}
+
+func output(c collector) string {
+	var buf bytes.Buffer
+	c.write(&buf)
+	return buf.String()
+}
+
+func TestCounterEscaping(t *testing.T) {
+	c := newTestCounter("test_calls_total", "调用次数\n第二行 \\ 反斜杠", "tool")
+	c.Inc(`a"b\c` + "\nd")
+	c.Add(2.5, "plain")
+	c.Add(-1, "plain") // 计数器只增不减，负数忽略
+
+	want := `# HELP test_calls_total 调用次数\n第二行 \\ 反斜杠
+# TYPE test_calls_total counter
+test_calls_total{tool="a\"b\\c\nd"} 1
+test_calls_total{tool="plain"} 2.5
+`
+	if got := output(c); got != want {
+		t.Errorf("output:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestCounterWithoutLabels(t *testing.T) {
+	c := newTestCounter("test_total", "total")
+	c.Inc()
+	if got := output(c); !strings.HasSuffix(got, "\ntest_total 1\n") {
+		t.Errorf("output:\n%s", got)
+	}
+}
+
+func TestHistogramBuckets(t *testing.T) {
+	h := newTestHistogram("test_duration_seconds", []float64{0.1, 1}, "api")
+	// 等于上界的值计入该分桶（le 含等号）
+	for _, v := range []float64{0.05, 0.1, 0.5, 5} {
+		h.Observe(v, "geocode")
+	}
+
+	want := `# HELP test_duration_seconds test_duration_seconds
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{api="geocode",le="0.1"} 2
+test_duration_seconds_bucket{api="geocode",le="1"} 3
+test_duration_seconds_bucket{api="geocode",le="+Inf"} 4
+test_duration_seconds_sum{api="geocode"} 5.65
+test_duration_seconds_count{api="geocode"} 4
+`
+	if got := output(h); got != want {
+		t.Errorf("output:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestSeriesOrder(t *testing.T) {
+	c := newTestCounter("test_order_total", "order", "tool", "status")
+	for _, lv := range [][]string{{"weather", "ok"}, {"air_quality", "ok"}, {"weather", "error"}} {
+		c.Inc(lv...)
+	}
+	want := []string{
+		`test_order_total{tool="air_quality",status="ok"} 1`,
+		`test_order_total{tool="weather",status="error"} 1`,
+		`test_order_total{tool="weather",status="ok"} 1`,
+	}
+	for i := 0; i < 3; i++ {
+		lines := strings.Split(strings.TrimSpace(output(c)), "\n")[2:]
+		if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+			t.Fatalf("series:\n%s\nwant:\n%s", strings.Join(lines, "\n"), strings.Join(want, "\n"))
+		}
+	}
+
+	// 注册表按指标名输出
+	r := &registry{collectors: make(map[string]collector)}
+	r.register(newTestCounter("b_total", "b"))
+	r.register(newTestCounter("a_total", "a"))
+	var buf bytes.Buffer
+	r.write(&buf)
+	if a, b := strings.Index(buf.String(), "a_total"), strings.Index(buf.String(), "b_total"); a < 0 || b < a {
+		t.Errorf("registry output not sorted by name:\n%s", buf.String())
+	}
+}
+
+func TestLabelCountMismatchPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Inc with wrong number of label values did not panic")
+		}
+	}()
+	newTestCounter("test_labels_total", "labels", "tool", "status").Inc("weather")
+}
+
+func TestDuplicateRegistrationPanics(t *testing.T) {
+	r := &registry{collectors: make(map[string]collector)}
+	r.register(newTestCounter("dup_total", "dup"))
+	defer func() {
+		if recover() == nil {
+			t.Error("registering a duplicate metric did not panic")
+		}
+	}()
+	r.register(newTestCounter("dup_total", "dup"))
+}
@@ -0,0 +1,204 @@
+// Package modelreplay 把真实模型调用录制成 golden 文件（Recorder），再离线回放（Replayer）。
+// agent server 的 agent.model.record 与 replay provider 使用它演示离线运行，测试用它做回归。
+package modelreplay
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+// Golden 录制文件格式：按发生顺序保存每次模型调用
+type Golden struct {
+	Exchanges []Exchange `json:"exchanges"`
+}
+
+// Exchange 一次模型调用：输入消息、绑定的工具与模型回复
+type Exchange struct {
+	Messages []*schema.Message  `json:"messages"`
+	Tools    []*schema.ToolInfo `json:"tools,omitempty"`
+	Response *schema.Message    `json:"response"`
+}
+
+// recording 同一个 Recorder 经 WithTools 派生的实例共享录制内容
+type recording struct {
+	mu     sync.Mutex
+	path   string
+	golden Golden
+}
+
+// Recorder 包装真实模型，把每次调用追加写入 golden 文件；每次调用后整体重写文件，进程中途退出也不丢已录制的部分
+type Recorder struct {
+	inner model.ToolCallingChatModel
+	rec   *recording
+	tools []*schema.ToolInfo
+}
+
+var _ model.ToolCallingChatModel = (*Recorder)(nil)
+
+// NewRecorder 录制 inner 的调用到 path（已存在时覆盖）
+func NewRecorder(inner model.ToolCallingChatModel, path string) *Recorder {
+	return &Recorder{inner: inner, rec: &recording{path: path}}
+}
+
+// Generate 调用真实模型并录制
+func (r *Recorder) Generate(ctx context.Context, in []*schema.Message, opts ...model.Option) (*schema.Message, error) {
+	out, err := r.inner.Generate(ctx, in, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.record(in, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Stream 原样转发流式输出，流读完后把拼接的完整回复录制下来
+func (r *Recorder) Stream(ctx context.Context, in []*schema.Message, opts ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	sr, err := r.inner.Stream(ctx, in, opts...)
+	if err != nil {
+		return nil, err
+	}
+	copies := sr.Copy(2)
+	in = append([]*schema.Message(nil), in...)
+	go func() {
+		defer copies[1].Close()
+		var chunks []*schema.Message
+		for {
+			chunk, err := copies[1].Recv()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return
+			}
+			chunks = append(chunks, chunk)
+		}
+		out, err := schema.ConcatMessages(chunks)
+		if err != nil {
+			return
+		}
+		_ = r.record(in, out)
+	}()
+	return copies[0], nil
+}
+
+// WithTools 绑定工具到真实模型，返回共享录制内容的新实例
+func (r *Recorder) WithTools(tools []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	inner, err := r.inner.WithTools(tools)
+	if err != nil {
+		return nil, err
+	}
+	return &Recorder{inner: inner, rec: r.rec, tools: tools}, nil
+}
+
+func (r *Recorder) record(in []*schema.Message, out *schema.Message) error {
+	rec := r.rec
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	rec.golden.Exchanges = append(rec.golden.Exchanges, Exchange{Messages: in, Tools: r.tools, Response: out})
+	data, err := json.MarshalIndent(rec.golden, "", "  ")
+	if err != nil {
+		return fmt.Errorf("modelreplay: marshal golden: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(rec.path), 0o755); err != nil {
+		return fmt.Errorf("modelreplay: write golden: %w", err)
+	}
+	tmp := rec.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("modelreplay: write golden: %w", err)
+	}
+	if err := os.Rename(tmp, rec.path); err != nil {
+		return fmt.Errorf("modelreplay: write golden: %w", err)
+	}
+	return nil
+}
+
+// Replayer 回放 golden 文件的模型，不访问任何模型服务。
+// 按输入匹配未使用过的录制：比较 system 以外各消息的角色、user/assistant 内容与工具调用，
+// 不比较 system 提示与工具结果内容（时间、温度等每次运行都会变化）；找不到匹配时返回错误，
+// 说明输入与录制时不同，需要重新录制。
+type Replayer struct {
+	path string
+
+	mu        sync.Mutex
+	exchanges []Exchange
+	used      []bool
+}
+
+var _ model.ToolCallingChatModel = (*Replayer)(nil)
+
+// LoadReplayer 读取 golden 文件
+func LoadReplayer(path string) (*Replayer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("modelreplay: read golden: %w", err)
+	}
+	var g Golden
+	if err := json.Unmarshal(data, &g); err != nil {
+		return nil, fmt.Errorf("modelreplay: parse golden %s: %w", path, err)
+	}
+	return &Replayer{path: path, exchanges: g.Exchanges, used: make([]bool, len(g.Exchanges))}, nil
+}
+
+// Generate 返回与输入匹配的录制回复
+func (r *Replayer) Generate(ctx context.Context, in []*schema.Message, _ ...model.Option) (*schema.Message, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	key := replayKey(in)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, ex := range r.exchanges {
+		if r.used[i] || replayKey(ex.Messages) != key {
+			continue
+		}
+		r.used[i] = true
+		msg := *ex.Response
+		return &msg, nil
+	}
+	return nil, fmt.Errorf("modelreplay: %s 中没有与本次输入匹配的录制（共 %d 条），请重新录制：%s", r.path, len(r.exchanges), key)
+}
+
+// Stream 以单个分片返回录制的回复
+func (r *Replayer) Stream(ctx context.Context, in []*schema.Message, opts ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	msg, err := r.Generate(ctx, in, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return schema.StreamReaderFromArray([]*schema.Message{msg}), nil
+}
+
+// WithTools 回放不依赖绑定的工具，返回自身
+func (r *Replayer) WithTools([]*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	return r, nil
+}
+
+// replayKey 输入消息的匹配键
+func replayKey(msgs []*schema.Message) string {
+	var sb strings.Builder
+	for _, m := range msgs {
+		switch m.Role {
+		case schema.System:
+			continue
+		case schema.Tool:
+			fmt.Fprintf(&sb, "[tool %s]", m.ToolCallID)
+			continue
+		}
+		fmt.Fprintf(&sb, "[%s %q", m.Role, m.Content)
+		for _, tc := range m.ToolCalls {
+			fmt.Fprintf(&sb, " %s:%s(%s)", tc.ID, tc.Function.Name, tc.Function.Arguments)
+		}
+		sb.WriteString("]")
+	}
+	return sb.String()
+}
@@ -2,11 +2,17 @@
 # 在项目根目录启动全部服务：MCP Server、Agent Server、Frontend Server
 # 用法：./scripts/start.sh  或从项目根执行 bash scripts/start.sh
 # 使用 ComfyUI 相关 MCP 工具前，请设置环境变量：export RUNNINGHUB_API_KEY=你的APIKey
+# 指定配置文件：CONFIG=config.example.yaml ./scripts/start.sh（端口等以配置为准，下方打印的是默认值）
 
 set -e
 cd "$(dirname "$0")/.."
 ROOT="$PWD"
 
+CONFIG_ARGS=()
+if [[ -n "${CONFIG:-}" ]]; then
+  CONFIG_ARGS=(--config "$CONFIG")
+fi
+
 PIDS_FILE="${ROOT}/.start_pids"
 LOG_DIR="${ROOT}/logs"
 mkdir -p "$LOG_DIR"
@@ -35,19 +41,18 @@ echo ""
 
 # MCP Server :3333
 echo "启动 MCP Server ( :3333 )..."
-go run ./backend/mcp_server > "$LOG_DIR/mcp_server.log" 2>&1 &
+go run ./backend/mcp_server "${CONFIG_ARGS[@]}" > "$LOG_DIR/mcp_server.log" 2>&1 &
 echo $! >> "$PIDS_FILE"
-sleep 1
 
-# Agent Server :8082（依赖 MCP）
+# Agent Server :8082（在后台连接 MCP，MCP 未就绪或重启时自动重连）
 echo "启动 Agent Server ( :8082 )..."
-go run ./backend/agent_server > "$LOG_DIR/agent_server.log" 2>&1 &
+go run ./backend/agent_server "${CONFIG_ARGS[@]}" > "$LOG_DIR/agent_server.log" 2>&1 &
 echo $! >> "$PIDS_FILE"
 sleep 0.5
 
 # Frontend Server :8081（需在项目根运行以正确提供 frontend/）
 echo "启动 Frontend Server ( :8081 )..."
-( cd "$ROOT" && go run ./backend/frontend_server > "$LOG_DIR/frontend_server.log" 2>&1 ) &
+( cd "$ROOT" && go run ./backend/frontend_server "${CONFIG_ARGS[@]}" > "$LOG_DIR/frontend_server.log" 2>&1 ) &
 echo $! >> "$PIDS_FILE"
 
 echo ""
@@ -56,6 +61,7 @@ echo "  - MCP Server:     http://localhost:3333"
 echo "  - Agent Server:  http://localhost:8082"
 echo "  - Frontend:      http://localhost:8081"
 echo ""
+echo "指标: http://localhost:3333/metrics, http://localhost:8082/metrics"
 echo "日志: $LOG_DIR/*.log"
 echo "按 Ctrl+C 停止所有服务。"
 echo ""